@@ -51,6 +51,9 @@ type Blob struct {
 	filepath      string
 	contentType   string
 	memory        *memory
+	headerOnce    sync.Once
+	header        *ImageHeader
+	headerErr     error
 
 	Header http.Header
 	Stat   *Stat
@@ -0,0 +1,120 @@
+package imagor
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xudaolong/imagor/imagorpath"
+)
+
+// maxDPR maximum device pixel ratio from dpr() filter or client hints
+const maxDPR = 4
+
+// saveDataQuality maximum quality when client requests with Save-Data: on
+const saveDataQuality = 50
+
+// clientHintsHeaders client hints accepted via Accept-CH response header
+const clientHintsHeaders = "Sec-CH-DPR, Sec-CH-Width, Sec-CH-Viewport-Width, Save-Data"
+
+// applyClientHints applies dpr() filter, Sec-CH-DPR, Sec-CH-Width, Sec-CH-Viewport-Width
+// and Save-Data request headers to params.
+// Returns the request headers the result varies on, and the effective device pixel ratio
+func (app *Imagor) applyClientHints(
+	r *http.Request, p imagorpath.Params,
+) (_ imagorpath.Params, vary []string, dpr float64, isChanged bool) {
+	var varyDPR bool
+	var headerDPR = parseDPR(r.Header.Get("Sec-CH-DPR"))
+	var filters = make(imagorpath.Filters, 0, len(p.Filters))
+	for _, f := range p.Filters {
+		if f.Name != "dpr" {
+			filters = append(filters, f)
+			continue
+		}
+		// dpr(n), or dpr() dpr(auto) from Sec-CH-DPR
+		if args := strings.TrimSpace(f.Args); args == "" || args == "auto" {
+			dpr = headerDPR
+			varyDPR = true
+		} else {
+			dpr = parseDPR(args)
+		}
+		isChanged = true
+	}
+	p.Filters = filters
+	if p.Width == 0 && p.Height == 0 {
+		// fill in missing dimensions
+		if w := parseClientHintInt(r.Header.Get("Sec-CH-Width")); w > 0 {
+			// Sec-CH-Width is in physical pixels
+			p.Width = app.snapClientHintSize(w)
+			if dpr == 0 {
+				dpr = headerDPR
+			}
+			isChanged = true
+		} else if vw := parseClientHintInt(r.Header.Get("Sec-CH-Viewport-Width")); vw > 0 {
+			if dpr == 0 {
+				dpr = headerDPR
+			}
+			p.Width = app.snapClientHintSize(int(math.Round(float64(vw) * math.Max(dpr, 1))))
+			isChanged = true
+		} else {
+			// dpr() requires dimensions, source size unknown before loading
+			dpr = 0
+		}
+		vary = append(vary, "Sec-CH-Width", "Sec-CH-Viewport-Width")
+		varyDPR = true
+	} else if dpr > 0 {
+		p.Width = app.snapClientHintSize(int(math.Round(float64(p.Width) * dpr)))
+		p.Height = app.snapClientHintSize(int(math.Round(float64(p.Height) * dpr)))
+	}
+	if varyDPR {
+		vary = append(vary, "Sec-CH-DPR")
+	}
+	vary = append(vary, "Save-Data")
+	if strings.EqualFold(strings.TrimSpace(r.Header.Get("Save-Data")), "on") {
+		var hasQuality bool
+		for i, f := range p.Filters {
+			if f.Name == "quality" {
+				hasQuality = true
+				if q, err := strconv.Atoi(f.Args); err != nil || q > saveDataQuality {
+					p.Filters[i].Args = strconv.Itoa(saveDataQuality)
+					isChanged = true
+				}
+			}
+		}
+		if !hasQuality {
+			p.Filters = append(p.Filters, imagorpath.Filter{
+				Name: "quality",
+				Args: strconv.Itoa(saveDataQuality),
+			})
+			isChanged = true
+		}
+	}
+	return p, vary, dpr, isChanged
+}
+
+// snapClientHintSize snaps size derived from client hints to allowed sizes policy
+// without redirect, preserving negative size for flip
+func (app *Imagor) snapClientHintSize(v int) int {
+	if v < 0 {
+		return -app.snapSize(-v)
+	}
+	return app.snapSize(v)
+}
+
+func parseDPR(s string) float64 {
+	dpr, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || dpr <= 0 || math.IsNaN(dpr) {
+		return 0
+	}
+	// round to 2 decimal places to limit result variations
+	return math.Round(math.Min(dpr, maxDPR)*100) / 100
+}
+
+func parseClientHintInt(s string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
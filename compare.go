@@ -0,0 +1,98 @@
+package imagor
+
+import (
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xudaolong/imagor/imagorpath"
+)
+
+// compareResult /compare endpoint response
+type compareResult struct {
+	Hash     string `json:"hash"`
+	A        string `json:"a"`
+	B        string `json:"b"`
+	Distance int    `json:"distance"`
+}
+
+// serveCompare handles /compare?a=PATH&b=PATH&hash=phash|dhash|ahash endpoint,
+// computing the perceptual hashes of two imagor endpoints and their hamming distance
+func (app *Imagor) serveCompare(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	algo := query.Get("hash")
+	switch algo {
+	case "":
+		algo = "phash"
+	case "phash", "dhash", "ahash":
+	default:
+		app.writeError(w, r, ErrInvalid)
+		return
+	}
+	paths := [2]string{query.Get("a"), query.Get("b")}
+	if paths[0] == "" || paths[1] == "" {
+		app.writeError(w, r, ErrInvalid)
+		return
+	}
+	var hashes [2]uint64
+	var errs [2]error
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			hashes[i], errs[i] = app.imageHash(r, path, algo)
+		}(i, path)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			app.writeError(w, r, err)
+			return
+		}
+	}
+	writeJSON(w, r, compareResult{
+		Hash:     algo,
+		A:        formatImageHash(hashes[0]),
+		B:        formatImageHash(hashes[1]),
+		Distance: bits.OnesCount64(hashes[0] ^ hashes[1]),
+	})
+}
+
+// imageHash processes imagor endpoint path with format(algo) filter
+// and returns the resulting perceptual hash
+func (app *Imagor) imageHash(r *http.Request, path, algo string) (uint64, error) {
+	p := imagorpath.Parse("/" + strings.TrimPrefix(path, "/"))
+	if err := app.checkSignature(p); err != nil {
+		return 0, err
+	}
+	var filters imagorpath.Filters
+	for _, f := range p.Filters {
+		if f.Name != "format" {
+			filters = append(filters, f)
+		}
+	}
+	p.Filters = append(filters, imagorpath.Filter{Name: "format", Args: algo})
+	p.Meta = false
+	p.Path = "" // signature checked, regenerate path for result key
+	blob, err := checkBlob(app.Do(r.Clone(r.Context()), p))
+	if err != nil {
+		return 0, err
+	}
+	buf, err := blob.ReadAll()
+	if err != nil {
+		return 0, err
+	}
+	hash, err := strconv.ParseUint(strings.TrimSpace(string(buf)), 16, 64)
+	if err != nil {
+		return 0, ErrUnsupportedFormat
+	}
+	return hash, nil
+}
+
+func formatImageHash(hash uint64) string {
+	s := strconv.FormatUint(hash, 16)
+	return strings.Repeat("0", 16-len(s)) + s
+}
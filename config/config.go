@@ -7,12 +7,14 @@ import (
 	"flag"
 	"fmt"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/xudaolong/imagor/metrics/prometheusmetrics"
 
 	"github.com/peterbourgon/ff/v3"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/xudaolong/imagor"
 	"github.com/xudaolong/imagor/imagorpath"
 	"github.com/xudaolong/imagor/server"
@@ -49,6 +51,20 @@ func NewImagor(
 			"URL to redirect for imagor / base path e.g. https://www.google.com")
 		imagorBaseParams = fs.String("imagor-base-params", "",
 			"imagor endpoint base params that applies to all resulting images e.g. filters:watermark(example.jpg)")
+		imagorPresets = fs.String("imagor-presets", "",
+			"imagor named presets in semicolon separated name=params e.g. thumbnail=fit-in/200x200/filters:format(webp);cover=800x400/smart")
+		imagorUnsafePresetsOnly = fs.Bool("imagor-unsafe-presets-only", false,
+			"imagor restrict unsafe unsigned requests to presets only")
+		imagorAllowedSizes = fs.String("imagor-allowed-sizes", "",
+			"imagor policy allowed image width and height in csv e.g. 320,640,1280. 0 is always allowed")
+		imagorSizeStep = fs.Int("imagor-size-step", 0,
+			"imagor policy rounding image width and height to the nearest multiple of step")
+		imagorAllowedFilters = fs.String("imagor-allowed-filters", "",
+			"imagor policy allowed filters in colon separated filters with optional argument ranges e.g. quality(10-95):blur(0-5):format")
+		imagorPolicySnap = fs.Bool("imagor-policy-snap", false,
+			"imagor policy snap to the nearest allowed values and redirect to the canonical URL, instead of rejecting with HTTP status 400")
+		imagorClientHints = fs.Bool("imagor-client-hints", false,
+			"imagor enable client hints Sec-CH-DPR, Sec-CH-Width, Sec-CH-Viewport-Width, Save-Data and dpr() filter")
 		imagorProcessConcurrency = fs.Int64("imagor-process-concurrency",
 			-1, "Maximum number of image process to be executed simultaneously. Requests that exceed this limit are put in the queue. Set -1 for no limit")
 		imagorProcessQueueSize = fs.Int64("imagor-process-queue-size",
@@ -63,6 +79,9 @@ func NewImagor(
 			"Check modified time of result image against the source image. This eliminates stale result but require more lookups")
 		imagorDisableErrorBody       = fs.Bool("imagor-disable-error-body", false, "imagor disable response body on error")
 		imagorDisableParamsEndpoint  = fs.Bool("imagor-disable-params-endpoint", false, "imagor disable /params endpoint")
+		imagorDisableCompareEndpoint = fs.Bool("imagor-disable-compare-endpoint", false, "imagor disable /compare perceptual hash endpoint")
+		imagorDisableSVGSanitize     = fs.Bool("imagor-disable-svg-sanitize", false, "imagor disable sanitization of scripts and external references for raw and passthrough SVG")
+		imagorSVGAllowedSources      = fs.String("imagor-svg-allowed-sources", "", "imagor SVG sanitization allowed external reference hosts in csv with glob pattern e.g. *.google.com,*.github.com")
 		imagorSignerType             = fs.String("imagor-signer-type", "sha1", "imagor URL signature hasher type: sha1, sha256, sha512")
 		imagorSignerTruncate         = fs.Int("imagor-signer-truncate", 0, "imagor URL signature truncate at length")
 		imagorStoragePathStyle       = fs.String("imagor-storage-path-style", "original", "imagor storage path style: original, digest")
@@ -100,6 +119,12 @@ func NewImagor(
 		)),
 		imagor.WithBasePathRedirect(*imagorBasePathRedirect),
 		imagor.WithBaseParams(*imagorBaseParams),
+		imagor.WithPresets(parsePresets(*imagorPresets)),
+		imagor.WithUnsafePresetsOnly(*imagorUnsafePresetsOnly),
+		imagor.WithAllowedSizes(parseSizes(*imagorAllowedSizes)...),
+		imagor.WithSizeStep(*imagorSizeStep),
+		imagor.WithAllowedFilters(*imagorAllowedFilters),
+		imagor.WithPolicySnap(*imagorPolicySnap),
 		imagor.WithRequestTimeout(*imagorRequestTimeout),
 		imagor.WithLoadTimeout(*imagorLoadTimeout),
 		imagor.WithSaveTimeout(*imagorSaveTimeout),
@@ -111,9 +136,13 @@ func NewImagor(
 		imagor.WithCacheHeaderNoCache(*imagorCacheHeaderNoCache),
 		imagor.WithAutoWebP(*imagorAutoWebP),
 		imagor.WithAutoAVIF(*imagorAutoAVIF),
+		imagor.WithClientHints(*imagorClientHints),
 		imagor.WithModifiedTimeCheck(*imagorModifiedTimeCheck),
 		imagor.WithDisableErrorBody(*imagorDisableErrorBody),
 		imagor.WithDisableParamsEndpoint(*imagorDisableParamsEndpoint),
+		imagor.WithDisableCompareEndpoint(*imagorDisableCompareEndpoint),
+		imagor.WithDisableSVGSanitize(*imagorDisableSVGSanitize),
+		imagor.WithSVGAllowedSources(*imagorSVGAllowedSources),
 		imagor.WithStoragePathStyle(hasher),
 		imagor.WithResultStoragePathStyle(resultHasher),
 		imagor.WithUnsafe(*imagorUnsafe),
@@ -122,6 +151,17 @@ func NewImagor(
 	)...)
 }
 
+// parsePresets parses semicolon separated name=params presets
+func parsePresets(str string) map[string]string {
+	var presets = map[string]string{}
+	for _, preset := range strings.Split(str, ";") {
+		if name, params, ok := strings.Cut(preset, "="); ok {
+			presets[strings.TrimSpace(name)] = strings.TrimSpace(params)
+		}
+	}
+	return presets
+}
+
 // CreateServer create server from config flags. Returns nil on version or help command
 func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 	var (
@@ -185,10 +225,17 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 
 	var pm *prometheusmetrics.PrometheusMetrics
 	if *prometheusBind != "" {
+		var collectors []prometheus.Collector
+		for _, processor := range app.Processors {
+			if collector, ok := processor.(prometheus.Collector); ok {
+				collectors = append(collectors, collector)
+			}
+		}
 		pm = prometheusmetrics.New(
 			prometheusmetrics.WithAddr(*prometheusBind),
 			prometheusmetrics.WithPath(*prometheusPath),
 			prometheusmetrics.WithLogger(logger),
+			prometheusmetrics.WithCollectors(collectors...),
 		)
 	}
 
@@ -205,3 +252,13 @@ func CreateServer(args []string, funcs ...Option) (srv *server.Server) {
 		server.WithMetrics(pm),
 	)
 }
+
+// parseSizes parses csv sizes
+func parseSizes(str string) (sizes []int) {
+	for _, s := range strings.Split(str, ",") {
+		if size, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+			sizes = append(sizes, size)
+		}
+	}
+	return
+}
@@ -29,8 +29,18 @@ func TestDefault(t *testing.T) {
 	assert.False(t, app.ModifiedTimeCheck)
 	assert.False(t, app.AutoWebP)
 	assert.False(t, app.AutoAVIF)
+	assert.False(t, app.ClientHints)
 	assert.False(t, app.DisableErrorBody)
 	assert.False(t, app.DisableParamsEndpoint)
+	assert.False(t, app.DisableCompareEndpoint)
+	assert.False(t, app.DisableSVGSanitize)
+	assert.Empty(t, app.Presets)
+	assert.False(t, app.UnsafePresetsOnly)
+	assert.Empty(t, app.SVGAllowedSources)
+	assert.Empty(t, app.AllowedSizes)
+	assert.Empty(t, app.SizeStep)
+	assert.Nil(t, app.AllowedFilters)
+	assert.False(t, app.PolicySnap)
 	assert.Equal(t, time.Hour*24*7, app.CacheHeaderTTL)
 	assert.Equal(t, time.Hour*24, app.CacheHeaderSWR)
 	assert.Empty(t, app.ResultStorages)
@@ -48,8 +58,12 @@ func TestBasic(t *testing.T) {
 		"-imagor-unsafe",
 		"-imagor-auto-webp",
 		"-imagor-auto-avif",
+		"-imagor-client-hints",
 		"-imagor-disable-error-body",
 		"-imagor-disable-params-endpoint",
+		"-imagor-disable-compare-endpoint",
+		"-imagor-disable-svg-sanitize",
+		"-imagor-svg-allowed-sources", "*.example.com, fonts.gstatic.com",
 		"-imagor-request-timeout", "16s",
 		"-imagor-load-timeout", "7s",
 		"-imagor-process-timeout", "19s",
@@ -57,6 +71,12 @@ func TestBasic(t *testing.T) {
 		"-imagor-process-queue-size", "1999",
 		"-imagor-base-path-redirect", "https://www.google.com",
 		"-imagor-base-params", "filters:watermark(example.jpg)",
+		"-imagor-presets", "thumbnail=fit-in/200x200/filters:format(webp); cover = 800x400/smart/",
+		"-imagor-unsafe-presets-only",
+		"-imagor-allowed-sizes", "640, 320,1280",
+		"-imagor-size-step", "50",
+		"-imagor-allowed-filters", "quality(10-95):blur(0-5):format",
+		"-imagor-policy-snap",
 		"-imagor-cache-header-ttl", "169h",
 		"-imagor-cache-header-swr", "167h",
 		"-http-loader-insecure-skip-verify-transport",
@@ -70,8 +90,12 @@ func TestBasic(t *testing.T) {
 	assert.True(t, app.Debug)
 	assert.True(t, app.Unsafe)
 	assert.True(t, app.AutoWebP)
+	assert.True(t, app.ClientHints)
 	assert.True(t, app.DisableErrorBody)
 	assert.True(t, app.DisableParamsEndpoint)
+	assert.True(t, app.DisableCompareEndpoint)
+	assert.True(t, app.DisableSVGSanitize)
+	assert.Equal(t, []string{"*.example.com", "fonts.gstatic.com"}, app.SVGAllowedSources)
 	assert.Equal(t, "RrTsWGEXFU2s1J1mTl1j_ciO-1E=", app.Signer.Sign("bar"))
 	assert.Equal(t, time.Second*16, app.RequestTimeout)
 	assert.Equal(t, time.Second*7, app.LoadTimeout)
@@ -80,6 +104,19 @@ func TestBasic(t *testing.T) {
 	assert.Equal(t, int64(1999), app.ProcessQueueSize)
 	assert.Equal(t, "https://www.google.com", app.BasePathRedirect)
 	assert.Equal(t, "filters:watermark(example.jpg)/", app.BaseParams)
+	assert.Equal(t, map[string]string{
+		"thumbnail": "fit-in/200x200/filters:format(webp)/",
+		"cover":     "800x400/smart/",
+	}, app.Presets)
+	assert.True(t, app.UnsafePresetsOnly)
+	assert.Equal(t, []int{320, 640, 1280}, app.AllowedSizes)
+	assert.Equal(t, 50, app.SizeStep)
+	assert.Equal(t, imagorpath.Filters{
+		{Name: "quality", Args: "10-95"},
+		{Name: "blur", Args: "0-5"},
+		{Name: "format"},
+	}, app.AllowedFilters)
+	assert.True(t, app.PolicySnap)
 	assert.Equal(t, time.Hour*169, app.CacheHeaderTTL)
 	assert.Equal(t, time.Hour*167, app.CacheHeaderSWR)
 
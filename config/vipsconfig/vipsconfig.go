@@ -34,6 +34,28 @@ func WithVips(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			"VIPS max image resolution")
 		vipsMozJPEG = fs.Bool("vips-mozjpeg", false,
 			"VIPS enable maximum compression with MozJPEG. Requires mozjpeg to be installed")
+		vipsAutoFormatBudget = fs.Duration("vips-auto-format-budget", 0,
+			"VIPS time budget for encoding candidate formats of format(auto) filter, by estimated encoding durations (default 1s)")
+		vipsMetadataKeep = fs.String("vips-metadata-keep", "",
+			"VIPS export metadata allowlist by csv e.g. exif:Copyright,exif:Artist,xmp:rights,iptc:*")
+		vipsMetadataStripGPS = fs.Bool("vips-metadata-strip-gps", false,
+			"VIPS strip GPS metadata on export")
+		vipsMetadataCopyright = fs.String("vips-metadata-copyright", "",
+			"VIPS copyright metadata injected on export")
+		vipsMetadataArtist = fs.String("vips-metadata-artist", "",
+			"VIPS artist metadata injected on export")
+		vipsMetadataURL = fs.String("vips-metadata-url", "",
+			"VIPS license URL metadata injected on export")
+		vipsICCProfilesDir = fs.String("vips-icc-profiles-dir", "",
+			"VIPS directory of named ICC profiles for icc(name) filter")
+		vipsFontsDir = fs.String("vips-fonts-dir", "",
+			"VIPS directory of TTF/OTF font files for label fontfile")
+		vipsKernel = fs.String("vips-kernel", "",
+			"VIPS default resampling kernel of resize: nearest, linear, cubic, mitchell, lanczos2 or lanczos3 (default lanczos3)")
+		vipsAutoSharpen = fs.Float64("vips-auto-sharpen", 0,
+			"VIPS sigma of unsharp mask applied after large downscale. Set 0 to disable")
+		vipsAutoSharpenRatio = fs.Float64("vips-auto-sharpen-ratio", 2,
+			"VIPS minimum downscale ratio of source to output longer side for auto sharpen")
 
 		logger, isDebug = cb()
 	)
@@ -51,6 +73,17 @@ func WithVips(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 			vips.WithMaxHeight(*vipsMaxHeight),
 			vips.WithMaxResolution(*vipsMaxResolution),
 			vips.WithMozJPEG(*vipsMozJPEG),
+			vips.WithAutoFormatBudget(*vipsAutoFormatBudget),
+			vips.WithMetadataKeep(*vipsMetadataKeep),
+			vips.WithMetadataStripGPS(*vipsMetadataStripGPS),
+			vips.WithMetadataCopyright(*vipsMetadataCopyright),
+			vips.WithMetadataArtist(*vipsMetadataArtist),
+			vips.WithMetadataURL(*vipsMetadataURL),
+			vips.WithICCProfilesDir(*vipsICCProfilesDir),
+			vips.WithFontsDir(*vipsFontsDir),
+			vips.WithKernel(*vipsKernel),
+			vips.WithAutoSharpen(*vipsAutoSharpen),
+			vips.WithAutoSharpenRatio(*vipsAutoSharpenRatio),
 			vips.WithLogger(logger),
 			vips.WithDebug(isDebug),
 		),
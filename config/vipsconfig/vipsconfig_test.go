@@ -6,15 +6,34 @@ import (
 	"github.com/xudaolong/imagor/config"
 	"github.com/xudaolong/imagor/vips"
 	"testing"
+	"time"
 )
 
 func TestWithVips(t *testing.T) {
 	srv := config.CreateServer([]string{
 		"-vips-max-animation-frames", "167",
 		"-vips-disable-filters", "blur,watermark,rgb",
+		"-vips-auto-format-budget", "3s",
+		"-vips-metadata-keep", "exif:Copyright,exif:Artist",
+		"-vips-metadata-strip-gps",
+		"-vips-metadata-copyright", "(c) Acme",
+		"-vips-icc-profiles-dir", "/etc/imagor/icc",
+		"-vips-fonts-dir", "/etc/imagor/fonts",
+		"-vips-kernel", "mitchell",
+		"-vips-auto-sharpen", "0.6",
+		"-vips-auto-sharpen-ratio", "2.5",
 	}, WithVips)
 	app := srv.App.(*imagor.Imagor)
 	processor := app.Processors[0].(*vips.Processor)
 	assert.Equal(t, 167, processor.MaxAnimationFrames)
 	assert.Equal(t, []string{"blur", "watermark", "rgb"}, processor.DisableFilters)
+	assert.Equal(t, time.Second*3, processor.AutoFormatBudget)
+	assert.Equal(t, []string{"exif:Copyright", "exif:Artist"}, processor.MetadataKeep)
+	assert.True(t, processor.MetadataStripGPS)
+	assert.Equal(t, "(c) Acme", processor.MetadataCopyright)
+	assert.Equal(t, "/etc/imagor/icc", processor.ICCProfilesDir)
+	assert.Equal(t, "/etc/imagor/fonts", processor.FontsDir)
+	assert.Equal(t, "mitchell", processor.Kernel)
+	assert.Equal(t, 0.6, processor.AutoSharpen)
+	assert.Equal(t, 2.5, processor.AutoSharpenRatio)
 }
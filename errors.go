@@ -33,6 +33,8 @@ var (
 	ErrMaxSizeExceeded = NewError("maximum size exceeded", http.StatusBadRequest)
 	// ErrMaxResolutionExceeded maximum resolution exceeded error
 	ErrMaxResolutionExceeded = NewError("maximum resolution exceeded", http.StatusUnprocessableEntity)
+	// ErrPolicyViolation allowed sizes or allowed filters policy violation error
+	ErrPolicyViolation = NewError("policy violation", http.StatusBadRequest)
 	// ErrTooManyRequests too many requests error
 	ErrTooManyRequests = NewError("too many requests", http.StatusTooManyRequests)
 	// ErrInternal internal error
@@ -53,6 +55,16 @@ func (p ErrForward) Error() string {
 	return fmt.Sprintf("%s forward %s", errPrefix, imagorpath.GeneratePath(p.Params))
 }
 
+// ErrRedirect indicator redirecting request to the canonical imagor path
+type ErrRedirect struct {
+	Path string
+}
+
+// Error implements error
+func (e ErrRedirect) Error() string {
+	return fmt.Sprintf("%s redirect %s", errPrefix, e.Path)
+}
+
 // Error imagor error convention
 type Error struct {
 	Message string `json:"message,omitempty"`
@@ -95,6 +107,9 @@ func WrapError(err error) Error {
 		// ErrForward till the end means no supported processor
 		return ErrUnsupportedFormat
 	}
+	if _, ok := err.(ErrRedirect); ok {
+		return NewErrorFromStatusCode(http.StatusFound)
+	}
 	if e, ok := err.(timeoutErr); ok {
 		if e.Timeout() {
 			return ErrTimeout
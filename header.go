@@ -0,0 +1,601 @@
+package imagor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ImageHeader image dimensions and number of pages decoded from Blob header,
+// zero values if not determined
+type ImageHeader struct {
+	Width  int
+	Height int
+	Pages  int
+
+	// Entities expanded size in bytes of XML entity references, for SVG
+	Entities int64
+}
+
+const (
+	// maxHeaderSize max bytes read for the header, pages counted up to the limit
+	maxHeaderSize = int64(16 << 20)
+
+	maxBoxDepth  = 8
+	maxTIFFPages = 1 << 16
+	pdfChunkSize = 32 << 10
+	pdfOverlap   = 128
+)
+
+var (
+	pdfCountRegex    = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfMediaBoxRegex = regexp.MustCompile(`/MediaBox\s*\[\s*(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s+(-?[\d.]+)\s*\]`)
+	svgRootRegex     = regexp.MustCompile(`(?s)<svg\b[^>]*>`)
+	svgEntityRegex   = regexp.MustCompile(`<!ENTITY\s+([^\s%]+)\s+(?:"([^"]*)"|'([^']*)')`)
+	svgEntityRef     = regexp.MustCompile(`&([A-Za-z_:][\w.:-]*);`)
+	svgWidthRegex    = regexp.MustCompile(`\swidth\s*=\s*["']([^"']*)["']`)
+	svgHeightRegex   = regexp.MustCompile(`\sheight\s*=\s*["']([^"']*)["']`)
+	svgViewBoxRegex  = regexp.MustCompile(`\sviewBox\s*=\s*["']([^"']*)["']`)
+)
+
+// DecodeHeader decodes image dimensions and number of pages from the Blob header,
+// without loading the image. Useful for rejecting image bombs before decoding
+func (b *Blob) DecodeHeader() (*ImageHeader, error) {
+	b.init()
+	b.headerOnce.Do(func() {
+		var (
+			r   io.ReadCloser
+			err error
+		)
+		if b.blobType == BlobTypeTIFF {
+			// tiff IFD can be located anywhere in the file
+			r, _, err = b.NewReadSeeker()
+		} else {
+			r, _, err = b.NewReader()
+		}
+		if err != nil {
+			b.headerErr = err
+			return
+		}
+		defer func() {
+			_ = r.Close()
+		}()
+		b.header = decodeHeader(r, b.blobType)
+	})
+	return b.header, b.headerErr
+}
+
+func decodeHeader(r io.Reader, typ BlobType) *ImageHeader {
+	var h = &ImageHeader{}
+	switch typ {
+	case BlobTypeJPEG:
+		decodeJPEGHeader(bufio.NewReader(io.LimitReader(r, maxHeaderSize)), h)
+	case BlobTypePNG:
+		decodePNGHeader(r, h)
+	case BlobTypeGIF:
+		decodeGIFHeader(bufio.NewReader(io.LimitReader(r, maxHeaderSize)), h)
+	case BlobTypeWEBP:
+		decodeWEBPHeader(bufio.NewReader(io.LimitReader(r, maxHeaderSize)), h)
+	case BlobTypeAVIF, BlobTypeHEIF, BlobTypeJP2:
+		decodeBoxes(bufio.NewReader(io.LimitReader(r, maxHeaderSize)), h, 0)
+	case BlobTypeTIFF:
+		if rs, ok := r.(io.ReadSeeker); ok {
+			decodeTIFFHeader(rs, h)
+		}
+	case BlobTypeBMP:
+		decodeBMPHeader(r, h)
+	case BlobTypePDF:
+		decodePDFHeader(io.LimitReader(r, maxHeaderSize), h)
+	case BlobTypeSVG:
+		if buf, err := io.ReadAll(io.LimitReader(r, maxMemorySize)); err == nil {
+			decodeSVGHeader(buf, h)
+		}
+	}
+	if h.Width <= 0 || h.Height <= 0 {
+		h.Width = 0
+		h.Height = 0
+	}
+	return h
+}
+
+func decodeJPEGHeader(r *bufio.Reader, h *ImageHeader) {
+	var buf [5]byte
+	if _, err := r.Discard(2); err != nil {
+		return
+	}
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		if c != 0xFF {
+			continue
+		}
+		marker, err := r.ReadByte()
+		for err == nil && marker == 0xFF {
+			// fill bytes
+			marker, err = r.ReadByte()
+		}
+		if err != nil || marker == 0xD9 || marker == 0xDA {
+			// start of scan comes after frame header
+			return
+		}
+		if marker == 0x00 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD8) {
+			// standalone markers without length
+			continue
+		}
+		if _, err = io.ReadFull(r, buf[:2]); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(buf[:2])) - 2
+		if length < 0 {
+			return
+		}
+		if marker >= 0xC0 && marker <= 0xCF &&
+			marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			// start of frame
+			if length < 5 {
+				return
+			}
+			if _, err = io.ReadFull(r, buf[:5]); err != nil {
+				return
+			}
+			h.Height = int(binary.BigEndian.Uint16(buf[1:3]))
+			h.Width = int(binary.BigEndian.Uint16(buf[3:5]))
+			h.Pages = 1
+			return
+		}
+		if _, err = r.Discard(length); err != nil {
+			return
+		}
+	}
+}
+
+func decodePNGHeader(r io.Reader, h *ImageHeader) {
+	var buf [24]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return
+	}
+	if string(buf[12:16]) != "IHDR" {
+		return
+	}
+	h.Width = toInt(int64(binary.BigEndian.Uint32(buf[16:20])))
+	h.Height = toInt(int64(binary.BigEndian.Uint32(buf[20:24])))
+	h.Pages = 1
+}
+
+func decodeGIFHeader(r *bufio.Reader, h *ImageHeader) {
+	var buf [13]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return
+	}
+	h.Width = int(binary.LittleEndian.Uint16(buf[6:8]))
+	h.Height = int(binary.LittleEndian.Uint16(buf[8:10]))
+	if buf[10]&0x80 != 0 {
+		// global color table
+		if _, err := r.Discard(3 << ((buf[10] & 0x07) + 1)); err != nil {
+			return
+		}
+	}
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch c {
+		case 0x2C:
+			// image descriptor
+			if _, err = io.ReadFull(r, buf[:9]); err != nil {
+				return
+			}
+			h.Pages++
+			if buf[8]&0x80 != 0 {
+				// local color table
+				if _, err = r.Discard(3 << ((buf[8] & 0x07) + 1)); err != nil {
+					return
+				}
+			}
+			// lzw minimum code size
+			if _, err = r.Discard(1); err != nil {
+				return
+			}
+		case 0x21:
+			// extension label
+			if _, err = r.Discard(1); err != nil {
+				return
+			}
+		default:
+			// trailer or invalid block
+			return
+		}
+		if !skipGIFSubBlocks(r) {
+			return
+		}
+	}
+}
+
+func skipGIFSubBlocks(r *bufio.Reader) bool {
+	for {
+		n, err := r.ReadByte()
+		if err != nil {
+			return false
+		}
+		if n == 0 {
+			return true
+		}
+		if _, err = r.Discard(int(n)); err != nil {
+			return false
+		}
+	}
+}
+
+func decodeWEBPHeader(r *bufio.Reader, h *ImageHeader) {
+	var buf [10]byte
+	if _, err := r.Discard(12); err != nil {
+		return
+	}
+	var (
+		canvas bool
+		frames int
+	)
+	for {
+		if _, err := io.ReadFull(r, buf[:8]); err != nil {
+			return
+		}
+		fourCC := string(buf[:4])
+		size := int64(binary.LittleEndian.Uint32(buf[4:8]))
+		size += size & 1
+		var n int64
+		switch fourCC {
+		case "VP8X":
+			if _, err := io.ReadFull(r, buf[:10]); err != nil {
+				return
+			}
+			n = 10
+			h.Width = int(uint32(buf[4])|uint32(buf[5])<<8|uint32(buf[6])<<16) + 1
+			h.Height = int(uint32(buf[7])|uint32(buf[8])<<8|uint32(buf[9])<<16) + 1
+			h.Pages = 1
+			canvas = true
+		case "ANMF":
+			frames++
+			h.Pages = frames
+		case "VP8 ":
+			if canvas {
+				break
+			}
+			if _, err := io.ReadFull(r, buf[:10]); err != nil {
+				return
+			}
+			if buf[3] != 0x9D || buf[4] != 0x01 || buf[5] != 0x2A {
+				return
+			}
+			h.Width = int(binary.LittleEndian.Uint16(buf[6:8]) & 0x3FFF)
+			h.Height = int(binary.LittleEndian.Uint16(buf[8:10]) & 0x3FFF)
+			h.Pages = 1
+			return
+		case "VP8L":
+			if canvas {
+				break
+			}
+			if _, err := io.ReadFull(r, buf[:5]); err != nil {
+				return
+			}
+			if buf[0] != 0x2F {
+				return
+			}
+			bits := binary.LittleEndian.Uint32(buf[1:5])
+			h.Width = int(bits&0x3FFF) + 1
+			h.Height = int((bits>>14)&0x3FFF) + 1
+			h.Pages = 1
+			return
+		}
+		if size < n {
+			return
+		}
+		if _, err := io.CopyN(io.Discard, r, size-n); err != nil {
+			return
+		}
+	}
+}
+
+// decodeBoxes decodes ISO base media file format boxes for AVIF, HEIF and JPEG 2000
+func decodeBoxes(r io.Reader, h *ImageHeader, depth int) {
+	var buf [16]byte
+	for {
+		if _, err := io.ReadFull(r, buf[:8]); err != nil {
+			return
+		}
+		size := int64(binary.BigEndian.Uint32(buf[:4]))
+		typ := string(buf[4:8])
+		hdr := int64(8)
+		if size == 1 {
+			if _, err := io.ReadFull(r, buf[:8]); err != nil {
+				return
+			}
+			size = int64(binary.BigEndian.Uint64(buf[:8]))
+			hdr = 16
+		}
+		var body io.Reader = r
+		if size != 0 {
+			if size < hdr {
+				return
+			}
+			body = io.LimitReader(r, size-hdr)
+		}
+		switch typ {
+		case "meta":
+			// full box
+			if _, err := io.ReadFull(body, buf[:4]); err != nil {
+				return
+			}
+			fallthrough
+		case "iprp", "ipco", "moov", "trak", "mdia", "minf", "stbl", "jp2h":
+			if depth < maxBoxDepth {
+				decodeBoxes(body, h, depth+1)
+			}
+		case "ispe":
+			if _, err := io.ReadFull(body, buf[:12]); err != nil {
+				return
+			}
+			h.Width = max(h.Width, toInt(int64(binary.BigEndian.Uint32(buf[4:8]))))
+			h.Height = max(h.Height, toInt(int64(binary.BigEndian.Uint32(buf[8:12]))))
+			h.Pages = max(h.Pages, 1)
+		case "ihdr":
+			if _, err := io.ReadFull(body, buf[:8]); err != nil {
+				return
+			}
+			h.Height = toInt(int64(binary.BigEndian.Uint32(buf[:4])))
+			h.Width = toInt(int64(binary.BigEndian.Uint32(buf[4:8])))
+			h.Pages = max(h.Pages, 1)
+		case "stsz":
+			if _, err := io.ReadFull(body, buf[:12]); err != nil {
+				return
+			}
+			h.Pages = max(h.Pages, toInt(int64(binary.BigEndian.Uint32(buf[8:12]))))
+		}
+		if size == 0 {
+			// box extends to end of file
+			return
+		}
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			return
+		}
+	}
+}
+
+func decodeTIFFHeader(r io.ReadSeeker, h *ImageHeader) {
+	var buf [12]byte
+	if _, err := io.ReadFull(r, buf[:8]); err != nil {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(buf[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+	if order.Uint16(buf[2:4]) != 42 {
+		return
+	}
+	var (
+		offset  = int64(order.Uint32(buf[4:8]))
+		visited = map[int64]bool{}
+	)
+	for offset > 0 && !visited[offset] && len(visited) < maxTIFFPages {
+		visited[offset] = true
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(r, buf[:2]); err != nil {
+			return
+		}
+		entries := make([]byte, int(order.Uint16(buf[:2]))*12)
+		if _, err := io.ReadFull(r, entries); err != nil {
+			return
+		}
+		if h.Pages == 0 {
+			for i := 0; i+12 <= len(entries); i += 12 {
+				entry := entries[i : i+12]
+				var value int
+				switch order.Uint16(entry[2:4]) {
+				case 3:
+					// short
+					value = int(order.Uint16(entry[8:10]))
+				case 4:
+					// long
+					value = toInt(int64(order.Uint32(entry[8:12])))
+				default:
+					continue
+				}
+				switch order.Uint16(entry[:2]) {
+				case 256:
+					h.Width = value
+				case 257:
+					h.Height = value
+				}
+			}
+		}
+		h.Pages++
+		if _, err := io.ReadFull(r, buf[:4]); err != nil {
+			return
+		}
+		offset = int64(order.Uint32(buf[:4]))
+	}
+}
+
+func decodeBMPHeader(r io.Reader, h *ImageHeader) {
+	var buf [26]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return
+	}
+	if binary.LittleEndian.Uint32(buf[14:18]) == 12 {
+		// OS/2 bitmap core header
+		h.Width = int(binary.LittleEndian.Uint16(buf[18:20]))
+		h.Height = int(binary.LittleEndian.Uint16(buf[20:22]))
+	} else {
+		h.Width = abs(int(int32(binary.LittleEndian.Uint32(buf[18:22]))))
+		h.Height = abs(int(int32(binary.LittleEndian.Uint32(buf[22:26]))))
+	}
+	h.Pages = 1
+}
+
+func decodePDFHeader(r io.Reader, h *ImageHeader) {
+	var (
+		buf = make([]byte, pdfChunkSize+pdfOverlap)
+		n   int
+	)
+	for {
+		m, err := io.ReadFull(r, buf[n:])
+		chunk := buf[:n+m]
+		for _, match := range pdfCountRegex.FindAllSubmatch(chunk, -1) {
+			if count, err := strconv.Atoi(string(match[1])); err == nil {
+				h.Pages = max(h.Pages, count)
+			}
+		}
+		for _, match := range pdfMediaBoxRegex.FindAllSubmatch(chunk, -1) {
+			var box [4]float64
+			for i := range box {
+				box[i], _ = strconv.ParseFloat(string(match[i+1]), 64)
+			}
+			h.Width = max(h.Width, toIntCeil(math.Abs(box[2]-box[0])))
+			h.Height = max(h.Height, toIntCeil(math.Abs(box[3]-box[1])))
+		}
+		if err != nil {
+			return
+		}
+		// keep tail of the chunk for matches across chunk boundary
+		n = copy(buf, chunk[len(chunk)-pdfOverlap:])
+	}
+}
+
+func decodeSVGHeader(buf []byte, h *ImageHeader) {
+	buf = svgComment.ReplaceAll(buf, nil)
+	loc := svgRootRegex.FindIndex(buf)
+	if loc == nil {
+		return
+	}
+	prolog, root, body := buf[:loc[0]], buf[loc[0]:loc[1]], buf[loc[0]:]
+
+	// entity expansion
+	var (
+		entities = map[string]string{}
+		sizes    = map[string]int64{}
+	)
+	for _, match := range svgEntityRegex.FindAllSubmatch(prolog, -1) {
+		if _, ok := entities[string(match[1])]; !ok {
+			entities[string(match[1])] = string(match[2]) + string(match[3])
+		}
+	}
+	if len(entities) > 0 {
+		var expand func(name string) int64
+		expand = func(name string) int64 {
+			if size, ok := sizes[name]; ok {
+				return size
+			}
+			// recursive entity reference expands infinitely
+			sizes[name] = math.MaxInt64
+			value := entities[name]
+			size := int64(len(value))
+			for _, ref := range svgEntityRef.FindAllStringSubmatch(value, -1) {
+				if _, ok := entities[ref[1]]; ok {
+					size = addSize(size-int64(len(ref[0])), expand(ref[1]))
+				}
+			}
+			sizes[name] = size
+			return size
+		}
+		for _, ref := range svgEntityRef.FindAllSubmatch(body, -1) {
+			if _, ok := entities[string(ref[1])]; ok {
+				h.Entities = addSize(h.Entities, expand(string(ref[1])))
+			}
+		}
+	}
+
+	// dimensions
+	var (
+		width, height     float64
+		vbWidth, vbHeight float64
+	)
+	if match := svgWidthRegex.FindSubmatch(root); match != nil {
+		width = parseSVGLength(string(match[1]))
+	}
+	if match := svgHeightRegex.FindSubmatch(root); match != nil {
+		height = parseSVGLength(string(match[1]))
+	}
+	if match := svgViewBoxRegex.FindSubmatch(root); match != nil {
+		fields := strings.FieldsFunc(string(match[1]), func(r rune) bool {
+			return r == ' ' || r == ',' || r == '\t' || r == '\n' || r == '\r'
+		})
+		if len(fields) == 4 {
+			vbWidth, _ = strconv.ParseFloat(fields[2], 64)
+			vbHeight, _ = strconv.ParseFloat(fields[3], 64)
+		}
+	}
+	if vbWidth > 0 && vbHeight > 0 {
+		if width <= 0 && height <= 0 {
+			width, height = vbWidth, vbHeight
+		} else if width <= 0 {
+			width = height * vbWidth / vbHeight
+		} else if height <= 0 {
+			height = width * vbHeight / vbWidth
+		}
+	}
+	h.Width = toIntCeil(width)
+	h.Height = toIntCeil(height)
+	h.Pages = 1
+}
+
+// parseSVGLength parses SVG length in pixels at 72 dpi, returns 0 if relative
+func parseSVGLength(s string) float64 {
+	s = strings.TrimSpace(s)
+	var unit = 1.0
+	for suffix, u := range map[string]float64{
+		"px": 1, "pt": 1, "pc": 12, "in": 72, "cm": 72 / 2.54, "mm": 72 / 25.4,
+	} {
+		if strings.HasSuffix(s, suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, suffix))
+			unit = u
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return 0
+	}
+	return f * unit
+}
+
+func addSize(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+func toInt(n int64) int {
+	if n > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(n)
+}
+
+func toIntCeil(f float64) int {
+	if f >= math.MaxInt32 || math.IsNaN(f) {
+		return math.MaxInt32
+	}
+	return int(math.Ceil(f))
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
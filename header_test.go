@@ -0,0 +1,302 @@
+package imagor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	buf := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(buf, uint32(8+len(body)))
+	copy(buf[4:], typ)
+	return append(buf, body...)
+}
+
+func u32(vals ...uint32) []byte {
+	buf := make([]byte, 4*len(vals))
+	for i, v := range vals {
+		binary.BigEndian.PutUint32(buf[i*4:], v)
+	}
+	return buf
+}
+
+func riffChunk(fourCC string, payload []byte) []byte {
+	buf := make([]byte, 8, 8+len(payload)+1)
+	copy(buf, fourCC)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(payload)))
+	buf = append(buf, payload...)
+	if len(payload)%2 == 1 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func tiffIFD(offset, next uint32, width, height uint32) []byte {
+	buf := make([]byte, 0, 2+24+4)
+	buf = binary.LittleEndian.AppendUint16(buf, 2)
+	for tag, value := range []uint32{width, height} {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(256+tag))
+		buf = binary.LittleEndian.AppendUint16(buf, 4)
+		buf = binary.LittleEndian.AppendUint32(buf, 1)
+		buf = binary.LittleEndian.AppendUint32(buf, value)
+	}
+	return binary.LittleEndian.AppendUint32(buf, next)
+}
+
+func TestDecodeHeader(t *testing.T) {
+	tests := []struct {
+		path   string
+		header ImageHeader
+	}{
+		{path: "demo1.jpg", header: ImageHeader{Width: 200, Height: 200, Pages: 1}},
+		{path: "Canon_40D.jpg", header: ImageHeader{Width: 100, Height: 68, Pages: 1}},
+		{path: "gopher.png", header: ImageHeader{Width: 1634, Height: 2224, Pages: 1}},
+		{path: "dancing-banana.gif", header: ImageHeader{Width: 121, Height: 128, Pages: 8}},
+		{path: "nyan-cat.gif", header: ImageHeader{Width: 500, Height: 198, Pages: 12}},
+		{path: "demo3.webp", header: ImageHeader{Width: 70, Height: 87, Pages: 8}},
+		{path: "gopher-front.avif", header: ImageHeader{Width: 202, Height: 259, Pages: 1}},
+		{path: "gopher-front.heif", header: ImageHeader{Width: 202, Height: 260, Pages: 1}},
+		{path: "gopher.tiff", header: ImageHeader{Width: 200, Height: 100, Pages: 1}},
+		{path: "gopher.jp2", header: ImageHeader{Width: 147, Height: 200, Pages: 1}},
+		{path: "bmp_24.bmp", header: ImageHeader{Width: 200, Height: 200, Pages: 1}},
+		{path: "sample.pdf", header: ImageHeader{Width: 612, Height: 792, Pages: 2}},
+		{path: "test.svg", header: ImageHeader{Width: 620, Height: 472, Pages: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			b := NewBlobFromFile("testdata/" + tt.path)
+			h, err := b.DecodeHeader()
+			require.NoError(t, err)
+			assert.Equal(t, tt.header, *h)
+
+			buf, err := b.ReadAll()
+			require.NoError(t, err)
+			h, err = NewBlobFromBytes(buf).DecodeHeader()
+			require.NoError(t, err)
+			assert.Equal(t, tt.header, *h)
+		})
+	}
+}
+
+func TestDecodeHeaderHostile(t *testing.T) {
+	var animatedWebP = riffChunk("VP8X", []byte{0x02, 0, 0, 0, 0xFF, 0x3F, 0, 0xFF, 0x3F, 0})
+	for i := 0; i < 500; i++ {
+		animatedWebP = append(animatedWebP, riffChunk("ANMF", make([]byte, 17))...)
+	}
+	var manyFramesGIF = []byte("GIF89a\x10\x00\x10\x00\x00\x00\x00")
+	for i := 0; i < 10000; i++ {
+		manyFramesGIF = append(manyFramesGIF, "\x2C\x00\x00\x00\x00\x10\x00\x10\x00\x00\x02\x02\x44\x01\x00"...)
+	}
+	manyFramesGIF = append(manyFramesGIF, 0x3B)
+	tests := []struct {
+		name   string
+		buf    []byte
+		typ    BlobType
+		header ImageHeader
+	}{
+		{
+			name:   "jpeg sof",
+			buf:    []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00\xFF\xFF\xC2\x00\x11\x08\xFF\xFF\xFF\xFE\x03\x01\x22\x00\x02\x11\x01\x03\x11\x01\xFF\xDA"),
+			typ:    BlobTypeJPEG,
+			header: ImageHeader{Width: 65534, Height: 65535, Pages: 1},
+		},
+		{
+			name: "jpeg truncated",
+			buf:  []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00\x01\x01"),
+			typ:  BlobTypeJPEG,
+		},
+		{
+			name:   "png ihdr",
+			buf:    append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), u32(100000, 3000000000)...),
+			typ:    BlobTypePNG,
+			header: ImageHeader{Width: 100000, Height: 2147483647, Pages: 1},
+		},
+		{
+			name:   "gif screen",
+			buf:    []byte("GIF89a\xFF\xFF\xFF\xFF\x00\x00\x00\x3B"),
+			typ:    BlobTypeGIF,
+			header: ImageHeader{Width: 65535, Height: 65535},
+		},
+		{
+			name:   "gif frames",
+			buf:    manyFramesGIF,
+			typ:    BlobTypeGIF,
+			header: ImageHeader{Width: 16, Height: 16, Pages: 10000},
+		},
+		{
+			name:   "webp animated canvas",
+			buf:    append([]byte("RIFF\x00\x00\x00\x00WEBP"), animatedWebP...),
+			typ:    BlobTypeWEBP,
+			header: ImageHeader{Width: 16384, Height: 16384, Pages: 500},
+		},
+		{
+			name:   "webp lossless",
+			buf:    append([]byte("RIFF\x00\x00\x00\x00WEBP"), riffChunk("VP8L", []byte{0x2F, 0xFF, 0xFF, 0xFF, 0x0F})...),
+			typ:    BlobTypeWEBP,
+			header: ImageHeader{Width: 16384, Height: 16384, Pages: 1},
+		},
+		{
+			name: "avif ispe",
+			buf: append(box("ftyp", []byte("avifmif1")), box("meta", u32(0),
+				box("hdlr", make([]byte, 20)),
+				box("iprp", box("ipco",
+					box("ispe", u32(0, 256, 256)),
+					box("ispe", u32(0, 50000, 40000)),
+				)),
+			)...),
+			typ:    BlobTypeAVIF,
+			header: ImageHeader{Width: 50000, Height: 40000, Pages: 1},
+		},
+		{
+			name:   "avif truncated box",
+			buf:    append(box("ftyp", []byte("avifmif1")), u32(4096, 0x6d657461)...),
+			typ:    BlobTypeAVIF,
+			header: ImageHeader{},
+		},
+		{
+			name: "tiff cyclic ifd",
+			buf: bytes.Join([][]byte{
+				[]byte("II\x2A\x00\x08\x00\x00\x00"),
+				tiffIFD(8, 38, 70000, 70000),
+				tiffIFD(38, 8, 1, 1),
+			}, nil),
+			typ:    BlobTypeTIFF,
+			header: ImageHeader{Width: 70000, Height: 70000, Pages: 2},
+		},
+		{
+			name:   "pdf pages",
+			buf:    []byte("%PDF-1.4\n1 0 obj << /Type /Pages /Count 100000 /MediaBox [0 0 14400 14400] >> endobj\n%%EOF"),
+			typ:    BlobTypePDF,
+			header: ImageHeader{Width: 14400, Height: 14400, Pages: 100000},
+		},
+		{
+			name:   "svg size",
+			buf:    []byte(`<svg xmlns="http://www.w3.org/2000/svg" stroke-width="2" width="100in" height="50000"></svg>`),
+			typ:    BlobTypeSVG,
+			header: ImageHeader{Width: 7200, Height: 50000, Pages: 1},
+		},
+		{
+			name:   "svg viewbox",
+			buf:    []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="100%" viewBox="0 0 30000 20000"></svg>`),
+			typ:    BlobTypeSVG,
+			header: ImageHeader{Width: 30000, Height: 20000, Pages: 1},
+		},
+		{
+			name: "svg billion laughs",
+			buf: []byte(`<?xml version="1.0"?>
+<!DOCTYPE svg [
+<!ENTITY lol "lol">
+<!ENTITY lol1 "&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;">
+<!ENTITY lol2 "&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;">
+<!ENTITY lol3 "&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;&lol2;">
+<!ENTITY lol4 "&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;&lol3;">
+<!ENTITY lol5 "&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;&lol4;">
+<!ENTITY lol6 "&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;&lol5;">
+]>
+<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"><text>&lol6;</text></svg>`),
+			typ:    BlobTypeSVG,
+			header: ImageHeader{Width: 10, Height: 10, Pages: 1, Entities: 3000000},
+		},
+		{
+			name: "svg recursive entity",
+			buf: []byte(`<!DOCTYPE svg [<!ENTITY a "&b;"><!ENTITY b "&a;">]>
+<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"><text>&a;</text></svg>`),
+			typ:    BlobTypeSVG,
+			header: ImageHeader{Width: 10, Height: 10, Pages: 1, Entities: 9223372036854775807},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.header, *decodeHeader(bytes.NewReader(tt.buf), tt.typ))
+		})
+	}
+}
+
+// endlessReader reads prefix followed by chunk repeated endlessly, counting bytes read
+type endlessReader struct {
+	prefix, chunk []byte
+	off           int
+	n             int64
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	var n int
+	for n < len(p) {
+		if len(r.prefix) > 0 {
+			m := copy(p[n:], r.prefix)
+			r.prefix = r.prefix[m:]
+			n += m
+			continue
+		}
+		m := copy(p[n:], r.chunk[r.off:])
+		r.off = (r.off + m) % len(r.chunk)
+		n += m
+	}
+	r.n += int64(n)
+	return n, nil
+}
+
+func TestDecodeHeaderBounded(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		prefix, chunk []byte
+		typ           BlobType
+	}{
+		{"gif endless frames", []byte("GIF89a\x10\x00\x10\x00\x00\x00\x00"),
+			[]byte("\x2C\x00\x00\x00\x00\x10\x00\x10\x00\x00\x02\x02\x44\x01\x00"), BlobTypeGIF},
+		{"pdf endless", []byte("%PDF-1.4\n/MediaBox [0 0 100 100]\n"), []byte("0 0 obj\n"), BlobTypePDF},
+		{"jpeg endless segments", []byte("\xFF\xD8"), append([]byte("\xFF\xE1\x00\x10"), make([]byte, 14)...), BlobTypeJPEG},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &endlessReader{prefix: tt.prefix, chunk: tt.chunk}
+			h := decodeHeader(r, tt.typ)
+			assert.LessOrEqual(t, r.n, maxHeaderSize)
+			if tt.typ != BlobTypeJPEG {
+				assert.Positive(t, h.Width)
+			}
+		})
+	}
+}
+
+func TestDecodeHeaderUnknown(t *testing.T) {
+	h, err := NewBlobFromBytes([]byte(strings.Repeat("a", 1024))).DecodeHeader()
+	require.NoError(t, err)
+	assert.Equal(t, ImageHeader{}, *h)
+
+	h, err = NewEmptyBlob().DecodeHeader()
+	require.NoError(t, err)
+	assert.Equal(t, ImageHeader{}, *h)
+
+	h, err = NewBlobFromFile("testdata/nonexistent.jpg").DecodeHeader()
+	assert.Equal(t, ErrNotFound, err)
+	assert.Nil(t, h)
+}
+
+func FuzzDecodeHeader(f *testing.F) {
+	entries, err := os.ReadDir("testdata")
+	require.NoError(f, err)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		buf, err := os.ReadFile("testdata/" + entry.Name())
+		require.NoError(f, err)
+		f.Add(buf)
+	}
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		for typ := BlobTypeJPEG; typ <= BlobTypeSVG; typ++ {
+			h := decodeHeader(bytes.NewReader(buf), typ)
+			if h.Width < 0 || h.Height < 0 || h.Pages < 0 || h.Entities < 0 {
+				t.Errorf("invalid header %v for type %d", h, typ)
+			}
+		}
+		_, _ = NewBlobFromBytes(buf).DecodeHeader()
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,6 +22,9 @@ import (
 	"golang.org/x/sync/singleflight"
 )
 
+// maxPresets maximum number of presets expanded per request, including nested presets
+const maxPresets = 16
+
 // Version imagor version
 const Version = "1.4.13"
 
@@ -85,7 +89,17 @@ type Imagor struct {
 	ModifiedTimeCheck      bool
 	DisableErrorBody       bool
 	DisableParamsEndpoint  bool
+	DisableCompareEndpoint bool
+	DisableSVGSanitize     bool
+	SVGAllowedSources      []string
 	BaseParams             string
+	Presets                map[string]string
+	UnsafePresetsOnly      bool
+	AllowedSizes           []int
+	SizeStep               int
+	AllowedFilters         imagorpath.Filters
+	PolicySnap             bool
+	ClientHints            bool
 	Logger                 *zap.Logger
 	Debug                  bool
 
@@ -123,6 +137,10 @@ func New(options ...Option) *Imagor {
 	if app.BaseParams != "" {
 		app.BaseParams = strings.TrimSuffix(app.BaseParams, "/") + "/"
 	}
+	for name, params := range app.Presets {
+		app.Presets[name] = strings.TrimSuffix(strings.TrimSpace(params), "/") + "/"
+	}
+	sort.Ints(app.AllowedSizes)
 	return app
 }
 
@@ -162,6 +180,12 @@ func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if path == "/compare" {
+		if !app.DisableCompareEndpoint {
+			app.serveCompare(w, r)
+		}
+		return
+	}
 	p := imagorpath.Parse(path)
 	if p.Params {
 		if !app.DisableParamsEndpoint {
@@ -178,17 +202,12 @@ func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			w.WriteHeader(499)
-			return
-		}
-		e := WrapError(err)
-		if app.DisableErrorBody {
-			w.WriteHeader(e.Code)
+		if e, ok := err.(ErrRedirect); ok {
+			setCacheHeaders(w, r, app.CacheHeaderTTL, app.CacheHeaderSWR)
+			http.Redirect(w, r, getRedirectLocation(r, e.Path), http.StatusFound)
 			return
 		}
-		w.WriteHeader(e.Code)
-		writeJSON(w, r, e)
+		app.writeError(w, r, err)
 		return
 	}
 	if isBlobEmpty(blob) {
@@ -197,8 +216,21 @@ func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", blob.ContentType())
 	w.Header().Set("Content-Disposition", getContentDisposition(p, blob))
 	setCacheHeaders(w, r, getTtl(p, app.CacheHeaderTTL), app.CacheHeaderSWR)
-	if r.Header.Get("Imagor-Auto-Format") != "" {
+	if autoFormat := r.Header.Get("Imagor-Auto-Format"); autoFormat != "" {
 		w.Header().Add("Vary", "Accept")
+		if autoFormat == "auto" {
+			// format chosen by format(auto)
+			w.Header().Set("Imagor-Format", strings.TrimPrefix(getExtension(blob.BlobType()), "."))
+		}
+	}
+	if app.ClientHints {
+		w.Header().Set("Accept-CH", clientHintsHeaders)
+		if vary := r.Header.Get("Imagor-Client-Hints"); vary != "" {
+			w.Header().Add("Vary", vary)
+		}
+		if dpr := r.Header.Get("Imagor-Content-DPR"); dpr != "" {
+			w.Header().Set("Content-DPR", dpr)
+		}
 	}
 	if r.Header.Get("Imagor-Raw") != "" {
 		w.Header().Set("Content-Security-Policy", "script-src 'none'")
@@ -217,6 +249,32 @@ func (app *Imagor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// checkSignature checks URL signature of params path unless unsafe
+func (app *Imagor) checkSignature(p imagorpath.Params) error {
+	if !(app.Unsafe && p.Unsafe) && app.Signer != nil {
+		if hash := app.Signer.Sign(p.Path); hash != p.Hash {
+			if app.Debug {
+				app.Logger.Debug("sign-mismatch", zap.Any("params", p), zap.String("expected", hash))
+			}
+			return ErrSignatureMismatch
+		}
+	}
+	return nil
+}
+
+// writeError writes error response
+func (app *Imagor) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		w.WriteHeader(499)
+		return
+	}
+	e := WrapError(err)
+	w.WriteHeader(e.Code)
+	if !app.DisableErrorBody {
+		writeJSON(w, r, e)
+	}
+}
+
 // Serve serves imagor by context and params
 func (app *Imagor) Serve(ctx context.Context, p imagorpath.Params) (*Blob, error) {
 	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "", nil)
@@ -249,19 +307,48 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 		contextDefer(ctx, cancel)
 		r = r.WithContext(ctx)
 	}
-	if !(app.Unsafe && p.Unsafe) && app.Signer != nil && p.Path != "" {
-		if hash := app.Signer.Sign(p.Path); hash != p.Hash {
-			err = ErrSignatureMismatch
-			if app.Debug {
-				app.Logger.Debug("sign-mismatch", zap.Any("params", p), zap.String("expected", hash))
-			}
+	if p.Path != "" {
+		if err = app.checkSignature(p); err != nil {
+			return
+		}
+	}
+	var isPathChanged, isPresetsOnly bool
+	if len(app.Presets) > 0 {
+		var hasPresets bool
+		if p, hasPresets, isPresetsOnly, err = app.applyPresets(p); err != nil {
 			return
 		}
+		isPathChanged = hasPresets
+	}
+	if app.UnsafePresetsOnly && app.Unsafe && p.Unsafe && !isPresetsOnly {
+		// unsigned request only allowed with presets
+		err = ErrSignatureMismatch
+		return
+	}
+	if app.hasPolicy() {
+		var isSnapped bool
+		if p, isSnapped, err = app.applyPolicy(p); err != nil {
+			return
+		}
+		if isSnapped {
+			if path := app.canonicalPath(p); path != "" {
+				// redirect to canonical path so that caches converge
+				err = ErrRedirect{Path: path}
+				return
+			}
+			// preset alias served snapped as is
+			isPathChanged = true
+		}
 	}
-	var isPathChanged bool
 	if app.BaseParams != "" {
 		p = imagorpath.Apply(p, app.BaseParams)
 		isPathChanged = true
+		if app.hasPolicy() {
+			// base params are not part of the request path, snapped without redirect
+			if p, _, err = app.applyPolicy(p); err != nil {
+				return
+			}
+		}
 	}
 	var hasFormat, hasPreview, isRaw bool
 	var filters = p.Filters
@@ -279,6 +366,12 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 			}
 		case "format":
 			hasFormat = true
+			if f.Args == "auto" || strings.HasPrefix(f.Args, "auto,") {
+				// format(auto) candidates from Accept header
+				f.Args = getAutoFormatArgs(r.Header.Get("Accept"))
+				r.Header.Set("Imagor-Auto-Format", "auto") // response Vary: Accept header
+				isPathChanged = true
+			}
 		case "raw":
 			r.Header.Set("Imagor-Raw", "1")
 			isRaw = true
@@ -294,6 +387,18 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 			p.Filters = append(p.Filters, f)
 		}
 	}
+	if app.ClientHints {
+		var vary []string
+		var dpr float64
+		var isChanged bool
+		if p, vary, dpr, isChanged = app.applyClientHints(r, p); isChanged {
+			isPathChanged = true
+		}
+		r.Header.Set("Imagor-Client-Hints", strings.Join(vary, ", ")) // response Vary header
+		if dpr > 0 {
+			r.Header.Set("Imagor-Content-DPR", strconv.FormatFloat(dpr, 'f', -1, 64))
+		}
+	}
 	// auto WebP / AVIF
 	if !hasFormat && (app.AutoWebP || app.AutoAVIF) {
 		accept := r.Header.Get("Accept")
@@ -422,6 +527,10 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 				}
 			}
 		}
+		if err == nil {
+			// raw or passthrough SVG
+			blob, err = app.sanitizeSVG(blob)
+		}
 		if shouldSave {
 			// make sure storage saved before response and result storage
 			<-doneSave
@@ -439,6 +548,75 @@ func (app *Imagor) Do(r *http.Request, p imagorpath.Params) (blob *Blob, err err
 	})
 }
 
+// applyPresets expands preset(name) filters and preset:name path alias into the Params
+func (app *Imagor) applyPresets(
+	p imagorpath.Params,
+) (_ imagorpath.Params, hasPresets, isPresetsOnly bool, err error) {
+	var names []string
+	if alias, image, ok := strings.Cut(p.Image, "/"); ok && strings.HasPrefix(alias, "preset:") {
+		names = append(names, strings.TrimPrefix(alias, "preset:"))
+		p.Image = image
+	}
+	p.Filters, names = cutPresetFilters(p.Filters, names)
+	if len(names) == 0 {
+		return p, false, false, nil
+	}
+	isPresetsOnly = imagorpath.GeneratePath(p) ==
+		imagorpath.GeneratePath(imagorpath.Params{Meta: p.Meta, Image: p.Image})
+	for i := 0; i < len(names); i++ {
+		params, ok := app.Presets[names[i]]
+		if !ok || i >= maxPresets {
+			return p, false, false, ErrInvalid
+		}
+		hash, unsafe := p.Hash, p.Unsafe
+		p = imagorpath.Apply(p, "unsafe/"+params)
+		p.Hash, p.Unsafe = hash, unsafe
+		// nested presets
+		p.Filters, names = cutPresetFilters(p.Filters, names)
+	}
+	return p, true, isPresetsOnly, nil
+}
+
+func cutPresetFilters(filters imagorpath.Filters, names []string) (imagorpath.Filters, []string) {
+	var res imagorpath.Filters
+	for _, f := range filters {
+		if f.Name == "preset" {
+			names = append(names, f.Args)
+		} else {
+			res = append(res, f)
+		}
+	}
+	return res, names
+}
+
+// sanitizeSVG strips scripts and external references from SVG Blob before response
+func (app *Imagor) sanitizeSVG(blob *Blob) (*Blob, error) {
+	if app.DisableSVGSanitize || isBlobEmpty(blob) || blob.BlobType() != BlobTypeSVG {
+		return blob, nil
+	}
+	buf, err := blob.ReadAll()
+	if err != nil {
+		return blob, err
+	}
+	if buf, err = sanitizeSVG(buf, app.SVGAllowedSources); err != nil {
+		if app.Debug {
+			app.Logger.Debug("sanitize-svg", zap.Error(err))
+		}
+		return nil, ErrUnsupportedFormat
+	}
+	out := NewBlobFromBytes(buf)
+	out.SetContentType(blob.ContentType())
+	out.Header = blob.Header
+	if blob.Stat != nil {
+		out.Stat = &Stat{
+			ModifiedTime: blob.Stat.ModifiedTime,
+			ETag:         blob.Stat.ETag,
+			Size:         int64(len(buf)),
+		}
+	}
+	return out, nil
+}
+
 func (app *Imagor) requestWithLoadContext(r *http.Request) *http.Request {
 	var ctx = r.Context()
 	var cancel func()
@@ -735,6 +913,27 @@ func getTtl(p imagorpath.Params, defaultTtl time.Duration) time.Duration {
 	return defaultTtl
 }
 
+// getAutoFormatArgs returns format(auto) args with candidate formats accepted by client
+func getAutoFormatArgs(accept string) string {
+	var args = "auto"
+	if strings.Contains(accept, "image/avif") {
+		args += ",avif"
+	}
+	if strings.Contains(accept, "image/webp") {
+		args += ",webp"
+	}
+	return args
+}
+
+// getRedirectLocation returns location of imagor path, preserving the stripped server path prefix
+func getRedirectLocation(r *http.Request, path string) string {
+	var prefix string
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		prefix = strings.TrimSuffix(u.EscapedPath(), r.URL.EscapedPath())
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + path
+}
+
 func setCacheHeaders(w http.ResponseWriter, r *http.Request, ttl, swr time.Duration) {
 	if strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
 		ttl = 0
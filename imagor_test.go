@@ -15,6 +15,7 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"sync"
 	"testing"
@@ -331,6 +332,45 @@ func TestWithRaw(t *testing.T) {
 	assert.Equal(t, "bar", w.Header().Get("Content-Type"))
 }
 
+func TestWithSVGSanitize(t *testing.T) {
+	var svg = `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"><script>alert(1)</script><image href="https://cdn.example.com/a.png"/></svg>`
+	loader := loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+		return NewBlobFromBytes([]byte(svg)), nil
+	})
+	processor := processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+		return NewBlobFromBytes([]byte("processed")), nil
+	})
+
+	app := New(WithUnsafe(true), WithLoaders(loader), WithProcessors(processor))
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/unsafe/filters:raw()/foo.svg", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `<svg xmlns="http://www.w3.org/2000/svg"><image/></svg>`, w.Body.String())
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, "script-src 'none'", w.Header().Get("Content-Security-Policy"))
+
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/unsafe/foo.svg", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "processed", w.Body.String())
+
+	app = New(WithUnsafe(true), WithLoaders(loader), WithSVGAllowedSources("*.example.com"))
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/unsafe/foo.svg", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `<svg xmlns="http://www.w3.org/2000/svg"><image href="https://cdn.example.com/a.png"/></svg>`, w.Body.String())
+
+	app = New(WithUnsafe(true), WithLoaders(loader), WithDisableSVGSanitize(true))
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(
+		http.MethodGet, "https://example.com/unsafe/filters:raw()/foo.svg", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, svg, w.Body.String())
+}
+
 func TestWithOverrideHeader(t *testing.T) {
 	app := New(
 		WithDebug(true),
@@ -1273,6 +1313,368 @@ func TestBaseParams(t *testing.T) {
 	assert.Equal(t, "fit-in/200x0/filters:format(jpg):watermark(example.jpg)/abc.png", w.Body.String())
 }
 
+func TestPresets(t *testing.T) {
+	factory := func(unsafePresetsOnly bool) *Imagor {
+		return New(
+			WithDebug(true),
+			WithUnsafe(true),
+			WithUnsafePresetsOnly(unsafePresetsOnly),
+			WithSigner(imagorpath.NewDefaultSigner("1234")),
+			WithPresets(map[string]string{
+				"webp":      "filters:quality(80):format(webp):strip_exif()",
+				"thumbnail": "fit-in/200x0/filters:preset(webp)/",
+				"loop":      "filters:preset(loop)",
+			}),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte("foo")), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return NewBlobFromBytes([]byte(p.Path)), nil
+			})),
+		)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		code     int
+		expected string
+	}{
+		{
+			name:     "preset filter",
+			path:     "/unsafe/fit-in/800x0/filters:preset(webp)/abc.png",
+			code:     200,
+			expected: "fit-in/800x0/filters:quality(80):format(webp):strip_exif()/abc.png",
+		},
+		{
+			name:     "preset filter with other filters",
+			path:     "/unsafe/filters:fill(white):preset(webp)/abc.png",
+			code:     200,
+			expected: "filters:fill(white):quality(80):format(webp):strip_exif()/abc.png",
+		},
+		{
+			name:     "nested preset",
+			path:     "/unsafe/filters:preset(thumbnail)/abc.png",
+			code:     200,
+			expected: "fit-in/200x0/filters:quality(80):format(webp):strip_exif()/abc.png",
+		},
+		{
+			name:     "path alias",
+			path:     "/unsafe/preset:thumbnail/abc.png",
+			code:     200,
+			expected: "fit-in/200x0/filters:quality(80):format(webp):strip_exif()/abc.png",
+		},
+		{
+			name:     "signed path alias",
+			path:     "/" + imagorpath.NewDefaultSigner("1234").Sign("preset:thumbnail/abc.png") + "/preset:thumbnail/abc.png",
+			code:     200,
+			expected: "fit-in/200x0/filters:quality(80):format(webp):strip_exif()/abc.png",
+		},
+		{
+			name: "preset not found",
+			path: "/unsafe/filters:preset(foo)/abc.png",
+			code: 400,
+		},
+		{
+			name: "recursive preset",
+			path: "/unsafe/filters:preset(loop)/abc.png",
+			code: 400,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			factory(false).ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "https://example.com"+tt.path, nil))
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == 200 {
+				assert.Equal(t, tt.expected, w.Body.String())
+			}
+		})
+	}
+	t.Run("unsafe presets only", func(t *testing.T) {
+		app := factory(true)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/preset:thumbnail/abc.png", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "fit-in/200x0/filters:quality(80):format(webp):strip_exif()/abc.png", w.Body.String())
+
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/meta/filters:preset(webp)/abc.png", nil))
+		assert.Equal(t, 200, w.Code)
+
+		for _, path := range []string{
+			"/unsafe/abc.png",
+			"/unsafe/fit-in/800x0/filters:preset(webp)/abc.png",
+			"/unsafe/filters:fill(white):preset(webp)/abc.png",
+		} {
+			w = httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil))
+			assert.Equal(t, 403, w.Code, path)
+		}
+
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/"+imagorpath.NewDefaultSigner("1234").Sign("fit-in/800x0/abc.png")+"/fit-in/800x0/abc.png", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "fit-in/800x0/abc.png", w.Body.String())
+	})
+	t.Run("unsafe presets only policy snap", func(t *testing.T) {
+		app := New(
+			WithUnsafe(true),
+			WithUnsafePresetsOnly(true),
+			WithSigner(imagorpath.NewDefaultSigner("1234")),
+			WithPresets(map[string]string{"thumbnail": "fit-in/300x0"}),
+			WithAllowedSizes(320, 640),
+			WithPolicySnap(true),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte("foo")), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return NewBlobFromBytes([]byte(p.Path)), nil
+			})),
+		)
+		// served snapped without redirect to the signed expanded path
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/preset:thumbnail/abc.png", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Equal(t, "fit-in/320x0/abc.png", w.Body.String())
+
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/"+imagorpath.NewDefaultSigner("1234").Sign("preset:thumbnail/abc.png")+"/preset:thumbnail/abc.png", nil))
+		assert.Equal(t, 302, w.Code)
+		assert.Equal(t, "/"+imagorpath.Generate(imagorpath.Params{FitIn: true, Width: 320, Image: "abc.png"}, imagorpath.NewDefaultSigner("1234")), w.Header().Get("Location"))
+	})
+}
+
+func TestPresetsResultKey(t *testing.T) {
+	resultStore := newMapStore()
+	var processed int
+	app := New(
+		WithUnsafe(true),
+		WithPresets(map[string]string{
+			"webp": "filters:quality(80):format(webp)",
+		}),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte("foo")), nil
+		})),
+		WithResultStorages(resultStore),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			processed++
+			return NewBlobFromBytes([]byte(p.Path)), nil
+		})),
+	)
+	for _, path := range []string{
+		"/unsafe/fit-in/100x100/filters:preset(webp)/abc.png",
+		"/unsafe/fit-in/100x100/filters:quality(80):format(webp)/abc.png",
+	} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "fit-in/100x100/filters:quality(80):format(webp)/abc.png", w.Body.String())
+	}
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, 1, resultStore.LoadCnt["fit-in/100x100/filters:quality(80):format(webp)/abc.png"])
+}
+
+func TestPolicy(t *testing.T) {
+	factory := func(options ...Option) *Imagor {
+		return New(append([]Option{
+			WithDebug(true),
+			WithUnsafe(true),
+			WithSigner(imagorpath.NewDefaultSigner("1234")),
+			WithAllowedSizes(640, 320, 1280),
+			WithAllowedFilters("quality(10-90):round_corner(0-50,,):format:fill"),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte("foo")), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return NewBlobFromBytes([]byte(p.Path)), nil
+			})),
+		}, options...)...)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		code     int
+		expected string
+	}{
+		{
+			name:     "allowed",
+			path:     "/unsafe/fit-in/640x0/filters:quality(80):format(webp):fill(white)/abc.png",
+			code:     200,
+			expected: "fit-in/640x0/filters:quality(80):format(webp):fill(white)/abc.png",
+		},
+		{
+			name:     "allowed flip",
+			path:     "/unsafe/-320x-1280/abc.png",
+			code:     200,
+			expected: "-320x-1280/abc.png",
+		},
+		{
+			name: "size not allowed",
+			path: "/unsafe/641x0/abc.png",
+			code: 400,
+		},
+		{
+			name: "filter not allowed",
+			path: "/unsafe/640x0/filters:blur(2)/abc.png",
+			code: 400,
+		},
+		{
+			name: "filter args out of range",
+			path: "/unsafe/640x0/filters:quality(95)/abc.png",
+			code: 400,
+		},
+		{
+			name: "filter args not numeric",
+			path: "/unsafe/640x0/filters:round_corner(abc,10,red)/abc.png",
+			code: 400,
+		},
+		{
+			name:     "utility filters allowed",
+			path:     "/unsafe/640x0/filters:expire(32503680000000):attachment():quality(80)/abc.png",
+			code:     200,
+			expected: "640x0/filters:quality(80)/abc.png",
+		},
+		{
+			name:     "filter args unrestricted",
+			path:     "/unsafe/640x0/filters:round_corner(20,999,red)/abc.png",
+			code:     200,
+			expected: "640x0/filters:round_corner(20,999,red)/abc.png",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			factory().ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "https://example.com"+tt.path, nil))
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code == 200 {
+				assert.Equal(t, tt.expected, w.Body.String())
+			}
+		})
+	}
+	snaps := []struct {
+		name     string
+		path     string
+		code     int
+		location string
+	}{
+		{
+			name:     "snap size",
+			path:     "/unsafe/fit-in/700x-1000/abc.png",
+			code:     302,
+			location: "/unsafe/fit-in/640x-1280/abc.png",
+		},
+		{
+			name:     "snap size tie",
+			path:     "/unsafe/480x0/abc.png",
+			code:     302,
+			location: "/unsafe/640x0/abc.png",
+		},
+		{
+			name:     "snap size max",
+			path:     "/unsafe/5000x5000/abc.png",
+			code:     302,
+			location: "/unsafe/1280x1280/abc.png",
+		},
+		{
+			name:     "snap filter args",
+			path:     "/unsafe/320x0/filters:quality(100):round_corner(-5,10)/abc.png",
+			code:     302,
+			location: "/unsafe/320x0/filters:quality(90):round_corner(0,10)/abc.png",
+		},
+		{
+			name:     "snap signed",
+			path:     "/" + imagorpath.NewDefaultSigner("1234").Sign("300x0/abc.png") + "/300x0/abc.png",
+			code:     302,
+			location: "/" + imagorpath.Generate(imagorpath.Params{Width: 320, Image: "abc.png"}, imagorpath.NewDefaultSigner("1234")),
+		},
+		{
+			name: "snap filter not allowed",
+			path: "/unsafe/320x0/filters:blur(2)/abc.png",
+			code: 400,
+		},
+		{
+			name: "snap percentage not allowed",
+			path: "/unsafe/50%25x0/abc.png",
+			code: 400,
+		},
+		{
+			name: "snap aspect ratio not allowed",
+			path: "/unsafe/640x16:9/abc.png",
+			code: 400,
+		},
+	}
+	for _, tt := range snaps {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			factory(WithPolicySnap(true)).ServeHTTP(w, httptest.NewRequest(
+				http.MethodGet, "https://example.com"+tt.path, nil))
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.location, w.Header().Get("Location"))
+			if tt.code == 302 {
+				assert.NotEmpty(t, w.Header().Get("Cache-Control"))
+				w = httptest.NewRecorder()
+				factory(WithPolicySnap(true)).ServeHTTP(w, httptest.NewRequest(
+					http.MethodGet, "https://example.com"+tt.location, nil))
+				assert.Equal(t, 200, w.Code)
+			}
+		})
+	}
+	t.Run("size step", func(t *testing.T) {
+		app := New(
+			WithUnsafe(true),
+			WithSizeStep(100),
+			WithPolicySnap(true),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte("foo")), nil
+			})),
+		)
+		for path, location := range map[string]string{
+			"/unsafe/149x151/abc.png": "/unsafe/100x200/abc.png",
+			"/unsafe/20x0/abc.png":    "/unsafe/100x0/abc.png",
+		} {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com"+path, nil))
+			assert.Equal(t, 302, w.Code)
+			assert.Equal(t, location, w.Header().Get("Location"))
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/300x0/filters:blur(5)/abc.png", nil))
+		assert.Equal(t, 200, w.Code)
+	})
+	t.Run("base params", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		factory(WithBaseParams("filters:quality(95)")).ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/640x0/abc.png", nil))
+		assert.Equal(t, 400, w.Code)
+
+		w = httptest.NewRecorder()
+		factory(WithBaseParams("filters:blur(2)"), WithPolicySnap(true)).ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/640x0/abc.png", nil))
+		assert.Equal(t, 400, w.Code)
+
+		w = httptest.NewRecorder()
+		factory(WithBaseParams("filters:quality(95)"), WithPolicySnap(true)).ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/unsafe/640x0/abc.png", nil))
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "640x0/filters:quality(90)/abc.png", w.Body.String())
+	})
+	t.Run("path prefix", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		http.StripPrefix("/imagor", factory(WithPolicySnap(true))).ServeHTTP(w, httptest.NewRequest(
+			http.MethodGet, "https://example.com/imagor/unsafe/300x0/abc.png", nil))
+		assert.Equal(t, 302, w.Code)
+		assert.Equal(t, "/imagor/unsafe/320x0/abc.png", w.Header().Get("Location"))
+	})
+}
+
 func TestAutoWebP(t *testing.T) {
 	factory := func(isAuto bool) *Imagor {
 		return New(
@@ -1411,6 +1813,223 @@ func TestAutoAVIF(t *testing.T) {
 	})
 }
 
+func TestAutoFormat(t *testing.T) {
+	resultStore := newMapStore()
+	app := New(
+		WithDebug(true),
+		WithUnsafe(true),
+		WithAutoWebP(true),
+		WithResultStorages(resultStore),
+		WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+			return NewBlobFromBytes([]byte("foo")), nil
+		})),
+		WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+			if strings.Contains(p.Path, "webp") {
+				return NewBlobFromBytes([]byte("RIFF\x00\x00\x00\x00WEBPVP8 " + p.Path)), nil
+			}
+			return NewBlobFromBytes([]byte("\x89PNG\r\n\x1a\n" + p.Path)), nil
+		})),
+	)
+	tests := []struct {
+		name     string
+		path     string
+		accept   string
+		expected string
+		format   string
+	}{
+		{
+			name:     "avif webp",
+			path:     "/unsafe/filters:format(auto)/abc.png",
+			accept:   "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+			expected: "filters:format(auto,avif,webp)/abc.png",
+			format:   "webp",
+		},
+		{
+			name:     "no accept",
+			path:     "/unsafe/filters:format(auto)/abc.png",
+			expected: "filters:format(auto)/abc.png",
+			format:   "png",
+		},
+		{
+			name:     "candidates from accept only",
+			path:     "/unsafe/filters:format(auto,avif,webp)/abc.png",
+			accept:   "image/apng,image/*,*/*;q=0.8",
+			expected: "filters:format(auto)/abc.png",
+			format:   "png",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "https://example.com"+tt.path, nil)
+			r.Header.Set("Accept", tt.accept)
+			app.ServeHTTP(w, r)
+			assert.Equal(t, 200, w.Code)
+			assert.True(t, strings.HasSuffix(w.Body.String(), tt.expected))
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			assert.Equal(t, tt.format, w.Header().Get("Imagor-Format"))
+			assert.Equal(t, 1, resultStore.SaveCnt[tt.expected])
+		})
+	}
+}
+
+func TestClientHints(t *testing.T) {
+	factory := func(options ...Option) *Imagor {
+		return New(append([]Option{
+			WithDebug(true),
+			WithUnsafe(true),
+			WithClientHints(true),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte("foo")), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				return NewBlobFromBytes([]byte(p.Path)), nil
+			})),
+		}, options...)...)
+	}
+	tests := []struct {
+		name       string
+		path       string
+		headers    map[string]string
+		expected   string
+		vary       []string
+		contentDPR string
+	}{
+		{
+			name:     "no hints",
+			path:     "/unsafe/100x50/abc.png",
+			expected: "100x50/abc.png",
+			vary:     []string{"Save-Data"},
+		},
+		{
+			name:       "dpr filter",
+			path:       "/unsafe/fit-in/100x-50/filters:dpr(2):fill(white)/abc.png",
+			expected:   "fit-in/200x-100/filters:fill(white)/abc.png",
+			vary:       []string{"Save-Data"},
+			contentDPR: "2",
+		},
+		{
+			name:       "dpr filter max",
+			path:       "/unsafe/100x0/filters:dpr(10)/abc.png",
+			expected:   "400x0/abc.png",
+			vary:       []string{"Save-Data"},
+			contentDPR: "4",
+		},
+		{
+			name:       "dpr auto",
+			path:       "/unsafe/100x0/filters:dpr(auto)/abc.png",
+			headers:    map[string]string{"Sec-CH-DPR": "1.5"},
+			expected:   "150x0/abc.png",
+			vary:       []string{"Sec-CH-DPR, Save-Data"},
+			contentDPR: "1.5",
+		},
+		{
+			name:     "dpr header without filter",
+			path:     "/unsafe/100x0/abc.png",
+			headers:  map[string]string{"Sec-CH-DPR": "2"},
+			expected: "100x0/abc.png",
+			vary:     []string{"Save-Data"},
+		},
+		{
+			name:       "width hint",
+			path:       "/unsafe/abc.png",
+			headers:    map[string]string{"Sec-CH-Width": "640", "Sec-CH-Viewport-Width": "1024", "Sec-CH-DPR": "2"},
+			expected:   "640x0/abc.png",
+			vary:       []string{"Sec-CH-Width, Sec-CH-Viewport-Width, Sec-CH-DPR, Save-Data"},
+			contentDPR: "2",
+		},
+		{
+			name:       "viewport width hint",
+			path:       "/unsafe/fit-in/abc.png",
+			headers:    map[string]string{"Sec-CH-Viewport-Width": "400", "Sec-CH-DPR": "2"},
+			expected:   "fit-in/800x0/abc.png",
+			vary:       []string{"Sec-CH-Width, Sec-CH-Viewport-Width, Sec-CH-DPR, Save-Data"},
+			contentDPR: "2",
+		},
+		{
+			name:     "dpr filter without dimensions",
+			path:     "/unsafe/filters:dpr(2)/abc.png",
+			headers:  map[string]string{"Sec-CH-DPR": "2"},
+			expected: "abc.png",
+			vary:     []string{"Sec-CH-Width, Sec-CH-Viewport-Width, Sec-CH-DPR, Save-Data"},
+		},
+		{
+			name:     "no width hint",
+			path:     "/unsafe/abc.png",
+			expected: "abc.png",
+			vary:     []string{"Sec-CH-Width, Sec-CH-Viewport-Width, Sec-CH-DPR, Save-Data"},
+		},
+		{
+			name:     "save data",
+			path:     "/unsafe/100x0/abc.png",
+			headers:  map[string]string{"Save-Data": "on"},
+			expected: "100x0/filters:quality(50)/abc.png",
+			vary:     []string{"Save-Data"},
+		},
+		{
+			name:     "save data lower quality",
+			path:     "/unsafe/100x0/filters:quality(90)/abc.png",
+			headers:  map[string]string{"Save-Data": "on"},
+			expected: "100x0/filters:quality(50)/abc.png",
+			vary:     []string{"Save-Data"},
+		},
+		{
+			name:     "save data keep quality",
+			path:     "/unsafe/100x0/filters:quality(30)/abc.png",
+			headers:  map[string]string{"Save-Data": "on"},
+			expected: "100x0/filters:quality(30)/abc.png",
+			vary:     []string{"Save-Data"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "https://example.com"+tt.path, nil)
+			for key, val := range tt.headers {
+				r.Header.Set(key, val)
+			}
+			factory().ServeHTTP(w, r)
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, tt.expected, w.Body.String())
+			assert.Equal(t, tt.vary, w.Header().Values("Vary"))
+			assert.Equal(t, tt.contentDPR, w.Header().Get("Content-DPR"))
+			assert.Equal(t, "Sec-CH-DPR, Sec-CH-Width, Sec-CH-Viewport-Width, Save-Data", w.Header().Get("Accept-CH"))
+		})
+	}
+	t.Run("not enabled", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/abc.png", nil)
+		r.Header.Set("Sec-CH-Width", "640")
+		r.Header.Set("Save-Data", "on")
+		factory(WithClientHints(false)).ServeHTTP(w, r)
+		assert.Equal(t, "abc.png", w.Body.String())
+		assert.Empty(t, w.Header().Get("Vary"))
+		assert.Empty(t, w.Header().Get("Accept-CH"))
+	})
+	t.Run("allowed sizes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/abc.png", nil)
+		r.Header.Set("Sec-CH-Width", "700")
+		factory(WithAllowedSizes(320, 640, 1280)).ServeHTTP(w, r)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, "640x0/abc.png", w.Body.String())
+	})
+	t.Run("result key", func(t *testing.T) {
+		resultStore := newMapStore()
+		app := factory(WithResultStorages(resultStore))
+		for _, dpr := range []string{"1", "2", "2"} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "https://example.com/unsafe/100x0/filters:dpr()/abc.png", nil)
+			r.Header.Set("Sec-CH-DPR", dpr)
+			app.ServeHTTP(w, r)
+			assert.Equal(t, 200, w.Code)
+		}
+		assert.Equal(t, 1, resultStore.SaveCnt["100x0/abc.png"])
+		assert.Equal(t, 1, resultStore.SaveCnt["200x0/abc.png"])
+		assert.Equal(t, 1, resultStore.LoadCnt["200x0/abc.png"])
+	})
+}
+
 func TestWithTimeout(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(r.URL.String(), "sleep") {
@@ -1597,3 +2216,68 @@ func (f processorFunc) Startup(_ context.Context) error {
 func (f processorFunc) Shutdown(_ context.Context) error {
 	return nil
 }
+
+func TestCompare(t *testing.T) {
+	signer := imagorpath.NewDefaultSigner("1234")
+	factory := func(options ...Option) *Imagor {
+		return New(append([]Option{
+			WithDebug(true),
+			WithUnsafe(true),
+			WithSigner(signer),
+			WithLoaders(loaderFunc(func(r *http.Request, image string) (*Blob, error) {
+				return NewBlobFromBytes([]byte(image)), nil
+			})),
+			WithProcessors(processorFunc(func(ctx context.Context, blob *Blob, p imagorpath.Params, load LoadFunc) (*Blob, error) {
+				buf, _ := blob.ReadAll()
+				var algo string
+				for _, f := range p.Filters {
+					if f.Name == "format" {
+						algo = f.Args
+					}
+				}
+				if p.Meta || algo == "" {
+					return nil, ErrUnsupportedFormat
+				}
+				hash := map[string]string{
+					"a.jpg": "f0f0f0f0f0f0f0f0",
+					"b.jpg": "f0f0f0f0f0f0f0ff",
+				}[string(buf)]
+				if algo == "dhash" {
+					hash = strings.Repeat("0", 16)
+				}
+				return NewBlobFromBytes([]byte(hash)), nil
+			})),
+		}, options...)...)
+	}
+	serve := func(app *Imagor, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://example.com/compare?"+query, nil))
+		return w
+	}
+	app := factory()
+	signed := imagorpath.Generate(imagorpath.Params{Image: "b.jpg", Width: 100}, signer)
+
+	w := serve(app, "a=unsafe/filters:format(webp)/a.jpg&b="+url.QueryEscape(signed))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"hash":"phash","a":"f0f0f0f0f0f0f0f0","b":"f0f0f0f0f0f0f0ff","distance":4}`, w.Body.String())
+
+	w = serve(app, "a=unsafe/meta/a.jpg&b=unsafe/b.jpg&hash=dhash")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"hash":"dhash","a":"0000000000000000","b":"0000000000000000","distance":0}`, w.Body.String())
+
+	w = serve(app, "a=unsafe/a.jpg&b=unsafe/b.jpg&hash=foo")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(app, "a=unsafe/a.jpg")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(factory(WithUnsafe(false)), "a=unsafe/a.jpg&b="+url.QueryEscape(signed))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(app, "a=unsafe/a.jpg&b=unsafe/c.jpg")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	w = serve(factory(WithDisableCompareEndpoint(true)), "a=unsafe/a.jpg&b=unsafe/b.jpg")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+}
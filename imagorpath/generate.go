@@ -3,10 +3,14 @@ package imagorpath
 import (
 	"fmt"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
+// gravityPrefixRegex image path to be escaped, otherwise parsed as gravity
+var gravityPrefixRegex = regexp.MustCompile("^" + gravity + "/")
+
 // GeneratePath generate imagor path by Params struct
 func GeneratePath(p Params) string {
 	var parts []string
@@ -37,7 +41,9 @@ func GeneratePath(p Params) string {
 	if p.Stretch {
 		parts = append(parts, "stretch")
 	}
+	hasAspect := p.AspectWidth > 0 && p.AspectHeight > 0
 	if p.HFlip || p.Width != 0 || p.VFlip || p.Height != 0 ||
+		p.WidthPercent > 0 || p.HeightPercent > 0 || hasAspect ||
 		p.PaddingLeft > 0 || p.PaddingTop > 0 {
 		if p.Width < 0 {
 			p.HFlip = !p.HFlip
@@ -54,8 +60,18 @@ func GeneratePath(p Params) string {
 		if p.VFlip {
 			vFlipStr = "-"
 		}
-		parts = append(parts, fmt.Sprintf(
-			"%s%dx%s%d", hFlipStr, p.Width, vFlipStr, p.Height))
+		width := generateDimension(p.Width, p.WidthPercent)
+		height := generateDimension(p.Height, p.HeightPercent)
+		if hasAspect {
+			aspect := strconv.FormatFloat(p.AspectWidth, 'f', -1, 64) + ":" +
+				strconv.FormatFloat(p.AspectHeight, 'f', -1, 64)
+			if p.Width == 0 && p.WidthPercent == 0 && (p.AspectOnWidth || p.Height > 0 || p.HeightPercent > 0) {
+				width = aspect
+			} else {
+				height = aspect
+			}
+		}
+		parts = append(parts, hFlipStr+width+"x"+vFlipStr+height)
 	}
 	if p.PaddingLeft > 0 || p.PaddingTop > 0 || p.PaddingRight > 0 || p.PaddingBottom > 0 {
 		if p.PaddingLeft == p.PaddingRight && p.PaddingTop == p.PaddingBottom {
@@ -73,6 +89,13 @@ func GeneratePath(p Params) string {
 	if p.VAlign == VAlignTop || p.VAlign == VAlignBottom {
 		parts = append(parts, p.VAlign)
 	}
+	if p.Gravity != "" {
+		if p.GravityX != 0 || p.GravityY != 0 {
+			parts = append(parts, fmt.Sprintf("%s%+d%+d", p.Gravity, p.GravityX, p.GravityY))
+		} else {
+			parts = append(parts, p.Gravity)
+		}
+	}
 	if p.Smart {
 		parts = append(parts, "smart")
 	}
@@ -83,7 +106,7 @@ func GeneratePath(p Params) string {
 		}
 		parts = append(parts, "filters:"+strings.Join(filters, ":"))
 	}
-	if strings.Contains(p.Image, "?") ||
+	if strings.Contains(p.Image, "?") || !isQueryUnescaped(p.Image) ||
 		strings.HasPrefix(p.Image, "trim/") ||
 		strings.HasPrefix(p.Image, "meta/") ||
 		strings.HasPrefix(p.Image, "fit-in/") ||
@@ -93,13 +116,28 @@ func GeneratePath(p Params) string {
 		strings.HasPrefix(p.Image, "right/") ||
 		strings.HasPrefix(p.Image, "bottom/") ||
 		strings.HasPrefix(p.Image, "center/") ||
-		strings.HasPrefix(p.Image, "smart/") {
+		strings.HasPrefix(p.Image, "smart/") ||
+		gravityPrefixRegex.MatchString(p.Image) {
 		p.Image = url.QueryEscape(p.Image)
 	}
 	parts = append(parts, p.Image)
 	return strings.Join(parts, "/")
 }
 
+// isQueryUnescaped if image path remains the same after query unescape by Parse
+func isQueryUnescaped(image string) bool {
+	u, err := url.QueryUnescape(image)
+	return err == nil && u == image
+}
+
+// generateDimension pixels or percentage of dimension, % url encoded
+func generateDimension(n int, percent float64) string {
+	if percent > 0 {
+		return strconv.FormatFloat(percent, 'f', -1, 64) + "%25"
+	}
+	return strconv.Itoa(n)
+}
+
 // GenerateUnsafe generate unsafe imagor endpoint by Params struct
 func GenerateUnsafe(p Params) string {
 	return Generate(p, nil)
@@ -37,6 +37,11 @@ type Params struct {
 	Stretch       bool    `json:"stretch,omitempty"`
 	Width         int     `json:"width,omitempty"`
 	Height        int     `json:"height,omitempty"`
+	WidthPercent  float64 `json:"width_percent,omitempty"`
+	HeightPercent float64 `json:"height_percent,omitempty"`
+	AspectWidth   float64 `json:"aspect_width,omitempty"`
+	AspectHeight  float64 `json:"aspect_height,omitempty"`
+	AspectOnWidth bool    `json:"aspect_on_width,omitempty"`
 	PaddingLeft   int     `json:"padding_left,omitempty"`
 	PaddingTop    int     `json:"padding_top,omitempty"`
 	PaddingRight  int     `json:"padding_right,omitempty"`
@@ -45,6 +50,9 @@ type Params struct {
 	VFlip         bool    `json:"v_flip,omitempty"`
 	HAlign        string  `json:"h_align,omitempty"`
 	VAlign        string  `json:"v_align,omitempty"`
+	Gravity       string  `json:"gravity,omitempty"`
+	GravityX      int     `json:"gravity_x,omitempty"`
+	GravityY      int     `json:"gravity_y,omitempty"`
 	Smart         bool    `json:"smart,omitempty"`
 	Filters       Filters `json:"filters,omitempty"`
 }
@@ -358,6 +358,136 @@ func TestParseGenerate(t *testing.T) {
 				Filters:    []Filter{{Name: "some_filter"}},
 			},
 		},
+		{
+			name: "aspect ratio with width",
+			uri:  "800x16:9/filters:some_filter()/img",
+			params: Params{
+				Path:         "800x16:9/filters:some_filter()/img",
+				Image:        "img",
+				Width:        800,
+				AspectWidth:  16,
+				AspectHeight: 9,
+				Filters:      []Filter{{Name: "some_filter"}},
+			},
+		},
+		{
+			name: "aspect ratio with height",
+			uri:  "fit-in/-2.35:1x450/img",
+			params: Params{
+				Path:          "fit-in/-2.35:1x450/img",
+				Image:         "img",
+				FitIn:         true,
+				HFlip:         true,
+				Height:        450,
+				AspectWidth:   2.35,
+				AspectHeight:  1,
+				AspectOnWidth: true,
+			},
+		},
+		{
+			name: "aspect ratio on width",
+			uri:  "16:9x0/img",
+			params: Params{
+				Path:          "16:9x0/img",
+				Image:         "img",
+				AspectWidth:   16,
+				AspectHeight:  9,
+				AspectOnWidth: true,
+			},
+		},
+		{
+			name: "aspect ratio on height",
+			uri:  "0x16:9/img",
+			params: Params{
+				Path:         "0x16:9/img",
+				Image:        "img",
+				AspectWidth:  16,
+				AspectHeight: 9,
+			},
+		},
+		{
+			name: "aspect ratio with gravity",
+			uri:  "800x16:9/north-east+10+20/a.jpg",
+			params: Params{
+				Path:         "800x16:9/north-east+10+20/a.jpg",
+				Image:        "a.jpg",
+				Width:        800,
+				AspectWidth:  16,
+				AspectHeight: 9,
+				Gravity:      "north-east",
+				GravityX:     10,
+				GravityY:     20,
+			},
+		},
+		{
+			name: "percentage dimensions",
+			uri:  "50%25x-12.5%25/img",
+			params: Params{
+				Path:          "50%25x-12.5%25/img",
+				Image:         "img",
+				WidthPercent:  50,
+				HeightPercent: 12.5,
+				VFlip:         true,
+			},
+		},
+		{
+			name: "percentage with aspect ratio",
+			uri:  "30%25x1:1/img",
+			params: Params{
+				Path:         "30%25x1:1/img",
+				Image:        "img",
+				WidthPercent: 30,
+				AspectWidth:  1,
+				AspectHeight: 1,
+			},
+		},
+		{
+			name: "gravity with offsets",
+			uri:  "300x200/north-east+10+20/smart/img",
+			params: Params{
+				Path:     "300x200/north-east+10+20/smart/img",
+				Image:    "img",
+				Width:    300,
+				Height:   200,
+				Gravity:  "north-east",
+				GravityX: 10,
+				GravityY: 20,
+				Smart:    true,
+			},
+		},
+		{
+			name: "gravity with negative offsets",
+			uri:  "300x200/south_west-5+0/img",
+			params: Params{
+				Path:     "300x200/south_west-5+0/img",
+				Image:    "img",
+				Width:    300,
+				Height:   200,
+				Gravity:  "south_west",
+				GravityX: -5,
+			},
+		},
+		{
+			name: "gravity",
+			uri:  "300x200/east/img",
+			params: Params{
+				Path:    "300x200/east/img",
+				Image:   "img",
+				Width:   300,
+				Height:  200,
+				Gravity: "east",
+			},
+		},
+		{
+			name: "gravity image escaped",
+			uri:  "300x200/north%2Fimg",
+			params: Params{
+				Path:   "300x200/north%2Fimg",
+				Image:  "north/img",
+				Width:  300,
+				Height: 200,
+			},
+		},
 	}
 	for _, test := range tests {
 		if test.name == "" {
@@ -398,6 +528,30 @@ func TestParamsNegativeDimensionFlip(t *testing.T) {
 	}))
 }
 
+func TestParsePercentDimension(t *testing.T) {
+	p := Parse("50%x0/north+10-10/img")
+	assert.Equal(t, 50.0, p.WidthPercent)
+	assert.Equal(t, 0, p.Height)
+	assert.Equal(t, "north", p.Gravity)
+	assert.Equal(t, 10, p.GravityX)
+	assert.Equal(t, -10, p.GravityY)
+	assert.Equal(t, "unsafe/50%25x0/north+10-10/img", GenerateUnsafe(p))
+}
+
+func TestParseGravityImagePath(t *testing.T) {
+	p := Parse("unsafe/east/photos/a.jpg")
+	assert.Equal(t, "east", p.Gravity)
+	assert.Equal(t, "photos/a.jpg", p.Image)
+
+	for _, image := range []string{
+		"east+1+0/a.jpg", "north-east/a.jpg", "south_west-5+0/a.jpg", "up/a.jpg", "eastern/a.jpg",
+	} {
+		p = Parse(GenerateUnsafe(Params{Image: image}))
+		assert.Empty(t, p.Gravity, image)
+		assert.Equal(t, image, p.Image)
+	}
+}
+
 func TestNormalize(t *testing.T) {
 	assert.Equal(t,
 		"unsafe/fit-in/800x800/filters%3Afill%28white%29%3Awatermark%28raw.githubusercontent.com/cshum/imagor/master/testdata/gopher.png%2Crepeat%2Cbottom%2C10%29%3Aformat%28jpeg%29/https%3A/raw.githubusercontent.com/golang-samples/gopher-vector/master/gopher+.png",
@@ -17,6 +17,12 @@ var pathRegex = regexp.MustCompile(
 		"(.+)?",
 )
 
+// dimension pixels, percentage N% or N%25 url encoded, or aspect ratio A:B
+const dimension = "\\d+(?:\\.\\d+)?(?:%25|%)|\\d+(?:\\.\\d+)?:\\d+(?:\\.\\d+)?|\\d*"
+
+// gravity of crop with optional +X+Y offsets
+const gravity = "(north[-_](?:east|west)|south[-_](?:east|west)|north|south|east|west)(([+-]\\d+)([+-]\\d+))?"
+
 var paramsRegex = regexp.MustCompile(
 	"/*" +
 		// meta
@@ -29,14 +35,16 @@ var paramsRegex = regexp.MustCompile(
 		"(fit-in/)?" +
 		// stretch
 		"(stretch/)?" +
-		// dimensions
-		"((\\-?)(\\d*)x(\\-?)(\\d*)/)?" +
+		// dimensions of pixels, percentage or aspect ratio
+		"((\\-?)(" + dimension + ")x(\\-?)(" + dimension + ")/)?" +
 		// paddings
 		"((\\d+)x(\\d+)(:(\\d+)x(\\d+))?/)?" +
 		// h_align
 		"((left|right|center)/)?" +
 		// v_align
 		"((top|bottom|middle)/)?" +
+		// gravity with offsets
+		"(" + gravity + "/)?" +
 		// smart
 		"(smart/)?" +
 		// filters and image
@@ -103,9 +111,11 @@ func Apply(p Params, path string) Params {
 	index++
 	if match[index] != "" {
 		p.HFlip = match[index+1] != ""
-		p.Width, _ = strconv.Atoi(match[index+2])
+		p.Width, p.WidthPercent = parseDimension(match[index+2], &p)
 		p.VFlip = match[index+3] != ""
-		p.Height, _ = strconv.Atoi(match[index+4])
+		p.Height, p.HeightPercent = parseDimension(match[index+4], &p)
+		// aspect ratio in place of width, e.g. 16:9x0
+		p.AspectOnWidth = strings.Contains(match[index+2], ":") && !strings.Contains(match[index+4], ":")
 	}
 	index += 5
 	if match[index] != "" {
@@ -128,6 +138,12 @@ func Apply(p Params, path string) Params {
 		p.VAlign = match[index+1]
 	}
 	index += 2
+	if match[index] != "" {
+		p.Gravity = match[index+1]
+		p.GravityX, _ = strconv.Atoi(match[index+3])
+		p.GravityY, _ = strconv.Atoi(match[index+4])
+	}
+	index += 5
 	if match[index] != "" {
 		p.Smart = true
 	}
@@ -145,6 +161,22 @@ func Apply(p Params, path string) Params {
 	return p
 }
 
+// parseDimension parses pixels or percentage of dimension,
+// or aspect ratio A:B applied to params
+func parseDimension(s string, p *Params) (n int, percent float64) {
+	if i := strings.Index(s, ":"); i > -1 {
+		p.AspectWidth, _ = strconv.ParseFloat(s[:i], 64)
+		p.AspectHeight, _ = strconv.ParseFloat(s[i+1:], 64)
+		return
+	}
+	if i := strings.Index(s, "%"); i > -1 {
+		percent, _ = strconv.ParseFloat(s[:i], 64)
+		return
+	}
+	n, _ = strconv.Atoi(s)
+	return
+}
+
 func parseFilters(str string) (filters []Filter, path string) {
 	if strings.HasPrefix(str, "filters:") {
 		str = str[8:]
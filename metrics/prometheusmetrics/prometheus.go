@@ -23,8 +23,9 @@ var (
 type PrometheusMetrics struct {
 	http.Server
 
-	Path   string
-	Logger *zap.Logger
+	Path       string
+	Logger     *zap.Logger
+	Collectors []prometheus.Collector
 }
 
 // New create new metrics PrometheusMetrics
@@ -54,6 +55,11 @@ func (s *PrometheusMetrics) Startup(_ context.Context) error {
 	if err := prometheus.Register(httpRequestDuration); err != nil {
 		return err
 	}
+	for _, collector := range s.Collectors {
+		if err := prometheus.Register(collector); err != nil {
+			return err
+		}
+	}
 
 	go func() {
 		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -94,3 +100,14 @@ func WithLogger(logger *zap.Logger) Option {
 		}
 	}
 }
+
+// WithCollectors with additional metrics collectors option, e.g. processor metrics
+func WithCollectors(collectors ...prometheus.Collector) Option {
+	return func(s *PrometheusMetrics) {
+		for _, collector := range collectors {
+			if collector != nil {
+				s.Collectors = append(s.Collectors, collector)
+			}
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
@@ -32,4 +33,10 @@ func TestWithOption(t *testing.T) {
 			http.MethodGet, "https://example.com/", nil))
 		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
 	})
+
+	t.Run("collectors", func(t *testing.T) {
+		c := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_collector_total"})
+		v := New(WithCollectors(c, nil))
+		assert.Equal(t, []prometheus.Collector{c}, v.Collectors)
+	})
 }
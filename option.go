@@ -3,6 +3,7 @@ package imagor
 import (
 	"github.com/xudaolong/imagor/imagorpath"
 	"go.uber.org/zap"
+	"strings"
 	"time"
 )
 
@@ -159,6 +160,14 @@ func WithAutoAVIF(enable bool) Option {
 	}
 }
 
+// WithClientHints with client hints option, applying dpr() filter, Sec-CH-DPR, Sec-CH-Width,
+// Sec-CH-Viewport-Width and Save-Data request headers
+func WithClientHints(enabled bool) Option {
+	return func(app *Imagor) {
+		app.ClientHints = enabled
+	}
+}
+
 // WithBasePathRedirect with base path redirect option
 func WithBasePathRedirect(url string) Option {
 	return func(app *Imagor) {
@@ -173,6 +182,66 @@ func WithBaseParams(params string) Option {
 	}
 }
 
+// WithPresets with named presets option, referenced by preset(name) filter or preset:name path alias
+// e.g. "thumbnail": "fit-in/200x200/filters:format(webp):quality(80)"
+func WithPresets(presets map[string]string) Option {
+	return func(app *Imagor) {
+		for name, params := range presets {
+			if name = strings.TrimSpace(name); name != "" {
+				if app.Presets == nil {
+					app.Presets = map[string]string{}
+				}
+				app.Presets[name] = params
+			}
+		}
+	}
+}
+
+// WithUnsafePresetsOnly with option restricting unsafe unsigned requests to presets only
+func WithUnsafePresetsOnly(enabled bool) Option {
+	return func(app *Imagor) {
+		app.UnsafePresetsOnly = enabled
+	}
+}
+
+// WithAllowedSizes with allowed sizes policy option restricting Width and Height to the nearest allowed size
+func WithAllowedSizes(sizes ...int) Option {
+	return func(app *Imagor) {
+		for _, size := range sizes {
+			if size > 0 {
+				app.AllowedSizes = append(app.AllowedSizes, size)
+			}
+		}
+	}
+}
+
+// WithSizeStep with size step policy option rounding Width and Height to the nearest multiple of step
+func WithSizeStep(step int) Option {
+	return func(app *Imagor) {
+		if step > 0 {
+			app.SizeStep = step
+		}
+	}
+}
+
+// WithAllowedFilters with allowed filters policy option in imagor filters syntax,
+// with optional comma separated argument ranges e.g. quality(10-95):blur(0-5):format
+func WithAllowedFilters(filters string) Option {
+	return func(app *Imagor) {
+		if filters = strings.TrimSpace(filters); filters != "" {
+			app.AllowedFilters = append(app.AllowedFilters, parsePolicyFilters(filters)...)
+		}
+	}
+}
+
+// WithPolicySnap with option snapping policy violations to the nearest allowed values
+// and redirecting to the canonical path, instead of rejecting
+func WithPolicySnap(enabled bool) Option {
+	return func(app *Imagor) {
+		app.PolicySnap = enabled
+	}
+}
+
 // WithModifiedTimeCheck with option for modified time check of storage against result storage
 func WithModifiedTimeCheck(enabled bool) Option {
 	return func(app *Imagor) {
@@ -194,6 +263,34 @@ func WithDisableParamsEndpoint(disabled bool) Option {
 	}
 }
 
+// WithDisableCompareEndpoint with disable imagor /compare endpoint
+func WithDisableCompareEndpoint(disabled bool) Option {
+	return func(app *Imagor) {
+		app.DisableCompareEndpoint = disabled
+	}
+}
+
+// WithDisableSVGSanitize with disable SVG sanitization option for raw and passthrough SVG response
+func WithDisableSVGSanitize(disabled bool) Option {
+	return func(app *Imagor) {
+		app.DisableSVGSanitize = disabled
+	}
+}
+
+// WithSVGAllowedSources with allowed external reference hosts option for SVG sanitization.
+// Accept csv wth glob pattern e.g. *.google.com,*.github.com
+func WithSVGAllowedSources(hosts ...string) Option {
+	return func(app *Imagor) {
+		for _, raw := range hosts {
+			for _, host := range strings.Split(raw, ",") {
+				if host = strings.TrimSpace(host); host != "" {
+					app.SVGAllowedSources = append(app.SVGAllowedSources, host)
+				}
+			}
+		}
+	}
+}
+
 // WithDebug with debug option
 func WithDebug(debug bool) Option {
 	return func(app *Imagor) {
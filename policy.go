@@ -0,0 +1,172 @@
+package imagor
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xudaolong/imagor/imagorpath"
+)
+
+// utilityFilters are handled by imagor itself and not subject to the allowed filters policy
+var utilityFilters = map[string]bool{
+	"expire": true, "attachment": true, "preview": true, "raw": true, "dpr": true,
+}
+
+var policyRangeRegex = regexp.MustCompile(`^\s*(-?\d+(?:\.\d+)?)\s*-\s*(-?\d+(?:\.\d+)?)\s*$`)
+
+// hasPolicy indicates if allowed sizes or allowed filters policy is configured
+func (app *Imagor) hasPolicy() bool {
+	return len(app.AllowedSizes) > 0 || app.SizeStep > 0 || app.AllowedFilters != nil
+}
+
+// applyPolicy checks Width, Height and filters against the allowed sizes and allowed filters policy.
+// Percentage and aspect ratio dimensions are not allowed by allowed sizes. With PolicySnap, dimensions are snapped to the nearest allowed size and filter arguments
+// are clamped into the allowed range, otherwise ErrPolicyViolation is returned.
+// Utility filters expire, attachment, preview, raw and dpr are always allowed
+func (app *Imagor) applyPolicy(p imagorpath.Params) (_ imagorpath.Params, isSnapped bool, err error) {
+	if (len(app.AllowedSizes) > 0 || app.SizeStep > 0) &&
+		(p.WidthPercent > 0 || p.HeightPercent > 0 || p.AspectWidth > 0 || p.AspectHeight > 0) {
+		// percentage and aspect ratio dimensions depend on the image, cannot be snapped
+		return p, false, ErrPolicyViolation
+	}
+	for _, size := range []*int{&p.Width, &p.Height} {
+		// negative dimension means flip
+		v, sign := *size, 1
+		if v < 0 {
+			v, sign = -v, -1
+		}
+		if s := app.snapSize(v); s != v {
+			if !app.PolicySnap {
+				return p, false, ErrPolicyViolation
+			}
+			*size = s * sign
+			isSnapped = true
+		}
+	}
+	if app.AllowedFilters == nil {
+		return p, isSnapped, nil
+	}
+	var filters = make(imagorpath.Filters, 0, len(p.Filters))
+	for _, f := range p.Filters {
+		if utilityFilters[f.Name] {
+			filters = append(filters, f)
+			continue
+		}
+		rule, ok := app.allowedFilter(f.Name)
+		if !ok {
+			return p, false, ErrPolicyViolation
+		}
+		args, snapped, ok := applyFilterArgsPolicy(f.Args, rule.Args, app.PolicySnap)
+		if !ok {
+			return p, false, ErrPolicyViolation
+		}
+		if snapped {
+			f.Args = args
+			isSnapped = true
+		}
+		filters = append(filters, f)
+	}
+	p.Filters = filters
+	return p, isSnapped, nil
+}
+
+// snapSize returns the nearest allowed size, 0 is always allowed
+func (app *Imagor) snapSize(v int) int {
+	if v == 0 {
+		return v
+	}
+	if len(app.AllowedSizes) > 0 {
+		// AllowedSizes sorted ascending, ties resolve to the larger size
+		i := sort.SearchInts(app.AllowedSizes, v)
+		if i == len(app.AllowedSizes) {
+			return app.AllowedSizes[i-1]
+		}
+		if i > 0 && v-app.AllowedSizes[i-1] < app.AllowedSizes[i]-v {
+			return app.AllowedSizes[i-1]
+		}
+		return app.AllowedSizes[i]
+	}
+	if step := app.SizeStep; step > 0 {
+		if s := (v + step/2) / step * step; s > 0 {
+			return s
+		}
+		return step
+	}
+	return v
+}
+
+func (app *Imagor) allowedFilter(name string) (imagorpath.Filter, bool) {
+	for _, f := range app.AllowedFilters {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return imagorpath.Filter{}, false
+}
+
+// applyFilterArgsPolicy checks comma separated filter arguments against ranges e.g. 0-100,,-50-50.
+// Argument with empty or unparsable range is not restricted
+func applyFilterArgsPolicy(args, ranges string, snap bool) (_ string, isSnapped, ok bool) {
+	if ranges == "" {
+		return args, false, true
+	}
+	var rs = strings.Split(ranges, ",")
+	var vals = strings.Split(args, ",")
+	for i, val := range vals {
+		if i >= len(rs) {
+			break
+		}
+		match := policyRangeRegex.FindStringSubmatch(rs[i])
+		if match == nil {
+			continue
+		}
+		lo, _ := strconv.ParseFloat(match[1], 64)
+		hi, _ := strconv.ParseFloat(match[2], 64)
+		n, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return args, false, false
+		}
+		if n >= lo && n <= hi {
+			continue
+		}
+		if !snap {
+			return args, false, false
+		}
+		if n < lo {
+			n = lo
+		} else {
+			n = hi
+		}
+		vals[i] = strconv.FormatFloat(n, 'f', -1, 64)
+		isSnapped = true
+	}
+	return strings.Join(vals, ","), isSnapped, true
+}
+
+// parsePolicyFilters parses colon separated filters with optional arguments e.g. quality(10-95):blur(0-5):format
+func parsePolicyFilters(str string) (filters imagorpath.Filters) {
+	for _, seg := range strings.Split(str, ":") {
+		name, args, _ := strings.Cut(strings.TrimSpace(seg), "(")
+		if name = strings.TrimSpace(name); name != "" {
+			filters = append(filters, imagorpath.Filter{
+				Name: name,
+				Args: strings.TrimSuffix(args, ")"),
+			})
+		}
+	}
+	return
+}
+
+// canonicalPath returns the signed or unsafe path of params.
+// Empty for unsafe request restricted to presets, as the expanded path is not allowed unsigned
+func (app *Imagor) canonicalPath(p imagorpath.Params) string {
+	if app.Unsafe && p.Unsafe {
+		if app.UnsafePresetsOnly {
+			return ""
+		}
+		return imagorpath.GenerateUnsafe(p)
+	}
+	return imagorpath.Generate(p, app.Signer)
+}
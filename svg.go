@@ -0,0 +1,221 @@
+package imagor
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// svgStripElements SVG elements stripped along with its content
+var svgStripElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// svgAnimationElements SVG elements that can set attributes of other elements
+var svgAnimationElements = map[string]bool{
+	"set":              true,
+	"animate":          true,
+	"animatemotion":    true,
+	"animatetransform": true,
+	"animatecolor":     true,
+}
+
+var (
+	cssURLRegex    = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)]*?))\s*\)`)
+	cssImportRegex = regexp.MustCompile(`(?i)@import\b[^;]*;?`)
+	cssUnsafeRegex = regexp.MustCompile(`(?i)\\|expression\s*\(|javascript:|behavior\s*:|-moz-binding`)
+
+	svgTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	svgAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;",
+		"\n", "&#xA;", "\r", "&#xD;", "\t", "&#x9;")
+)
+
+// sanitizeSVG strips scripts, event handlers, foreignObject and external references from SVG.
+// External references in href, xlink:href and CSS url() are kept only if
+// the host matches the allowed sources glob pattern e.g. *.google.com
+func sanitizeSVG(buf []byte, allowedSources []string) ([]byte, error) {
+	var (
+		d       = xml.NewDecoder(bytes.NewReader(buf))
+		w       = &bytes.Buffer{}
+		stack   []string
+		skip    int
+		pending bool
+	)
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	allowed := func(s string) bool {
+		return isSVGURLAllowed(s, allowedSources)
+	}
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if skip > 0 {
+			switch tok.(type) {
+			case xml.StartElement:
+				skip++
+			case xml.EndElement:
+				skip--
+			}
+			continue
+		}
+		if pending {
+			pending = false
+			if _, ok := tok.(xml.EndElement); ok && len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+				w.WriteString("/>")
+				continue
+			}
+			w.WriteByte('>')
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			local := strings.ToLower(t.Name.Local)
+			if svgStripElements[local] || (svgAnimationElements[local] && isSVGAnimationUnsafe(t)) {
+				skip = 1
+				continue
+			}
+			name := xmlName(t.Name)
+			stack = append(stack, name)
+			w.WriteByte('<')
+			w.WriteString(name)
+			for _, attr := range t.Attr {
+				value, ok := sanitizeSVGAttr(attr, allowed)
+				if !ok {
+					continue
+				}
+				w.WriteByte(' ')
+				w.WriteString(xmlName(attr.Name))
+				w.WriteString(`="`)
+				_, _ = svgAttrEscaper.WriteString(w, value)
+				w.WriteByte('"')
+			}
+			pending = true
+		case xml.EndElement:
+			if len(stack) == 0 {
+				continue
+			}
+			w.WriteString("</")
+			w.WriteString(stack[len(stack)-1])
+			w.WriteByte('>')
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			text := string(t)
+			if len(stack) > 0 && strings.EqualFold(stack[len(stack)-1], "style") {
+				text = sanitizeCSS(text, allowed)
+			}
+			_, _ = svgTextEscaper.WriteString(w, text)
+		case xml.ProcInst:
+			if t.Target == "xml" {
+				w.WriteString("<?xml ")
+				w.Write(t.Inst)
+				w.WriteString("?>")
+			}
+		}
+		// comments and directives e.g. DOCTYPE and ENTITY declarations are stripped
+	}
+	if pending {
+		w.WriteByte('>')
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		w.WriteString("</")
+		w.WriteString(stack[i])
+		w.WriteByte('>')
+	}
+	return w.Bytes(), nil
+}
+
+func sanitizeSVGAttr(attr xml.Attr, allowed func(string) bool) (string, bool) {
+	local := strings.ToLower(attr.Name.Local)
+	switch {
+	case strings.HasPrefix(local, "on"):
+		// event handlers
+		return "", false
+	case local == "href" || local == "src":
+		return attr.Value, allowed(attr.Value)
+	case local == "base" && attr.Name.Space == "xml":
+		return "", false
+	case local == "style" || strings.Contains(strings.ToLower(attr.Value), "url("):
+		value := sanitizeCSS(attr.Value, allowed)
+		return value, value != ""
+	}
+	return attr.Value, true
+}
+
+func isSVGAnimationUnsafe(t xml.StartElement) bool {
+	for _, attr := range t.Attr {
+		if strings.ToLower(attr.Name.Local) != "attributename" {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(attr.Value))
+		if i := strings.LastIndexByte(name, ':'); i > -1 {
+			name = name[i+1:]
+		}
+		if name == "href" || name == "src" || name == "style" || strings.HasPrefix(name, "on") {
+			return true
+		}
+	}
+	return false
+}
+
+func sanitizeCSS(css string, allowed func(string) bool) string {
+	if cssUnsafeRegex.MatchString(css) {
+		return ""
+	}
+	css = cssImportRegex.ReplaceAllString(css, "")
+	return cssURLRegex.ReplaceAllStringFunc(css, func(s string) string {
+		m := cssURLRegex.FindStringSubmatch(s)
+		if allowed(m[1] + m[2] + m[3]) {
+			return s
+		}
+		return "none"
+	})
+}
+
+func isSVGURLAllowed(s string, allowedSources []string) bool {
+	// browsers ignore whitespaces and control characters within URL
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, s)
+	if strings.HasPrefix(s, "#") {
+		return true
+	}
+	if lower := strings.ToLower(s); strings.HasPrefix(lower, "data:image/") &&
+		!strings.HasPrefix(lower, "data:image/svg") {
+		return true
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	for _, pattern := range allowedSources {
+		if matched, e := path.Match(pattern, u.Host); matched && e == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
@@ -0,0 +1,114 @@
+package imagor
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	tests := []struct {
+		name     string
+		svg      string
+		expected string
+	}{
+		{
+			name:     "event handler",
+			svg:      `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"><rect width="1" height="1" ONCLICK="alert(1)"/></svg>`,
+			expected: `<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1"/></svg>`,
+		},
+		{
+			name:     "script",
+			svg:      `<svg><script>alert(1)</script><SCRIPT type="text/ecmascript">alert(1)</SCRIPT><rect/></svg>`,
+			expected: `<svg><rect/></svg>`,
+		},
+		{
+			name:     "script href",
+			svg:      `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><script xlink:href="data:,alert(1)"/></svg>`,
+			expected: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"></svg>`,
+		},
+		{
+			name:     "handler",
+			svg:      `<svg><handler type="application/ecmascript" ev:event="load">alert(1)</handler></svg>`,
+			expected: `<svg></svg>`,
+		},
+		{
+			name:     "foreignObject",
+			svg:      `<svg><foreignObject width="100" height="100"><iframe xmlns="http://www.w3.org/1999/xhtml" src="javascript:alert(1)"></iframe><body><img src="x" onerror="alert(1)"/></body></foreignObject></svg>`,
+			expected: `<svg></svg>`,
+		},
+		{
+			name:     "javascript link",
+			svg:      `<svg><a xlink:href="javascript:alert(1)"><text>x</text></a><a href="jav&#x09;ascript:alert(1)"><text>y</text></a></svg>`,
+			expected: `<svg><a><text>x</text></a><a><text>y</text></a></svg>`,
+		},
+		{
+			name:     "animate href",
+			svg:      `<svg><a><animate attributeName="href" values="javascript:alert(1)"/><set attributeName="xlink:href" to="javascript:alert(1)"/><set attributeName="onmouseover" to="alert(1)"/><animate attributeName="x" values="0;1"/><text>x</text></a></svg>`,
+			expected: `<svg><a><animate attributeName="x" values="0;1"/><text>x</text></a></svg>`,
+		},
+		{
+			name:     "external references",
+			svg:      `<svg><image href="https://evil.com/x.png"/><use xlink:href="//evil.com/sprite.svg#a"/><use href="data:image/svg+xml;base64,PHN2Zz48L3N2Zz4="/><use href="#local"/><image href="data:image/png;base64,iVBORw0KGgo="/></svg>`,
+			expected: `<svg><image/><use/><use/><use href="#local"/><image href="data:image/png;base64,iVBORw0KGgo="/></svg>`,
+		},
+		{
+			name:     "css url",
+			svg:      `<svg><style>@import url(https://evil.com/x.css); rect{fill:url(https://evil.com/p.svg#a)} a>b{fill:url(#g)}</style><rect style="fill:url('https://evil.com/x')" fill="url(#grad)" mask="url( &quot;https://evil.com/m&quot; )"/></svg>`,
+			expected: `<svg><style> rect{fill:none} a&gt;b{fill:url(#g)}</style><rect style="fill:none" fill="url(#grad)" mask="none"/></svg>`,
+		},
+		{
+			name:     "css escape",
+			svg:      `<svg><style>rect{fill:u\72l(https://evil.com/x)}</style><rect style="background:expression(alert(1))"/></svg>`,
+			expected: `<svg><style></style><rect/></svg>`,
+		},
+		{
+			name:     "xml base and stylesheet",
+			svg:      `<?xml version="1.0"?><?xml-stylesheet href="https://evil.com/x.css"?><svg xml:base="https://evil.com/"><!-- <script>alert(1)</script> --></svg>`,
+			expected: `<?xml version="1.0"?><svg></svg>`,
+		},
+		{
+			name:     "doctype entity",
+			svg:      `<!DOCTYPE svg [<!ENTITY xss "<script>alert(1)</script>">]><svg><text>&xss;&amp;</text></svg>`,
+			expected: `<svg><text>&amp;xss;&amp;</text></svg>`,
+		},
+		{
+			name:     "cdata",
+			svg:      `<svg><text><![CDATA[</text><script>alert(1)</script>]]></text></svg>`,
+			expected: `<svg><text>&lt;/text&gt;&lt;script&gt;alert(1)&lt;/script&gt;</text></svg>`,
+		},
+		{
+			name:     "unclosed",
+			svg:      `<svg><g><rect>`,
+			expected: `<svg><g><rect></rect></g></svg>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := sanitizeSVG([]byte(tt.svg), nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(buf))
+		})
+	}
+}
+
+func TestSanitizeSVGAllowedSources(t *testing.T) {
+	buf, err := sanitizeSVG([]byte(
+		`<svg><image href="https://cdn.example.com/x.png"/><image href="https://evil.com/x.png"/><style>@font-face{src:url(https://fonts.example.com/a.woff)}</style></svg>`,
+	), []string{"*.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, `<svg><image href="https://cdn.example.com/x.png"/><image/><style>@font-face{src:url(https://fonts.example.com/a.woff)}</style></svg>`, string(buf))
+}
+
+func TestSanitizeSVGTestData(t *testing.T) {
+	buf, err := os.ReadFile("testdata/test.svg")
+	require.NoError(t, err)
+	out, err := sanitizeSVG(buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, BlobTypeSVG, NewBlobFromBytes(out).BlobType())
+	assert.Contains(t, string(out), `<svg id="svg2" width="620" height="472"`)
+	assert.Equal(t, strings.Count(string(buf), "<path"), strings.Count(string(out), "<path"))
+}
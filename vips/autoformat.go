@@ -0,0 +1,176 @@
+package vips
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/xudaolong/imagor"
+	"github.com/xudaolong/imagor/imagorpath"
+	"go.uber.org/zap"
+)
+
+// graphicSampleSize max dimension of the sample used for photo vs graphic heuristic
+const graphicSampleSize = 64
+
+// autoFormatThroughput estimated encoding throughput in megapixels per second
+var autoFormatThroughput = map[ImageType]float64{
+	ImageTypeJPEG: 50,
+	ImageTypePNG:  20,
+	ImageTypeGIF:  5,
+	ImageTypeWEBP: 10,
+	ImageTypeAVIF: 2,
+}
+
+type autoFormatCandidate struct {
+	Format   ImageType
+	Lossless bool
+}
+
+// parseAutoFormat parses format(auto,avif,webp) args into formats accepted by client
+func parseAutoFormat(args string) (accept map[ImageType]bool, ok bool) {
+	parts := strings.Split(args, ",")
+	if strings.TrimSpace(parts[0]) != "auto" {
+		return nil, false
+	}
+	accept = map[ImageType]bool{}
+	for _, s := range parts[1:] {
+		if typ, ok := imageTypeMap[strings.TrimSpace(s)]; ok {
+			accept[typ] = true
+		}
+	}
+	return accept, true
+}
+
+// autoFormatCandidates returns export candidates based on content traits
+// and formats accepted by client. The universally supported fallback comes first
+func autoFormatCandidates(
+	img *Image, blob *imagor.Blob, accept map[ImageType]bool,
+) (candidates []autoFormatCandidate) {
+	add := func(format ImageType, lossless bool) {
+		if accept[format] && IsSaveSupported(format) {
+			candidates = append(candidates, autoFormatCandidate{format, lossless})
+		}
+	}
+	switch {
+	case img.Height() > img.PageHeight():
+		// animated, AVIF export does not support animation
+		candidates = append(candidates, autoFormatCandidate{Format: supportedSaveFormat(ImageTypeGIF)})
+		add(ImageTypeWEBP, false)
+	case isGraphic(img, blob):
+		// lossless to avoid artifacts on flat colors and sharp edges
+		candidates = append(candidates, autoFormatCandidate{ImageTypePNG, true})
+		add(ImageTypeWEBP, true)
+	default:
+		if img.HasAlpha() {
+			candidates = append(candidates, autoFormatCandidate{ImageTypePNG, true})
+		} else {
+			candidates = append(candidates, autoFormatCandidate{Format: ImageTypeJPEG})
+		}
+		add(ImageTypeWEBP, false)
+		add(ImageTypeAVIF, false)
+	}
+	return
+}
+
+// isGraphic photo vs graphic heuristic, that graphic compresses better losslessly
+func isGraphic(img *Image, blob *imagor.Blob) bool {
+	if blob != nil {
+		switch blob.BlobType() {
+		case imagor.BlobTypeJPEG, imagor.BlobTypeHEIF, imagor.BlobTypeAVIF:
+			return false
+		case imagor.BlobTypeSVG, imagor.BlobTypePDF:
+			return true
+		}
+	}
+	sample, err := img.Copy()
+	if err != nil {
+		return false
+	}
+	defer sample.Close()
+	if err = sample.Thumbnail(graphicSampleSize, graphicSampleSize, InterestingNone); err != nil {
+		return false
+	}
+	if sample.HasAlpha() {
+		if err = sample.Flatten(&Color{R: 255, G: 255, B: 255}); err != nil {
+			return false
+		}
+	}
+	png, err := sample.ExportPng(NewPngExportParams())
+	if err != nil {
+		return false
+	}
+	jpg, err := sample.ExportJpeg(NewJpegExportParams())
+	if err != nil {
+		return false
+	}
+	return len(png) <= len(jpg)
+}
+
+// estimate estimated encoding duration of the candidate for the image of pixels
+func (c autoFormatCandidate) estimate(pixels int, target *targetQuality) time.Duration {
+	throughput, ok := autoFormatThroughput[c.Format]
+	if !ok {
+		throughput = autoFormatThroughput[ImageTypeWEBP]
+	}
+	if c.Lossless && c.Format == ImageTypeWEBP {
+		throughput /= 5
+	}
+	d := time.Duration(float64(pixels) / (throughput * 1e6) * float64(time.Second))
+	if target != nil && !c.Lossless && isTargetQualitySupported(c.Format) {
+		// bisection up to max iterations
+		d *= maxTargetQualityIterations
+	}
+	return d
+}
+
+// exportAuto encodes candidates within AutoFormatBudget by estimated encoding durations
+// and returns the smallest. Candidates depend on the image only so that the result is cacheable
+func (v *Processor) exportAuto(
+	ctx context.Context, img *Image, candidates []autoFormatCandidate, target *targetQuality,
+	compression int, quality int, palette bool, bitdepth int, params *imagorpath.Filters,
+) (buf []byte, format ImageType, chosenQuality int, err error) {
+	var pixels = img.Width() * img.Height()
+	var estimated time.Duration
+	for i, c := range candidates {
+		d := c.estimate(pixels, target)
+		if i > 0 && estimated+d > v.AutoFormatBudget {
+			continue
+		}
+		estimated += d
+		if err = ctx.Err(); err != nil {
+			return nil, c.Format, 0, err
+		}
+		t := time.Now()
+		var b []byte
+		var e error
+		var q = quality
+		switch {
+		case c.Lossless:
+			q = 0
+			b, e = v.export(img, c.Format, compression, q, palette, bitdepth, true, params)
+		case target != nil && isTargetQualitySupported(c.Format):
+			// compare sizes at the same perceptual quality
+			b, q, e = v.exportTargetQuality(ctx, img, c.Format, target, compression, palette, bitdepth, params)
+		default:
+			b, e = v.export(img, c.Format, compression, quality, palette, bitdepth, false, params)
+		}
+		if e != nil {
+			if i == 0 {
+				return nil, c.Format, 0, e
+			}
+			continue
+		}
+		if v.Debug {
+			v.Logger.Debug("auto-format",
+				zap.String("format", ImageTypes[c.Format]),
+				zap.Bool("lossless", c.Lossless),
+				zap.Int("bytes", len(b)),
+				zap.Duration("duration", time.Since(t)))
+		}
+		if buf == nil || len(b) < len(buf) {
+			buf, format, chosenQuality = b, c.Format, q
+		}
+	}
+	return
+}
@@ -0,0 +1,16 @@
+package vips
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoFormatCandidateEstimate(t *testing.T) {
+	assert.Equal(t, time.Second, autoFormatCandidate{Format: ImageTypeAVIF}.estimate(2000000, nil))
+	assert.Equal(t, time.Second/5, autoFormatCandidate{Format: ImageTypeWEBP}.estimate(2000000, nil))
+	assert.Equal(t, time.Second, autoFormatCandidate{ImageTypeWEBP, true}.estimate(2000000, nil))
+	assert.Equal(t, time.Second*8/5, autoFormatCandidate{Format: ImageTypeWEBP}.estimate(2000000, &targetQuality{Value: 0.98}))
+	assert.Equal(t, time.Second/10, autoFormatCandidate{ImageTypePNG, true}.estimate(2000000, &targetQuality{Value: 0.98}))
+}
@@ -51,3 +51,12 @@ func goSourceSeek(
 	}
 	return -1
 }
+
+//export goImageEvalKill
+func goImageEvalKill(ptr unsafe.Pointer) C.int {
+	if r, ok := pointer.Restore(ptr).(*contextRef); ok && r.ctx.Err() != nil {
+		r.killed.Store(true)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,268 @@
+package vips
+
+import (
+	"context"
+	"html"
+	"math"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/xudaolong/imagor"
+)
+
+const (
+	// collageMaxImages max number of images of a collage
+	collageMaxImages = 25
+	// collageDefaultCaptionSize default caption font size
+	collageDefaultCaptionSize = 14
+)
+
+// collage options of collage filter
+type collage struct {
+	Images       []string
+	Cols         int
+	Masonry      bool
+	Gutter       int
+	Background   string
+	CellWidth    int
+	CellHeight   int
+	Fit          string
+	Captions     []string
+	CaptionNames bool
+	CaptionSize  int
+	CaptionColor string
+}
+
+// parseCollage parses collage filter args of images followed by options in any order
+// e.g. cols=3, layout=masonry, gutter=10, background=white, cell=200x200, fit=contain,
+// captions, captions=A|B|C, caption_size=14, caption_color=black
+func parseCollage(args ...string) *collage {
+	c := &collage{Background: "white", CaptionSize: collageDefaultCaptionSize, CaptionColor: "black"}
+	for _, arg := range args {
+		arg = strings.TrimSpace(arg)
+		key, value, ok := strings.Cut(arg, "=")
+		if s, e := url.QueryUnescape(value); e == nil {
+			value = s
+		}
+		if !ok {
+			if arg == "captions" {
+				c.CaptionNames = true
+			} else if arg != "" {
+				if s, e := url.QueryUnescape(arg); e == nil {
+					arg = s
+				}
+				c.Images = append(c.Images, arg)
+			}
+			continue
+		}
+		switch key {
+		case "cols":
+			c.Cols, _ = strconv.Atoi(value)
+		case "layout":
+			c.Masonry = value == "masonry"
+		case "gutter":
+			c.Gutter, _ = strconv.Atoi(value)
+			c.Gutter = max(0, min(c.Gutter, 200))
+		case "background":
+			c.Background = value
+		case "cell":
+			w, h, _ := strings.Cut(value, "x")
+			c.CellWidth, _ = strconv.Atoi(w)
+			c.CellHeight, _ = strconv.Atoi(h)
+		case "fit":
+			c.Fit = value
+		case "captions":
+			c.Captions = strings.Split(value, "|")
+		case "caption_size":
+			c.CaptionSize, _ = strconv.Atoi(value)
+			c.CaptionSize = max(1, min(c.CaptionSize, 200))
+		case "caption_color":
+			c.CaptionColor = value
+		}
+	}
+	if len(c.Images) > collageMaxImages-1 {
+		c.Images = c.Images[:collageMaxImages-1]
+	}
+	return c
+}
+
+// caption returns caption of the i-th cell, the source image i = 0 has no file name
+func (c *collage) caption(i int) string {
+	if i < len(c.Captions) {
+		return c.Captions[i]
+	}
+	if c.CaptionNames && i > 0 {
+		return path.Base(c.Images[i-1])
+	}
+	return ""
+}
+
+// captionHeight height of the caption area below each cell
+func (c *collage) captionHeight() int {
+	if len(c.Captions) == 0 && !c.CaptionNames {
+		return 0
+	}
+	return c.CaptionSize * 3 / 2
+}
+
+// gridLayout positions of n cells of w x h in cols columns, and the canvas size
+func gridLayout(n, cols, w, h, gutter, captionHeight int) (points [][2]int, width, height int) {
+	rows := (n + cols - 1) / cols
+	for i := 0; i < n; i++ {
+		points = append(points, [2]int{
+			gutter + (i%cols)*(w+gutter),
+			gutter + (i/cols)*(h+captionHeight+gutter),
+		})
+	}
+	width = gutter + cols*(w+gutter)
+	height = gutter + rows*(h+captionHeight+gutter)
+	return
+}
+
+// masonryLayout positions of cells of heights in cols columns of width w,
+// each placed in the shortest column, and the canvas size
+func masonryLayout(heights []int, cols, w, gutter, captionHeight int) (points [][2]int, width, height int) {
+	columns := make([]int, cols)
+	for i := range columns {
+		columns[i] = gutter
+	}
+	for _, h := range heights {
+		col := 0
+		for i, y := range columns {
+			if y < columns[col] {
+				col = i
+			}
+		}
+		points = append(points, [2]int{gutter + col*(w+gutter), columns[col]})
+		columns[col] += h + captionHeight + gutter
+	}
+	width = gutter + cols*(w+gutter)
+	for _, y := range columns {
+		height = max(height, y)
+	}
+	return
+}
+
+func (v *Processor) collage(ctx context.Context, img *Image, load imagor.LoadFunc, args ...string) (err error) {
+	c := parseCollage(args...)
+	if len(c.Images) == 0 {
+		return
+	}
+	n := len(c.Images) + 1
+	cols := c.Cols
+	if cols <= 0 {
+		cols = int(math.Ceil(math.Sqrt(float64(n))))
+	}
+	cols = min(cols, n)
+	if err = firstFrame(img); err != nil {
+		return
+	}
+	cellW, cellH := c.CellWidth, c.CellHeight
+	if cellW <= 0 {
+		cellW = img.Width()
+	}
+	if cellH <= 0 {
+		cellH = img.PageHeight()
+	}
+	if err = v.checkDimensions(float64(cols*cellW), float64(cellH)); err != nil {
+		return
+	}
+	// loaded images shrunk on load to the cell, resized by fit
+	loadW, loadH, crop, size := cellW, cellH, InterestingCentre, SizeBoth
+	if c.Masonry {
+		loadH, crop = v.MaxHeight, InterestingNone
+	} else if c.Fit == "fill" {
+		crop, size = InterestingNone, SizeForce
+	} else if c.Fit == "contain" {
+		crop = InterestingNone
+	}
+	// cells of the source image followed by the loaded images
+	cells := make([]*Image, n)
+	source, err := img.Copy()
+	if err != nil {
+		return
+	}
+	cells[0] = source
+	contextDefer(ctx, source.Close)
+	for i, image := range c.Images {
+		var blob *imagor.Blob
+		if blob, err = load(image); err != nil {
+			return
+		}
+		if cells[i+1], err = v.NewThumbnail(
+			ctx, blob, loadW, loadH, crop, size, 1, 1, 0,
+		); err != nil {
+			return
+		}
+		contextDefer(ctx, cells[i+1].Close)
+	}
+	heights := make([]int, n)
+	offsets := make([][2]int, n)
+	for i, cell := range cells {
+		if c.Masonry {
+			_, _, err = fitImage(cell, cellW, 0, "", "")
+		} else {
+			offsets[i][0], offsets[i][1], err = fitImage(cell, cellW, cellH, c.Fit, "")
+		}
+		if err != nil {
+			return
+		}
+		if err = prepareLayer(cell); err != nil {
+			return
+		}
+		heights[i] = cell.PageHeight()
+	}
+	captionHeight := c.captionHeight()
+	var points [][2]int
+	var width, height int
+	if c.Masonry {
+		points, width, height = masonryLayout(heights, cols, cellW, c.Gutter, captionHeight)
+	} else {
+		points, width, height = gridLayout(n, cols, cellW, cellH, c.Gutter, captionHeight)
+	}
+	if err = v.checkDimensions(float64(width), float64(height)); err != nil {
+		return
+	}
+	var opacity float64
+	if c.Background != "none" {
+		opacity = 1
+	}
+	canvas, err := newRectImage(width, height, 0, getColor(nil, c.Background), opacity)
+	if err != nil {
+		return
+	}
+	contextDefer(ctx, canvas.Close)
+	for i, cell := range cells {
+		x, y := points[i][0], points[i][1]
+		if err = canvas.Composite(cell, BlendModeOver, x+offsets[i][0], y+offsets[i][1]); err != nil {
+			return
+		}
+		boxHeight := cellH
+		if c.Masonry {
+			boxHeight = heights[i]
+		}
+		if caption := c.caption(i); captionHeight > 0 && caption != "" {
+			if err = canvas.RichLabel(&LabelOptions{
+				Text:    html.EscapeString(caption),
+				Font:    labelFont(defaultLabelFont, false, false, c.CaptionSize),
+				X:       x + cellW/2,
+				Y:       y + boxHeight + (captionHeight-c.CaptionSize)/2,
+				Size:    c.CaptionSize,
+				Align:   AlignCenter,
+				Width:   cellW,
+				Color:   getColor(nil, c.CaptionColor),
+				Opacity: 1,
+			}); err != nil {
+				return
+			}
+		}
+	}
+	out, err := vipsCopyImage(canvas.image)
+	if err != nil {
+		return
+	}
+	img.setImage(out)
+	return
+}
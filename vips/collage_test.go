@@ -0,0 +1,55 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCollage(t *testing.T) {
+	c := parseCollage("b.jpg", "a%2Fc.png", "cols=3", "layout=masonry", "gutter=999",
+		"cell=200x150", "fit=contain", "captions=One|Two%20%26%20Three", "caption_size=0", "")
+	assert.Equal(t, []string{"b.jpg", "a/c.png"}, c.Images)
+	assert.Equal(t, 3, c.Cols)
+	assert.True(t, c.Masonry)
+	assert.Equal(t, 200, c.Gutter)
+	assert.Equal(t, 200, c.CellWidth)
+	assert.Equal(t, 150, c.CellHeight)
+	assert.Equal(t, "contain", c.Fit)
+	assert.Equal(t, []string{"One", "Two & Three"}, c.Captions)
+	assert.Equal(t, 1, c.CaptionSize)
+	assert.Equal(t, "One", c.caption(0))
+	assert.Equal(t, "", c.caption(2))
+
+	c = parseCollage("b.jpg", "dir/c.png", "captions")
+	assert.True(t, c.CaptionNames)
+	assert.Equal(t, "", c.caption(0))
+	assert.Equal(t, "c.png", c.caption(2))
+	assert.Equal(t, 21, c.captionHeight())
+	assert.Zero(t, parseCollage("b.jpg").captionHeight())
+
+	images := make([]string, 30)
+	for i := range images {
+		images[i] = "a.jpg"
+	}
+	assert.Len(t, parseCollage(images...).Images, collageMaxImages-1)
+}
+
+func TestGridLayout(t *testing.T) {
+	points, w, h := gridLayout(5, 2, 100, 50, 10, 0)
+	assert.Equal(t, [][2]int{{10, 10}, {120, 10}, {10, 70}, {120, 70}, {10, 130}}, points)
+	assert.Equal(t, 230, w)
+	assert.Equal(t, 190, h)
+
+	points, w, h = gridLayout(2, 2, 100, 50, 0, 20)
+	assert.Equal(t, [][2]int{{0, 0}, {100, 0}}, points)
+	assert.Equal(t, 200, w)
+	assert.Equal(t, 70, h)
+}
+
+func TestMasonryLayout(t *testing.T) {
+	points, w, h := masonryLayout([]int{100, 50, 30, 40}, 2, 100, 10, 0)
+	assert.Equal(t, [][2]int{{10, 10}, {120, 10}, {120, 70}, {120, 110}}, points)
+	assert.Equal(t, 230, w)
+	assert.Equal(t, 160, h)
+}
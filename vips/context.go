@@ -2,12 +2,19 @@ package vips
 
 import (
 	"context"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/xudaolong/imagor/vips/pointer"
 )
 
 type contextRefKey struct{}
 
 type contextRef struct {
 	cbs      []func()
+	ctx      context.Context
+	ptr      unsafe.Pointer
+	killed   atomic.Bool
 	Rotate90 bool
 }
 
@@ -20,11 +27,26 @@ func (r *contextRef) Done() {
 		cb()
 	}
 	r.cbs = nil
+	if r.ptr != nil {
+		pointer.Unref(r.ptr)
+		r.ptr = nil
+	}
+}
+
+// Pointer returns the C index pointer of the context ref for libvips callbacks
+func (r *contextRef) Pointer() unsafe.Pointer {
+	if r.ptr == nil {
+		r.ptr = pointer.Save(r)
+	}
+	return r.ptr
 }
 
 // withContext with callback tracking
 func withContext(ctx context.Context) context.Context {
-	return context.WithValue(ctx, contextRefKey{}, &contextRef{})
+	r := &contextRef{}
+	ctx = context.WithValue(ctx, contextRefKey{}, r)
+	r.ctx = ctx
+	return ctx
 }
 
 // contextDefer context add func for callback tracking for callback gc
@@ -37,6 +59,24 @@ func contextDone(ctx context.Context) {
 	ctx.Value(contextRefKey{}).(*contextRef).Done()
 }
 
+// contextEval kills libvips evaluation of the image pipeline once context is done
+func contextEval(ctx context.Context, img *Image) {
+	if img == nil {
+		return
+	}
+	if r, ok := ctx.Value(contextRefKey{}).(*contextRef); ok {
+		r.Defer(img.setEvalCallback(r.Pointer()))
+	}
+}
+
+// isContextKilled if libvips evaluation was killed by context done
+func isContextKilled(ctx context.Context) bool {
+	if r, ok := ctx.Value(contextRefKey{}).(*contextRef); ok {
+		return r.killed.Load()
+	}
+	return false
+}
+
 func setRotate90(ctx context.Context) {
 	if r, ok := ctx.Value(contextRefKey{}).(*contextRef); ok {
 		r.Rotate90 = !r.Rotate90
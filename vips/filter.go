@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"image/color"
 	"math"
+	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -15,8 +19,13 @@ import (
 )
 
 func (v *Processor) watermark(ctx context.Context, img *Image, load imagor.LoadFunc, args ...string) (err error) {
+	if len(args) < 1 {
+		return
+	}
+	opts, positional := parseWatermarkOptions(min(img.Width(), img.PageHeight()), args[1:]...)
+	args = append(args[:1:1], positional...)
 	ln := len(args)
-	if ln < 1 {
+	if img.Width() < opts.MinWidth || img.PageHeight() < opts.MinHeight {
 		return
 	}
 	image := args[0]
@@ -32,11 +41,17 @@ func (v *Processor) watermark(ctx context.Context, img *Image, load imagor.LoadF
 	var down = 1
 	var overlay *Image
 	var n = 1
-	if isAnimated(img) {
+	if isAnimated(img) && opts.Angle == 0 {
 		n = -1
 	}
-	// w_ratio h_ratio
-	if ln >= 6 {
+	// size or w_ratio h_ratio
+	if opts.Size > 0 {
+		if overlay, err = v.NewThumbnail(
+			ctx, blob, opts.Size, opts.Size, InterestingNone, SizeBoth, n, 1, 0,
+		); err != nil {
+			return
+		}
+	} else if ln >= 6 {
 		w = img.Width()
 		h = img.PageHeight()
 		if args[4] != "none" {
@@ -69,20 +84,47 @@ func (v *Processor) watermark(ctx context.Context, img *Image, load imagor.LoadF
 	if err = overlay.AddAlpha(); err != nil {
 		return
 	}
-	w = overlay.Width()
-	h = overlay.PageHeight()
-	// alpha
+	// alpha, applied per tile
+	alpha := opts.Alpha
 	if ln >= 4 {
-		alpha, _ := strconv.ParseFloat(args[3], 64)
-		alpha = 1 - alpha/100
-		if alpha != 1 {
-			if err = overlay.Linear([]float64{1, 1, 1, alpha}, []float64{0, 0, 0, 0}); err != nil {
-				return
-			}
+		alpha, _ = strconv.ParseFloat(args[3], 64)
+	}
+	if alpha = 1 - alpha/100; alpha != 1 {
+		if err = overlay.Linear([]float64{1, 1, 1, alpha}, []float64{0, 0, 0, 0}); err != nil {
+			return
 		}
 	}
+	if opts.Angle != 0 && !opts.Tile {
+		if err = overlay.Similarity(-opts.Angle, defaultInterpolator, &ColorRGBA{}); err != nil {
+			return
+		}
+	}
+	// spacing between tiles
+	repeatX := opts.Tile || (ln >= 3 && args[1] == "repeat")
+	repeatY := opts.Tile || (ln >= 3 && args[2] == "repeat")
+	if opts.Spacing > 0 && (repeatX || repeatY) {
+		var sx, sy int
+		if repeatX {
+			sx = opts.Spacing
+		}
+		if repeatY {
+			sy = opts.Spacing
+		}
+		if err = overlay.EmbedBackgroundRGBA(
+			0, 0, overlay.Width()+sx, overlay.PageHeight()+sy, &ColorRGBA{},
+		); err != nil {
+			return
+		}
+	}
+	w = overlay.Width()
+	h = overlay.PageHeight()
 	// x y
-	if ln >= 3 {
+	if opts.Tile {
+		across = img.Width()/w + 1
+		down = img.PageHeight()/h + 1
+	} else if opts.Gravity != "" {
+		x, y = gravityPosition(opts.Gravity, img.Width(), img.PageHeight(), w, h, opts.Margin)
+	} else if ln >= 3 {
 		if args[1] == "center" {
 			x = (img.Width() - overlay.Width()) / 2
 		} else if args[1] == imagorpath.HAlignLeft {
@@ -126,7 +168,25 @@ func (v *Processor) watermark(ctx context.Context, img *Image, load imagor.LoadF
 			y += img.PageHeight() - overlay.PageHeight()
 		}
 	}
-	if across*down > 1 {
+	if opts.Tile && opts.Angle != 0 {
+		// tile the diagonal so that the rotated pattern covers the image
+		d := int(math.Ceil(math.Hypot(float64(img.Width()), float64(img.PageHeight()))))
+		if err = v.checkDimensions(float64(d), float64(d)); err != nil {
+			return
+		}
+		if err = overlay.Embed(0, 0, d, d, ExtendRepeat); err != nil {
+			return
+		}
+		if err = overlay.Similarity(-opts.Angle, defaultInterpolator, &ColorRGBA{}); err != nil {
+			return
+		}
+		if err = overlay.ExtractArea(
+			(overlay.Width()-img.Width())/2, (overlay.PageHeight()-img.PageHeight())/2,
+			img.Width(), img.PageHeight(),
+		); err != nil {
+			return
+		}
+	} else if across*down > 1 {
 		if err = overlay.Embed(0, 0, across*w, down*h, ExtendRepeat); err != nil {
 			return
 		}
@@ -145,7 +205,7 @@ func (v *Processor) watermark(ctx context.Context, img *Image, load imagor.LoadF
 			return
 		}
 	}
-	if err = img.Composite(overlay, BlendModeOver, 0, 0); err != nil {
+	if err = img.Composite(overlay, opts.Blend, 0, 0); err != nil {
 		return
 	}
 	return
@@ -216,7 +276,7 @@ func (v *Processor) fill(ctx context.Context, img *Image, w, h int, pLeft, pTop,
 		}
 		if isTransparent {
 			if img.Bands() < 3 {
-				if err = img.ToColorSpace(InterpretationSRGB); err != nil {
+				if err = img.ToColorSpace(rgbInterpretation(img)); err != nil {
 					return
 				}
 			}
@@ -311,7 +371,7 @@ func roundCorner(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...str
 	return nil
 }
 
-func label(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+func (v *Processor) label(_ context.Context, img *Image, load imagor.LoadFunc, args ...string) (err error) {
 	ln := len(args)
 	if ln == 0 {
 		return
@@ -320,11 +380,12 @@ func label(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (er
 		args[0] = a
 	}
 	var text = args[0]
-	var font = "tahoma"
+	var font = defaultLabelFont
 	var x, y int
 	var c = &Color{}
 	var alpha float64
 	var align = AlignLow
+	var valign = AlignLow
 	var size = 20
 	var width = img.Width()
 	if ln > 3 {
@@ -353,10 +414,12 @@ func label(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (er
 	}
 	if ln > 2 {
 		if args[2] == "center" {
+			valign = AlignCenter
 			y = (img.PageHeight() - size) / 2
 		} else if args[2] == imagorpath.VAlignTop {
 			y = 0
 		} else if args[2] == imagorpath.VAlignBottom {
+			valign = AlignHigh
 			y = img.PageHeight() - size
 		} else if strings.HasPrefix(strings.TrimPrefix(args[2], "-"), "0.") {
 			pec, _ := strconv.ParseFloat(args[2], 64)
@@ -368,21 +431,32 @@ func label(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (er
 			y, _ = strconv.Atoi(args[2])
 		}
 		if y < 0 {
+			valign = AlignHigh
 			y += img.PageHeight() - size
 		}
 	}
 	if ln > 4 {
 		c = getColor(img, args[4])
 	}
-	if ln > 5 {
-		alpha, _ = strconv.ParseFloat(args[5], 64)
+	// alpha and font followed by rich text options in any order
+	var options []string
+	var positional []string
+	for i := 5; i < ln; i++ {
+		if isLabelOption(args[i]) {
+			options = append(options, args[i])
+		} else {
+			positional = append(positional, args[i])
+		}
+	}
+	if len(positional) > 0 {
+		alpha, _ = strconv.ParseFloat(positional[0], 64)
 		alpha /= 100
 	}
-	if ln > 6 {
-		if a, e := url.QueryUnescape(args[6]); e == nil {
+	if len(positional) > 1 {
+		if a, e := url.QueryUnescape(positional[1]); e == nil {
 			font = a
 		} else {
-			font = args[6]
+			font = positional[1]
 		}
 	}
 	if img.Bands() < 3 {
@@ -393,7 +467,23 @@ func label(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (er
 	if err = img.AddAlpha(); err != nil {
 		return
 	}
-	return img.Label(text, font, x, y, size, align, c, 1-alpha)
+	if len(options) == 0 {
+		return img.Label(text, font, x, y, size, align, c, 1-alpha)
+	}
+	opts := &LabelOptions{
+		Text:    text,
+		X:       x,
+		Y:       y,
+		Size:    size,
+		Align:   align,
+		VAlign:  valign,
+		Color:   c,
+		Opacity: 1 - alpha,
+	}
+	if err = v.parseLabelOptions(img, load, opts, font, options...); err != nil {
+		return
+	}
+	return img.RichLabel(opts)
 }
 
 func (v *Processor) padding(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) error {
@@ -433,18 +523,104 @@ func rotate(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string)
 	if len(args) == 0 {
 		return
 	}
-	if angle, _ := strconv.Atoi(args[0]); angle > 0 {
+	angle, _ := strconv.ParseFloat(strings.TrimSpace(args[0]), 64)
+	if angle = math.Mod(angle, 360); angle < 0 {
+		angle += 360
+	}
+	if angle == 0 || math.IsNaN(angle) {
+		return
+	}
+	if math.Mod(angle, 90) == 0 {
 		switch angle {
 		case 90, 270:
 			setRotate90(ctx)
 		}
-		if err = img.Rotate(getAngle(angle)); err != nil {
-			return err
+		return img.Rotate(getAngle(int(angle)))
+	}
+	if isAnimated(img) {
+		// interpolated rotation not applicable to animation
+		return
+	}
+	w, h := img.Width(), img.PageHeight()
+	opts := parseTransformOptions(img, args[1:]...)
+	if err = opts.prepare(img); err != nil {
+		return
+	}
+	// counterclockwise as multiples of 90
+	if err = img.Similarity(-angle, opts.Interpolator, opts.Background); err != nil {
+		return
+	}
+	if opts.Crop {
+		if cw, ch := inscribedRect(w, h, angle); cw > 0 && ch > 0 {
+			return img.ExtractArea((img.Width()-cw)/2, (img.PageHeight()-ch)/2, cw, ch)
 		}
 	}
 	return
 }
 
+func (v *Processor) affine(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) < 4 || isAnimated(img) {
+		return
+	}
+	var m [4]float64
+	for i := range m {
+		if m[i], err = strconv.ParseFloat(strings.TrimSpace(args[i]), 64); err != nil {
+			return nil
+		}
+	}
+	if det := m[0]*m[3] - m[1]*m[2]; math.Abs(det) < 1e-6 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return // no ops
+	}
+	w, h := float64(img.Width()), float64(img.PageHeight())
+	if err = v.checkDimensions(
+		math.Abs(m[0])*w+math.Abs(m[1])*h, math.Abs(m[2])*w+math.Abs(m[3])*h,
+	); err != nil {
+		return
+	}
+	opts := parseTransformOptions(img, args[4:]...)
+	if err = opts.prepare(img); err != nil {
+		return
+	}
+	return img.Affine(m[0], m[1], m[2], m[3], opts.Interpolator, opts.Background)
+}
+
+func (v *Processor) perspective(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) < 8 || isAnimated(img) {
+		return
+	}
+	var quad [4][2]float64
+	var fraction = true
+	for i := 0; i < 8; i++ {
+		if quad[i/2][i%2], err = strconv.ParseFloat(strings.TrimSpace(args[i]), 64); err != nil {
+			return nil
+		}
+		fraction = fraction && quad[i/2][i%2] <= 1
+	}
+	if fraction {
+		// fraction of the image dimensions
+		for i := range quad {
+			quad[i][0] *= float64(img.Width())
+			quad[i][1] *= float64(img.PageHeight())
+		}
+	}
+	width, height := perspectiveSize(quad)
+	if width <= 0 || height <= 0 {
+		return
+	}
+	if err = v.checkDimensions(float64(width), float64(height)); err != nil {
+		return
+	}
+	hm, ok := homography(float64(width), float64(height), quad)
+	if !ok {
+		return
+	}
+	opts := parseTransformOptions(img, args[8:]...)
+	if err = opts.prepare(img); err != nil {
+		return
+	}
+	return img.Perspective(hm, width, height, opts.Interpolator, opts.Background)
+}
+
 func getAngle(angle int) Angle {
 	switch angle {
 	case 90:
@@ -481,6 +657,9 @@ func proportion(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string
 }
 
 func grayscale(_ context.Context, img *Image, _ imagor.LoadFunc, _ ...string) (err error) {
+	if img.Is16Bit() {
+		return img.ToColorSpace(InterpretationGrey16)
+	}
 	return img.ToColorSpace(InterpretationBW)
 }
 
@@ -597,6 +776,117 @@ func stripExif(_ context.Context, img *Image, _ imagor.LoadFunc, _ ...string) (e
 	return img.RemoveExif()
 }
 
+func colorspace(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	intent := parseIntent(args[1:]...)
+	switch strings.ToLower(strings.TrimSpace(args[0])) {
+	case "srgb":
+		if img.HasICCProfile() {
+			return img.ICCTransform("srgb", defaultInputProfile(img), intent, iccDepth(img))
+		}
+		return img.ToColorSpace(rgbInterpretation(img))
+	case "p3":
+		return img.ICCTransform("p3", defaultInputProfile(img), intent, iccDepth(img))
+	case "cmyk":
+		if img.HasAlpha() {
+			// CMYK output formats do not support alpha
+			if err = img.Flatten(&Color{R: 255, G: 255, B: 255}); err != nil {
+				return
+			}
+		}
+		return img.ICCTransform("cmyk", defaultInputProfile(img), intent, 8)
+	case "gray", "grey":
+		if img.HasICCProfile() {
+			if err = img.ICCTransform("srgb", defaultInputProfile(img), intent, iccDepth(img)); err != nil {
+				return
+			}
+		}
+		if img.Is16Bit() {
+			err = img.ToColorSpace(InterpretationGrey16)
+		} else {
+			err = img.ToColorSpace(InterpretationBW)
+		}
+		if err != nil {
+			return
+		}
+		// RGB profile not applicable to grayscale
+		return img.RemoveICCProfile()
+	}
+	return
+}
+
+func (v *Processor) icc(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 || v.ICCProfilesDir == "" {
+		return
+	}
+	path, ok := findNamedFile(v.ICCProfilesDir, strings.TrimSpace(args[0]), ".icc", ".icm")
+	if !ok {
+		return imagor.NewError("icc profile not found", http.StatusBadRequest)
+	}
+	return img.ICCTransform(path, defaultInputProfile(img), parseIntent(args[1:]...), iccDepth(img))
+}
+
+// hasICCFilter if filters contain colorspace or icc filter embedding ICC profile
+func hasICCFilter(filters imagorpath.Filters) bool {
+	for _, f := range filters {
+		if f.Name == "colorspace" || f.Name == "icc" {
+			return true
+		}
+	}
+	return false
+}
+
+// findNamedFile finds named file in dir, with optional extensions
+func findNamedFile(dir, name string, exts ...string) (string, bool) {
+	if !fileNameRegexp.MatchString(name) {
+		return "", false
+	}
+	for _, ext := range append([]string{""}, exts...) {
+		path := filepath.Join(dir, name+ext)
+		if stat, err := os.Stat(path); err == nil && stat.Mode().IsRegular() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+var fileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+func parseIntent(args ...string) Intent {
+	if len(args) > 0 {
+		if intent, ok := intentMap[strings.ToLower(strings.TrimSpace(args[0]))]; ok {
+			return intent
+		}
+	}
+	return IntentRelative
+}
+
+// defaultInputProfile input profile if image has no embedded profile
+func defaultInputProfile(img *Image) string {
+	if img.Interpretation() == InterpretationCMYK {
+		return "cmyk"
+	}
+	return "srgb"
+}
+
+// iccDepth preserves 16-bit depth on ICC transform
+func iccDepth(img *Image) int {
+	if img.Is16Bit() {
+		return 16
+	}
+	return 8
+}
+
+// rgbInterpretation sRGB interpretation preserving 16-bit depth
+func rgbInterpretation(img *Image) Interpretation {
+	if img.Is16Bit() {
+		return InterpretationRGB16
+	}
+	return InterpretationSRGB
+}
+
 func trim(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) error {
 	var (
 		ln        = len(args)
@@ -696,3 +986,16 @@ func hexToByte(b byte) byte {
 func isAnimated(img *Image) bool {
 	return img.Height() > img.PageHeight()
 }
+
+// firstFrame reduces animated image to its first frame
+func firstFrame(img *Image) error {
+	if !isAnimated(img) {
+		return nil
+	}
+	pageHeight := img.PageHeight()
+	// single page so that extract area does not apply to every frame
+	if err := img.SetPageHeight(img.Height()); err != nil {
+		return err
+	}
+	return img.ExtractArea(0, 0, img.Width(), pageHeight)
+}
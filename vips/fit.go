@@ -0,0 +1,348 @@
+package vips
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/xudaolong/imagor/imagorpath"
+)
+
+// fitModes resize modes of fit filter as per CSS object-fit
+var fitModes = map[string]bool{
+	"cover":   true,
+	"contain": true,
+	"fill":    true,
+	"inside":  true,
+	"outside": true,
+}
+
+// FitInfo resize mode and exact output dimensions of the resize
+type FitInfo struct {
+	Mode   string `json:"mode"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// resizeFit resize mode of fit filter with min and max dimension constraints
+type resizeFit struct {
+	mode      string
+	minWidth  int
+	minHeight int
+	maxWidth  int
+	maxHeight int
+
+	// box dimensions resolved after the image loaded
+	resolve   bool
+	boxWidth  int
+	boxHeight int
+	// contain pads the resized image to the box
+	pad bool
+	// source dimensions decoded from header if shrunk on load
+	srcWidth  int
+	srcHeight int
+
+	// processor limits the min constraints clamped to
+	limitWidth      int
+	limitHeight     int
+	limitResolution int
+
+	width  int
+	height int
+}
+
+func (v *Processor) newResizeFit() *resizeFit {
+	return &resizeFit{
+		limitWidth:      v.MaxWidth,
+		limitHeight:     v.MaxHeight,
+		limitResolution: v.MaxResolution,
+	}
+}
+
+// parse parses fit mode and constraint filters, returns true if handled
+func (f *resizeFit) parse(name, args string) bool {
+	args = strings.TrimSpace(args)
+	if name == "fit" {
+		if mode := strings.ToLower(args); fitModes[mode] {
+			f.mode = mode
+			return true
+		}
+		return false
+	}
+	n, _ := strconv.Atoi(args)
+	if n <= 0 {
+		return false
+	}
+	switch name {
+	case "min_width":
+		f.minWidth = n
+	case "min_height":
+		f.minHeight = n
+	case "max_width":
+		f.maxWidth = n
+	case "max_height":
+		f.maxHeight = n
+	default:
+		return false
+	}
+	return true
+}
+
+// IsActive fit mode or constraints specified
+func (f *resizeFit) IsActive() bool {
+	return f.mode != "" || f.minWidth > 0 || f.minHeight > 0 || f.maxWidth > 0 || f.maxHeight > 0
+}
+
+// apply translates fit mode and constraints to params where resolvable without the image dimensions,
+// so that shrink-on-load applies. Otherwise the box is resolved after the image loaded
+func (f *resizeFit) apply(p *imagorpath.Params, stretch *bool) {
+	if f.mode == "" {
+		// constraints of the path resize mode
+		if p.FitIn {
+			f.mode = "inside"
+		} else if *stretch {
+			f.mode = "fill"
+		} else {
+			f.mode = "cover"
+		}
+	}
+	w, h := p.Width, p.Height
+	switch f.mode {
+	case "cover", "fill", "contain":
+		p.FitIn = f.mode == "contain"
+		*stretch = f.mode == "fill"
+		if w > 0 && h > 0 {
+			// output dimensions are the box
+			p.Width, p.Height = f.constrain(w, h)
+			f.pad = f.mode == "contain"
+			return
+		}
+	case "inside":
+		p.FitIn = true
+		*stretch = false
+		if f.minWidth == 0 && f.minHeight == 0 && (w > 0 || h > 0) {
+			// max constraints within the box
+			if f.maxWidth > 0 && (w == 0 || w > f.maxWidth) {
+				p.Width = f.maxWidth
+			}
+			if f.maxHeight > 0 && (h == 0 || h > f.maxHeight) {
+				p.Height = f.maxHeight
+			}
+			return
+		}
+	}
+	// resolved proportionally to the image dimensions
+	f.resolve = true
+	f.boxWidth, f.boxHeight = w, h
+	p.Width, p.Height = 0, 0
+	p.FitIn = false
+	*stretch = false
+}
+
+// constrain scales w x h proportionally to satisfy min constraints, then max constraints
+func (f *resizeFit) constrain(w, h int) (int, int) {
+	if w <= 0 || h <= 0 {
+		return w, h
+	}
+	fw, fh := float64(w), float64(h)
+	s := 1.0
+	if f.minWidth > 0 {
+		s = math.Max(s, float64(f.minWidth)/fw)
+	}
+	if f.minHeight > 0 {
+		s = math.Max(s, float64(f.minHeight)/fh)
+	}
+	if s > 1 {
+		// min constraints within the processor limits
+		s = math.Max(1, math.Min(s, f.limitScale(fw, fh)))
+	}
+	if f.maxWidth > 0 {
+		s = math.Min(s, float64(f.maxWidth)/fw)
+	}
+	if f.maxHeight > 0 {
+		s = math.Min(s, float64(f.maxHeight)/fh)
+	}
+	if s == 1 {
+		return w, h
+	}
+	return max(1, int(math.Round(fw*s))), max(1, int(math.Round(fh*s)))
+}
+
+// limitScale max scale of fw x fh within the processor limits
+func (f *resizeFit) limitScale(fw, fh float64) float64 {
+	s := math.Inf(1)
+	if f.limitWidth > 0 {
+		s = math.Min(s, float64(f.limitWidth)/fw)
+	}
+	if f.limitHeight > 0 {
+		s = math.Min(s, float64(f.limitHeight)/fh)
+	}
+	if f.limitResolution > 0 {
+		s = math.Min(s, math.Sqrt(float64(f.limitResolution)/(fw*fh)))
+	}
+	return s
+}
+
+// size exact output dimensions of width x height resized to the box by fit mode,
+// proportional if either box dimension is 0
+func (f *resizeFit) size(width, height int, upscale bool) (int, int) {
+	if width <= 0 || height <= 0 {
+		return width, height
+	}
+	w, h := float64(f.boxWidth), float64(f.boxHeight)
+	fw, fh := float64(width), float64(height)
+	var s float64
+	switch {
+	case w == 0 && h == 0:
+		s = 1
+	case w == 0:
+		s = h / fh
+	case h == 0:
+		s = w / fw
+	case f.mode == "outside":
+		s = math.Max(w/fw, h/fh)
+	default:
+		s = math.Min(w/fw, h/fh)
+	}
+	if !upscale {
+		s = math.Min(s, 1)
+	}
+	return f.constrain(max(1, int(math.Round(fw*s))), max(1, int(math.Round(fh*s))))
+}
+
+// resolveParams resolves pending dimensions and the fit box of params
+// by the source image dimensions of width x height
+func (f *resizeFit) resolveParams(p *imagorpath.Params, stretch, upscale *bool, width, height int) {
+	if pendingDimensions(*p) {
+		resolveDimensions(p, width, height)
+		if f.IsActive() {
+			f.apply(p, stretch)
+		}
+	}
+	if f.resolve {
+		p.Width, p.Height = f.size(width, height, *upscale)
+		p.FitIn = false
+		*stretch, *upscale = true, true
+	}
+}
+
+// shrinkSize longer side of the source image of width x height to shrink on load,
+// so that params resolved in either orientation not upscaled from the loaded image,
+// by ratio of the loaded image to the output. Returns 0 if the image not to be shrunk
+func (f *resizeFit) shrinkSize(p imagorpath.Params, width, height int, stretch, upscale bool, ratio float64) int {
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+	var s float64
+	for _, size := range [][2]int{{width, height}, {height, width}} {
+		pp, ff, st, up := p, *f, stretch, upscale
+		ff.resolveParams(&pp, &st, &up, size[0], size[1])
+		s = math.Max(s, math.Max(float64(pp.Width)/float64(size[0]), float64(pp.Height)/float64(size[1])))
+	}
+	s *= ratio
+	if s <= 0 || s >= 1 {
+		return 0
+	}
+	return int(math.Ceil(float64(max(width, height)) * s))
+}
+
+// sourceSize dimensions of the source image oriented as img,
+// from header if the image shrunk on load
+func (f *resizeFit) sourceSize(img *Image) (int, int) {
+	width, height := img.Width(), img.PageHeight()
+	if img.Orientation() > 4 {
+		width, height = height, width
+	}
+	if f.srcWidth <= 0 || f.srcHeight <= 0 || width <= 0 || height <= 0 {
+		return width, height
+	}
+	// orientation closest to the aspect ratio of the loaded image
+	ratio := float64(width) / float64(height)
+	if math.Abs(float64(f.srcWidth)/float64(f.srcHeight)-ratio) <=
+		math.Abs(float64(f.srcHeight)/float64(f.srcWidth)-ratio) {
+		return f.srcWidth, f.srcHeight
+	}
+	return f.srcHeight, f.srcWidth
+}
+
+// info fit mode and output dimensions for metadata
+func (f *resizeFit) info() *FitInfo {
+	return &FitInfo{Mode: f.mode, Width: f.width, Height: f.height}
+}
+
+// pendingDimensions if dimensions of params depend on the image dimensions
+func pendingDimensions(p imagorpath.Params) bool {
+	return p.WidthPercent > 0 || p.HeightPercent > 0 ||
+		(p.AspectWidth > 0 && p.AspectHeight > 0 && p.Width == 0 && p.Height == 0)
+}
+
+// resolveDimensions resolves percentage and aspect ratio dimensions of params,
+// by width x height of the image if known
+func resolveDimensions(p *imagorpath.Params, width, height int) {
+	if width > 0 && height > 0 {
+		if p.WidthPercent > 0 {
+			p.Width = max(1, int(math.Round(float64(width)*p.WidthPercent/100)))
+			p.WidthPercent = 0
+		}
+		if p.HeightPercent > 0 {
+			p.Height = max(1, int(math.Round(float64(height)*p.HeightPercent/100)))
+			p.HeightPercent = 0
+		}
+	}
+	if p.AspectWidth <= 0 || p.AspectHeight <= 0 || p.WidthPercent > 0 || p.HeightPercent > 0 {
+		return
+	}
+	aspect := p.AspectWidth / p.AspectHeight
+	switch {
+	case p.Width > 0 && p.Height == 0:
+		p.Height = max(1, int(math.Round(float64(p.Width)/aspect)))
+	case p.Height > 0 && p.Width == 0:
+		p.Width = max(1, int(math.Round(float64(p.Height)*aspect)))
+	case p.Width == 0 && p.Height == 0 && width > 0 && height > 0:
+		// largest area of the aspect ratio within the image
+		if float64(width)/float64(height) > aspect {
+			p.Width, p.Height = max(1, int(math.Round(float64(height)*aspect))), height
+		} else {
+			p.Width, p.Height = width, max(1, int(math.Round(float64(width)/aspect)))
+		}
+	default:
+		return
+	}
+	p.AspectWidth, p.AspectHeight, p.AspectOnWidth = 0, 0, false
+}
+
+// gravityOffset position of crop within the free space by gravity from 0 to 1,
+// moved inwards from the edge by offset, clamped within the space
+func gravityOffset(space int, g float64, offset int) int {
+	pos := float64(space) * g
+	if g == 1 {
+		pos -= float64(offset)
+	} else {
+		pos += float64(offset)
+	}
+	return max(0, min(int(math.Round(pos)), space))
+}
+
+// containPad pads the image centered to w x h,
+// transparent if the image has alpha, black otherwise
+func (v *Processor) containPad(ctx context.Context, img *Image, w, h int) error {
+	if img.Width() >= w && img.PageHeight() >= h {
+		return nil
+	}
+	colour := "black"
+	if img.HasAlpha() {
+		colour = "none"
+	}
+	return v.fill(ctx, img, max(w, img.Width()), max(h, img.PageHeight()), 0, 0, 0, 0, colour)
+}
+
+// hasFilter if filters contain the enabled filter of name
+func (v *Processor) hasFilter(filters imagorpath.Filters, name string) bool {
+	for _, f := range filters {
+		if f.Name == name && !v.disableFilters[name] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xudaolong/imagor/imagorpath"
+)
+
+func TestResizeFitParse(t *testing.T) {
+	f := &resizeFit{}
+	assert.False(t, f.IsActive())
+	assert.False(t, f.parse("fit", "scale-down"))
+	assert.False(t, f.parse("max_width", "-1"))
+	assert.False(t, f.IsActive())
+	assert.True(t, f.parse("fit", " Cover"))
+	assert.True(t, f.parse("min_width", "100"))
+	assert.True(t, f.parse("max_height", "300"))
+	assert.Equal(t, "cover", f.mode)
+	assert.Equal(t, 100, f.minWidth)
+	assert.Equal(t, 300, f.maxHeight)
+	assert.True(t, f.IsActive())
+}
+
+func TestResizeFitApply(t *testing.T) {
+	t.Run("cover constrained box", func(t *testing.T) {
+		f := &resizeFit{mode: "cover", maxWidth: 200}
+		p := imagorpath.Params{FitIn: true, Width: 400, Height: 300}
+		stretch := true
+		f.apply(&p, &stretch)
+		assert.False(t, p.FitIn)
+		assert.False(t, stretch)
+		assert.False(t, f.resolve)
+		assert.Equal(t, []int{200, 150}, []int{p.Width, p.Height})
+	})
+	t.Run("contain pads", func(t *testing.T) {
+		f := &resizeFit{mode: "contain", minHeight: 600}
+		p := imagorpath.Params{Width: 400, Height: 300}
+		stretch := false
+		f.apply(&p, &stretch)
+		assert.True(t, p.FitIn)
+		assert.True(t, f.pad)
+		assert.Equal(t, []int{800, 600}, []int{p.Width, p.Height})
+	})
+	t.Run("fill", func(t *testing.T) {
+		f := &resizeFit{mode: "fill"}
+		p := imagorpath.Params{Width: 400, Height: 300}
+		stretch := false
+		f.apply(&p, &stretch)
+		assert.True(t, stretch)
+		assert.Equal(t, []int{400, 300}, []int{p.Width, p.Height})
+	})
+	t.Run("inside max within box", func(t *testing.T) {
+		f := &resizeFit{maxWidth: 300}
+		p := imagorpath.Params{FitIn: true, Height: 300}
+		stretch := false
+		f.apply(&p, &stretch)
+		assert.Equal(t, "inside", f.mode)
+		assert.True(t, p.FitIn)
+		assert.False(t, f.resolve)
+		assert.Equal(t, []int{300, 300}, []int{p.Width, p.Height})
+	})
+	t.Run("outside resolved", func(t *testing.T) {
+		f := &resizeFit{mode: "outside"}
+		p := imagorpath.Params{Width: 400, Height: 300}
+		stretch := false
+		f.apply(&p, &stretch)
+		assert.True(t, f.resolve)
+		assert.False(t, p.FitIn)
+		assert.Equal(t, []int{0, 0}, []int{p.Width, p.Height})
+		assert.Equal(t, []int{400, 300}, []int{f.boxWidth, f.boxHeight})
+	})
+}
+
+func TestResizeFitSize(t *testing.T) {
+	f := &resizeFit{mode: "outside", boxWidth: 400, boxHeight: 300}
+	w, h := f.size(1000, 500, true)
+	assert.Equal(t, []int{600, 300}, []int{w, h})
+
+	f = &resizeFit{mode: "inside", boxWidth: 400, boxHeight: 300}
+	w, h = f.size(1000, 500, true)
+	assert.Equal(t, []int{400, 200}, []int{w, h})
+	w, h = f.size(100, 50, false)
+	assert.Equal(t, []int{100, 50}, []int{w, h})
+
+	f = &resizeFit{mode: "inside", boxWidth: 400, boxHeight: 300, minHeight: 250}
+	w, h = f.size(1000, 500, true)
+	assert.Equal(t, []int{500, 250}, []int{w, h})
+
+	f = &resizeFit{mode: "cover", boxHeight: 100, maxWidth: 150}
+	w, h = f.size(1000, 500, true)
+	assert.Equal(t, []int{150, 75}, []int{w, h})
+
+	// max constraints win over min constraints
+	f = &resizeFit{minWidth: 500, maxHeight: 100}
+	w, h = f.size(200, 100, true)
+	assert.Equal(t, []int{200, 100}, []int{w, h})
+
+	// min constraints clamped to the processor limits
+	f = &resizeFit{mode: "inside", minWidth: 60000, limitWidth: 1000, limitHeight: 1000, limitResolution: 400000}
+	w, h = f.size(200, 100, true)
+	assert.Equal(t, []int{894, 447}, []int{w, h})
+	f = &resizeFit{minWidth: 60000, limitWidth: 1000, limitHeight: 1000}
+	w, h = f.size(2000, 1000, true)
+	assert.Equal(t, []int{2000, 1000}, []int{w, h})
+}
+
+func TestResizeFitShrinkSize(t *testing.T) {
+	f := &resizeFit{mode: "outside"}
+	p := imagorpath.Params{Width: 400, Height: 300}
+	stretch := false
+	f.apply(&p, &stretch)
+	assert.Equal(t, 800, f.shrinkSize(p, 2000, 1000, stretch, true, 1))
+	assert.Equal(t, 0, f.shrinkSize(p, 200, 100, stretch, true, 1))
+	assert.Equal(t, 0, f.shrinkSize(p, 0, 0, stretch, true, 1))
+
+	f = &resizeFit{mode: "inside", minHeight: 300}
+	p = imagorpath.Params{Width: 400}
+	f.apply(&p, &stretch)
+	assert.Equal(t, 800, f.shrinkSize(p, 4000, 2000, stretch, false, 1))
+
+	// percentage and aspect ratio dimensions
+	f = &resizeFit{}
+	p = imagorpath.Params{WidthPercent: 10, AspectWidth: 1, AspectHeight: 1}
+	assert.Equal(t, 800, f.shrinkSize(p, 4000, 2000, false, true, 1))
+	p = imagorpath.Params{WidthPercent: 100}
+	assert.Equal(t, 0, f.shrinkSize(p, 4000, 2000, false, true, 1))
+	p = imagorpath.Params{AspectWidth: 1, AspectHeight: 1}
+	assert.Equal(t, 0, f.shrinkSize(p, 4000, 2000, false, true, 1))
+
+	// ratio of the resampling kernel
+	p = imagorpath.Params{Width: 200}
+	assert.Equal(t, 400, f.shrinkSize(p, 4000, 2000, false, true, 1))
+	assert.Equal(t, 800, f.shrinkSize(p, 4000, 2000, false, true, 2))
+	assert.Equal(t, 0, f.shrinkSize(p, 400, 200, false, true, 2))
+}
+
+func TestResolveDimensions(t *testing.T) {
+	p := imagorpath.Params{Width: 800, AspectWidth: 16, AspectHeight: 9}
+	assert.False(t, pendingDimensions(p))
+	resolveDimensions(&p, 0, 0)
+	assert.Equal(t, []int{800, 450}, []int{p.Width, p.Height})
+	assert.Zero(t, p.AspectWidth)
+
+	p = imagorpath.Params{Height: 100, AspectWidth: 2.35, AspectHeight: 1}
+	resolveDimensions(&p, 0, 0)
+	assert.Equal(t, []int{235, 100}, []int{p.Width, p.Height})
+
+	p = imagorpath.Params{WidthPercent: 30, AspectWidth: 1, AspectHeight: 1}
+	assert.True(t, pendingDimensions(p))
+	resolveDimensions(&p, 0, 0)
+	assert.True(t, pendingDimensions(p))
+	resolveDimensions(&p, 1000, 500)
+	assert.False(t, pendingDimensions(p))
+	assert.Equal(t, []int{300, 300}, []int{p.Width, p.Height})
+
+	p = imagorpath.Params{WidthPercent: 50, HeightPercent: 12.5}
+	resolveDimensions(&p, 1000, 500)
+	assert.Equal(t, []int{500, 63}, []int{p.Width, p.Height})
+
+	// largest area of the aspect ratio within the image
+	p = imagorpath.Params{AspectWidth: 16, AspectHeight: 9}
+	assert.True(t, pendingDimensions(p))
+	resolveDimensions(&p, 1000, 1000)
+	assert.Equal(t, []int{1000, 563}, []int{p.Width, p.Height})
+	p = imagorpath.Params{AspectWidth: 1, AspectHeight: 2}
+	resolveDimensions(&p, 1000, 1000)
+	assert.Equal(t, []int{500, 1000}, []int{p.Width, p.Height})
+}
+
+func TestGravityOffset(t *testing.T) {
+	assert.Equal(t, 10, gravityOffset(100, 0, 10))
+	assert.Equal(t, 90, gravityOffset(100, 1, 10))
+	assert.Equal(t, 60, gravityOffset(100, 0.5, 10))
+	assert.Equal(t, 0, gravityOffset(100, 0, -10))
+	assert.Equal(t, 100, gravityOffset(100, 1, -10))
+	assert.Equal(t, 0, gravityOffset(0, 1, 20))
+}
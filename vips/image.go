@@ -6,9 +6,11 @@ import "C"
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
+	"unsafe"
 )
 
 // Image contains a libvips image and manages its lifecycle.
@@ -219,6 +221,22 @@ func (r *Image) Close() {
 	r.lock.Unlock()
 }
 
+// setEvalCallback connects libvips eval callback of the current image,
+// which kills the evaluation of downstream pipeline if context ref of ptr is done.
+// Returns func that disconnects the callback
+func (r *Image) setEvalCallback(ptr unsafe.Pointer) func() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	in := r.image
+	if in == nil {
+		return func() {}
+	}
+	handlerID := vipsSetImageEval(in, ptr)
+	return func() {
+		vipsUnsetImageEval(in, handlerID)
+	}
+}
+
 // Format returns the initial format of the vips image when loaded.
 func (r *Image) Format() ImageType {
 	return r.format
@@ -302,6 +320,65 @@ func (r *Image) SetPageDelay(delay []int) error {
 	return vipsImageSetDelay(r.image, data)
 }
 
+// PageDelay returns the page delay array in milliseconds for animation
+func (r *Image) PageDelay() []int {
+	return vipsGetMetaArrayInt(r.image, "delay")
+}
+
+// Loop returns the animation loop count, 0 for infinite, -1 if not specified
+func (r *Image) Loop() int {
+	if loop, ok := vipsGetMetaInt(r.image, "loop"); ok {
+		return loop
+	}
+	return -1
+}
+
+// XMP returns the raw XMP packet
+func (r *Image) XMP() []byte {
+	return vipsGetMetaBlob(r.image, "xmp-data")
+}
+
+// IPTC returns the raw IPTC data
+func (r *Image) IPTC() []byte {
+	return vipsGetMetaBlob(r.image, "iptc-data")
+}
+
+// ICCProfile returns the embedded ICC profile
+func (r *Image) ICCProfile() []byte {
+	return vipsGetMetaBlob(r.image, "icc-profile-data")
+}
+
+// BitDepth returns the number of bits per sample
+func (r *Image) BitDepth() int {
+	if bits, ok := vipsGetMetaInt(r.image, "bits-per-sample"); ok && bits > 0 {
+		return bits
+	}
+	switch r.image.BandFmt {
+	case C.VIPS_FORMAT_UCHAR, C.VIPS_FORMAT_CHAR:
+		return 8
+	case C.VIPS_FORMAT_USHORT, C.VIPS_FORMAT_SHORT:
+		return 16
+	case C.VIPS_FORMAT_UINT, C.VIPS_FORMAT_INT, C.VIPS_FORMAT_FLOAT:
+		return 32
+	case C.VIPS_FORMAT_DOUBLE, C.VIPS_FORMAT_COMPLEX:
+		return 64
+	case C.VIPS_FORMAT_DPCOMPLEX:
+		return 128
+	}
+	return 0
+}
+
+// DPI returns the horizontal resolution in dots per inch
+func (r *Image) DPI() float64 {
+	return math.Round(float64(r.image.Xres)*25.4*100) / 100
+}
+
+// HasEmbeddedThumbnail returns if the image has Exif embedded thumbnail
+func (r *Image) HasEmbeddedThumbnail() bool {
+	return vipsHasMeta(r.image, "exif-ifd1-JPEGInterchangeFormat") ||
+		vipsHasMeta(r.image, "exif-ifd1-JPEGInterchangeFormatLength")
+}
+
 // Exif extracts Exif key value data
 func (r *Image) Exif() map[string]any {
 	return vipsImageGetExif(r.image)
@@ -429,6 +506,46 @@ func (r *Image) Composite(overlay *Image, mode BlendMode, x, y int) error {
 	return nil
 }
 
+// LuminanceMask converts image to grey with alpha of its luminance for masking
+func (r *Image) LuminanceMask() error {
+	out, err := vipsLuminanceMask(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// MapLUT maps the RGB bands by lookup table of interleaved RGB entries, preserving alpha
+func (r *Image) MapLUT(lut []float64) error {
+	out, err := vipsMapLUT(r.image, lut)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Recomb recombines the RGB bands by 3x3 matrix, preserving alpha
+func (r *Image) Recomb(matrix [9]float64) error {
+	out, err := vipsRecomb(r.image, matrix)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Equalize histogram equalizes the RGB bands, preserving alpha
+func (r *Image) Equalize() error {
+	out, err := vipsEqualize(r.image)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // AddAlpha adds an alpha channel to the associated image.
 func (r *Image) AddAlpha() error {
 	if vipsHasAlpha(r.image) {
@@ -499,6 +616,51 @@ func (r *Image) RemoveExif() error {
 	return nil
 }
 
+// MetadataFields returns names of the metadata fields of the image
+func (r *Image) MetadataFields() []string {
+	return vipsImageGetFields(r.image)
+}
+
+// UpdateMetadata removes fields, then sets string and blob fields of the image metadata
+func (r *Image) UpdateMetadata(remove []string, strs map[string]string, blobs map[string][]byte) error {
+	out, err := vipsCopyImage(r.image)
+	if err != nil {
+		return err
+	}
+	for _, name := range remove {
+		vipsImageRemoveMeta(out, name)
+	}
+	for name, value := range strs {
+		vipsImageSetMetaString(out, name, value)
+	}
+	for name, buf := range blobs {
+		vipsImageSetMetaBlob(out, name, buf)
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Is16Bit returns if the image is 16-bit RGB or grayscale
+func (r *Image) Is16Bit() bool {
+	return int(C.is_16bit(r.image.Type)) == 1
+}
+
+// HasICCProfile returns if the image has embedded ICC profile
+func (r *Image) HasICCProfile() bool {
+	return vipsHasMeta(r.image, "icc-profile-data")
+}
+
+// ICCTransform transforms the image to the output ICC profile, built-in srgb, p3, cmyk or a file path,
+// from the embedded profile or inputProfile if not embedded. The output profile is embedded
+func (r *Image) ICCTransform(outputProfile, inputProfile string, intent Intent, depth int) error {
+	out, err := vipsICCTransform(r.image, outputProfile, inputProfile, intent, depth)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // ToColorSpace changes the color space of the image to the interpretation supplied as the parameter.
 func (r *Image) ToColorSpace(interpretation Interpretation) error {
 	out, err := vipsToColorSpace(r.image, interpretation)
@@ -534,6 +696,46 @@ func (r *Image) Label(
 	return nil
 }
 
+// RichLabel draws text label with wrapping, stroke, shadow and background box
+func (r *Image) RichLabel(opts *LabelOptions) error {
+	out, err := vipsRichLabel(r.image, opts)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Resize resizes the image by horizontal and vertical scale with resampling kernel
+func (r *Image) Resize(hscale, vscale float64, kernel Kernel) error {
+	out, err := vipsResize(r.image, hscale, vscale, kernel)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Pixelate averages the image in blocks of pixels
+func (r *Image) Pixelate(block int) error {
+	out, err := vipsPixelate(r.image, block)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Insert replaces the area of the image at x, y with sub image
+func (r *Image) Insert(sub *Image, x, y int) error {
+	out, err := vipsInsert(r.image, sub.image, x, y)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // GaussianBlur blurs the image
 func (r *Image) GaussianBlur(sigma float64) error {
 	out, err := vipsGaussianBlur(r.image, sigma)
@@ -610,6 +812,16 @@ func (r *Image) GetPoint(x int, y int) ([]float64, error) {
 	return vipsGetPoint(r.image, n, x, y)
 }
 
+// Luma returns the 8-bit luma pixels of the image, with width and height
+func (r *Image) Luma() ([]byte, int, int, error) {
+	return vipsImageToLuma(r.image)
+}
+
+// Pixels returns the 8-bit sRGB pixels of the image, with width, height and number of bands
+func (r *Image) Pixels() ([]byte, int, int, int, error) {
+	return vipsImageToSRGB(r.image)
+}
+
 // Thumbnail resizes the image to the given width and height.
 // crop decides algorithm vips uses to shrink and crop to fill target,
 func (r *Image) Thumbnail(width, height int, crop Interesting) error {
@@ -702,6 +914,36 @@ func (r *Image) Rotate(angle Angle) error {
 	return nil
 }
 
+// Similarity rotates the image by any angle in degrees clockwise, with background filling the corners
+func (r *Image) Similarity(angle float64, interpolator string, bg *ColorRGBA) error {
+	out, err := vipsSimilarity(r.image, angle, interpolator, bg)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Affine transforms the image by the 2x2 matrix a, b, c, d
+func (r *Image) Affine(a, b, c, d float64, interpolator string, bg *ColorRGBA) error {
+	out, err := vipsAffine(r.image, [4]float64{a, b, c, d}, interpolator, bg)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
+// Perspective maps the image to width x height by homography h of the output to the source coordinates
+func (r *Image) Perspective(h [9]float64, width, height int, interpolator string, bg *ColorRGBA) error {
+	out, err := vipsPerspective(r.image, h, width, height, interpolator, bg)
+	if err != nil {
+		return err
+	}
+	r.setImage(out)
+	return nil
+}
+
 // Replicate repeats an image many times across and down
 func (r *Image) Replicate(across int, down int) error {
 	out, err := vipsReplicate(r.image, across, down)
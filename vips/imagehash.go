@@ -0,0 +1,147 @@
+package vips
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// imageHashSampleSize dimension of the grayscale sample perceptual hashes computed from
+const imageHashSampleSize = 64
+
+// imageHashLoadSize max dimension of the image shrunk on load for perceptual hash output
+const imageHashLoadSize = 256
+
+// parseImageHash parses format args phash, dhash or ahash
+func parseImageHash(args string) (string, bool) {
+	switch algo := strings.TrimSpace(args); algo {
+	case "phash", "dhash", "ahash":
+		return algo, true
+	}
+	return "", false
+}
+
+// imageHash computes the 64-bit perceptual hash of the first frame as 16 hex digits
+func imageHash(img *Image, algo string) (string, error) {
+	sample, err := img.Copy()
+	if err != nil {
+		return "", err
+	}
+	defer sample.Close()
+	if err = firstFrame(sample); err != nil {
+		return "", err
+	}
+	if err = sample.ThumbnailWithSize(
+		imageHashSampleSize, imageHashSampleSize, InterestingNone, SizeForce,
+	); err != nil {
+		return "", err
+	}
+	luma, width, height, err := sample.Luma()
+	if err != nil {
+		return "", err
+	}
+	var hash uint64
+	switch algo {
+	case "dhash":
+		hash = DHash(luma, width, height)
+	case "ahash":
+		hash = AHash(luma, width, height)
+	default:
+		hash = PHash(luma, width, height)
+	}
+	return fmt.Sprintf("%016x", hash), nil
+}
+
+// AHash average hash of 8-bit single band image,
+// bits set for 8x8 pixels brighter than the mean
+func AHash(luma []byte, width, height int) (hash uint64) {
+	pix := resizeLuma(luma, width, height, 8, 8)
+	var mean float64
+	for _, v := range pix {
+		mean += v
+	}
+	mean /= float64(len(pix))
+	for _, v := range pix {
+		hash <<= 1
+		if v > mean {
+			hash |= 1
+		}
+	}
+	return
+}
+
+// DHash difference hash of 8-bit single band image,
+// bits set for 9x8 pixels brighter than their left neighbours
+func DHash(luma []byte, width, height int) (hash uint64) {
+	pix := resizeLuma(luma, width, height, 9, 8)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if pix[y*9+x+1] > pix[y*9+x] {
+				hash |= 1
+			}
+		}
+	}
+	return
+}
+
+// PHash DCT based perceptual hash of 8-bit single band image,
+// bits set for the 8x8 lowest frequencies of 32x32 DCT above the median
+func PHash(luma []byte, width, height int) (hash uint64) {
+	const size, low = 32, 8
+	pix := resizeLuma(luma, width, height, size, size)
+	// separable DCT-II of the low frequencies
+	rows := make([]float64, size*low)
+	for y := 0; y < size; y++ {
+		for u := 0; u < low; u++ {
+			var sum float64
+			for x := 0; x < size; x++ {
+				sum += pix[y*size+x] * math.Cos(math.Pi/size*(float64(x)+0.5)*float64(u))
+			}
+			rows[y*low+u] = sum
+		}
+	}
+	coeffs := make([]float64, low*low)
+	for v := 0; v < low; v++ {
+		for u := 0; u < low; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				sum += rows[y*low+u] * math.Cos(math.Pi/size*(float64(y)+0.5)*float64(v))
+			}
+			coeffs[v*low+u] = sum
+		}
+	}
+	sorted := append([]float64(nil), coeffs...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	for _, c := range coeffs {
+		hash <<= 1
+		if c > median {
+			hash |= 1
+		}
+	}
+	return
+}
+
+// resizeLuma resizes single band image by box averaging
+func resizeLuma(luma []byte, width, height, w, h int) []float64 {
+	out := make([]float64, w*h)
+	if width <= 0 || height <= 0 {
+		return out
+	}
+	for j := 0; j < h; j++ {
+		y0, y1 := j*height/h, max((j+1)*height/h, j*height/h+1)
+		for i := 0; i < w; i++ {
+			x0, x1 := i*width/w, max((i+1)*width/w, i*width/w+1)
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum += float64(luma[y*width+x])
+				}
+			}
+			out[j*w+i] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+package vips
+
+import (
+	"math/bits"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageHash(t *testing.T) {
+	const width, height = 64, 48
+	gradient := make([]byte, width*height)
+	pattern := make([]byte, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			gradient[y*width+x] = byte(x * 3)
+			pattern[y*width+x] = byte((x*x + y*7) % 200)
+		}
+	}
+	assert.Equal(t, uint64(0xffffffffffffffff), DHash(gradient, width, height))
+	assert.Equal(t, uint64(0x0f0f0f0f0f0f0f0f), AHash(gradient, width, height))
+
+	// robust to brightness change
+	brighter := make([]byte, len(pattern))
+	for i, v := range pattern {
+		brighter[i] = v + 40
+	}
+	for _, fn := range []func([]byte, int, int) uint64{AHash, DHash, PHash} {
+		assert.Equal(t, fn(pattern, width, height), fn(brighter, width, height))
+		assert.Greater(t, bits.OnesCount64(fn(pattern, width, height)^fn(gradient, width, height)), 8)
+	}
+}
+
+func TestParseImageHash(t *testing.T) {
+	algo, ok := parseImageHash("phash")
+	assert.True(t, ok)
+	assert.Equal(t, "phash", algo)
+	_, ok = parseImageHash("blurhash")
+	assert.False(t, ok)
+}
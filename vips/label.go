@@ -0,0 +1,248 @@
+package vips
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xudaolong/imagor"
+)
+
+const (
+	// defaultLabelFont default font of label
+	defaultLabelFont = "tahoma"
+	// labelMaxStroke max stroke width of label
+	labelMaxStroke = 50
+	// labelMaxShadow max shadow offset of label
+	labelMaxShadow = 100
+	// labelMaxSpacing max line spacing of label
+	labelMaxSpacing = 200
+	// labelMaxPadding max background box padding of label
+	labelMaxPadding = 200
+	// labelDefaultShadowAlpha default shadow transparency in %
+	labelDefaultShadowAlpha = 50
+	// fontMaxSize max size of font file loaded from storages
+	fontMaxSize = 20 << 20
+	// fontCacheMaxFiles max number of font files cached in temp directory
+	fontCacheMaxFiles = 100
+)
+
+// isLabelOption if label arg is a rich text option flag or key=value pair
+func isLabelOption(arg string) bool {
+	switch arg {
+	case "bold", "italic", "markup":
+		return true
+	}
+	return strings.Contains(arg, "=")
+}
+
+// parseLabelOptions parses rich text options of label filter
+// e.g. bold, italic, markup, width=80p, spacing=4, stroke=2, stroke_color=black,
+// shadow=3, shadow_blur=2, shadow_color=black, shadow_alpha=50,
+// background=yellow, padding=10, fontfile=Roboto-Regular.ttf
+func (v *Processor) parseLabelOptions(
+	img *Image, load imagor.LoadFunc, opts *LabelOptions, font string, args ...string,
+) (err error) {
+	var bold, italic, markup bool
+	var fontfile string
+	var shadowAlpha = float64(labelDefaultShadowAlpha)
+	for _, arg := range args {
+		key, value, _ := strings.Cut(arg, "=")
+		if s, e := url.QueryUnescape(value); e == nil {
+			value = s
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "bold":
+			bold = true
+		case "italic":
+			italic = true
+		case "markup":
+			markup = true
+		case "width":
+			if strings.HasSuffix(value, "p") {
+				n, _ := strconv.Atoi(strings.TrimSuffix(value, "p"))
+				opts.Width = n * img.Width() / 100
+			} else {
+				opts.Width, _ = strconv.Atoi(value)
+			}
+			opts.Width = max(0, min(opts.Width, v.MaxWidth))
+		case "spacing":
+			opts.Spacing, _ = strconv.Atoi(value)
+			opts.Spacing = max(0, min(opts.Spacing, labelMaxSpacing))
+		case "stroke":
+			opts.Stroke, _ = strconv.Atoi(value)
+			opts.Stroke = max(0, min(opts.Stroke, labelMaxStroke))
+		case "stroke_color":
+			opts.StrokeColor = getColor(img, value)
+		case "shadow":
+			n, _ := strconv.Atoi(value)
+			n = max(-labelMaxShadow, min(n, labelMaxShadow))
+			opts.ShadowX, opts.ShadowY = n, n
+		case "shadow_blur":
+			opts.ShadowBlur, _ = strconv.ParseFloat(value, 64)
+			opts.ShadowBlur = max(0, min(opts.ShadowBlur, labelMaxStroke))
+		case "shadow_color":
+			opts.ShadowColor = getColor(img, value)
+		case "shadow_alpha":
+			shadowAlpha, _ = strconv.ParseFloat(value, 64)
+		case "background":
+			opts.Background = getColor(img, value)
+		case "padding":
+			opts.Padding, _ = strconv.Atoi(value)
+			opts.Padding = max(0, min(opts.Padding, labelMaxPadding))
+		case "fontfile":
+			fontfile = value
+		}
+	}
+	if opts.Stroke > 0 && opts.StrokeColor == nil {
+		opts.StrokeColor = &Color{}
+	}
+	if opts.ShadowColor == nil {
+		opts.ShadowColor = &Color{}
+	}
+	opts.ShadowOpacity = 1 - max(0, min(shadowAlpha, 100))/100
+	if !markup {
+		opts.Text = html.EscapeString(opts.Text)
+	}
+	opts.Font = labelFont(font, bold, italic, opts.Size)
+	if fontfile != "" {
+		if opts.FontFile, err = v.loadFontFile(load, fontfile); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// labelFont pango font description, size in points equals pixels at 72 dpi
+func labelFont(font string, bold, italic bool, size int) string {
+	if bold {
+		font += " Bold"
+	}
+	if italic {
+		font += " Italic"
+	}
+	return font + " " + strconv.Itoa(size)
+}
+
+// loadFontFile returns the path of font file from fonts directory,
+// or loaded from storages and cached in temp directory
+func (v *Processor) loadFontFile(load imagor.LoadFunc, name string) (string, error) {
+	if v.FontsDir != "" {
+		if path, ok := findNamedFile(v.FontsDir, name, ".ttf", ".otf", ".ttc"); ok {
+			return path, nil
+		}
+	}
+	blob, err := load(name)
+	if err != nil {
+		return "", err
+	}
+	if blob.Size() > fontMaxSize {
+		return "", imagor.ErrMaxSizeExceeded
+	}
+	reader, _, err := blob.NewReader()
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	buf, err := io.ReadAll(io.LimitReader(reader, fontMaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(buf) > fontMaxSize {
+		return "", imagor.ErrMaxSizeExceeded
+	}
+	if !isFontFile(buf) {
+		return "", imagor.NewError("invalid font file", http.StatusBadRequest)
+	}
+	return cacheFontFile(buf)
+}
+
+// isFontFile if data is TrueType, OpenType or TrueType collection font
+func isFontFile(buf []byte) bool {
+	if len(buf) < 4 {
+		return false
+	}
+	switch string(buf[:4]) {
+	case "\x00\x01\x00\x00", "true", "OTTO", "ttcf":
+		return true
+	}
+	return false
+}
+
+// cacheFontFile writes font data to temp directory by content hash
+func cacheFontFile(buf []byte) (string, error) {
+	sum := sha256.Sum256(buf)
+	dir := filepath.Join(os.TempDir(), "imagor-fonts")
+	path := filepath.Join(dir, hex.EncodeToString(sum[:16])+".ttf")
+	if _, err := os.Stat(path); err == nil {
+		// recently used kept by pruning
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+		return path, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp(dir, "font-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err = file.Write(buf); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	if err = file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	// atomic for concurrent requests
+	if err = os.Rename(file.Name(), path); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	pruneFontCache(dir, fontCacheMaxFiles)
+	return path, nil
+}
+
+// pruneFontCache removes least recently used font files over max files
+func pruneFontCache(dir string, maxFiles int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		path    string
+		modTime time.Time
+	}
+	var files []cached
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".ttf" {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			files = append(files, cached{filepath.Join(dir, entry.Name()), info.ModTime()})
+		}
+	}
+	if len(files) <= maxFiles {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, file := range files[:len(files)-maxFiles] {
+		_ = os.Remove(file.path)
+	}
+}
@@ -0,0 +1,82 @@
+package vips
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xudaolong/imagor"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestIsLabelOption(t *testing.T) {
+	assert.True(t, isLabelOption("bold"))
+	assert.True(t, isLabelOption("stroke=2"))
+	assert.True(t, isLabelOption("fontfile=fonts%2FRoboto.ttf"))
+	assert.False(t, isLabelOption("50"))
+	assert.False(t, isLabelOption("monospace"))
+}
+
+func TestCacheFontFile(t *testing.T) {
+	buf := []byte("font data")
+	path, err := cacheFontFile(buf)
+	require.NoError(t, err)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, buf, b)
+
+	path2, err := cacheFontFile(buf)
+	require.NoError(t, err)
+	assert.Equal(t, path, path2)
+
+	path3, err := cacheFontFile([]byte("other font data"))
+	require.NoError(t, err)
+	assert.NotEqual(t, path, path3)
+}
+
+func TestLoadFontFile(t *testing.T) {
+	v := &Processor{}
+	load := func(image string) (*imagor.Blob, error) {
+		switch image {
+		case "fonts/Go-Regular.ttf":
+			return imagor.NewBlobFromBytes(goregular.TTF), nil
+		case "large.ttf":
+			return imagor.NewBlobFromBytes(append([]byte("true"), make([]byte, fontMaxSize)...)), nil
+		case "text.ttf":
+			return imagor.NewBlobFromBytes([]byte("not a font")), nil
+		}
+		return nil, imagor.ErrNotFound
+	}
+	path, err := v.loadFontFile(load, "fonts/Go-Regular.ttf")
+	require.NoError(t, err)
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(goregular.TTF, b))
+
+	_, err = v.loadFontFile(load, "large.ttf")
+	assert.Equal(t, imagor.ErrMaxSizeExceeded, err)
+	_, err = v.loadFontFile(load, "text.ttf")
+	assert.Equal(t, 400, imagor.WrapError(err).Code)
+	_, err = v.loadFontFile(load, "missing.ttf")
+	assert.Equal(t, imagor.ErrNotFound, err)
+}
+
+func TestPruneFontCache(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"a.ttf", "b.ttf", "c.ttf"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+		modTime := now.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	pruneFontCache(dir, 2)
+	_, err := os.Stat(filepath.Join(dir, "a.ttf"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "c.ttf"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,371 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// metadataSections extended metadata sections selected by metadata() filter
+type metadataSections struct {
+	XMP       bool
+	IPTC      bool
+	ICC       bool
+	Color     bool
+	Animation bool
+	Thumbnail bool
+}
+
+// parseMetadataSections parses metadata filter args e.g. xmp,iptc,icc,color,animation,thumbnail or all
+func parseMetadataSections(args string) *metadataSections {
+	s := &metadataSections{}
+	for _, arg := range strings.Split(args, ",") {
+		switch strings.TrimSpace(strings.ToLower(arg)) {
+		case "xmp":
+			s.XMP = true
+		case "iptc":
+			s.IPTC = true
+		case "icc":
+			s.ICC = true
+		case "color":
+			s.Color = true
+		case "animation":
+			s.Animation = true
+		case "thumbnail":
+			s.Thumbnail = true
+		case "all":
+			*s = metadataSections{true, true, true, true, true, true}
+		}
+	}
+	return s
+}
+
+// Apply extracts the selected metadata sections of the image into meta
+func (s *metadataSections) Apply(img *Image, meta *Metadata) {
+	if s.XMP {
+		meta.XMP = ParseXMP(img.XMP())
+	}
+	if s.IPTC {
+		meta.IPTC = ParseIPTC(img.IPTC())
+	}
+	if s.ICC {
+		meta.ICC = ParseICCProfile(img.ICCProfile())
+	}
+	if s.Color {
+		meta.Color = &ColorInfo{
+			Interpretation: img.Interpretation().String(),
+			BitDepth:       img.BitDepth(),
+			DPI:            img.DPI(),
+		}
+	}
+	if s.Animation {
+		if loop, delays := img.Loop(), img.PageDelay(); loop >= 0 || len(delays) > 0 {
+			meta.Animation = &AnimationInfo{Loop: max(loop, 0), Delays: delays}
+		}
+	}
+	if s.Thumbnail {
+		hasThumbnail := img.HasEmbeddedThumbnail()
+		meta.EmbeddedThumbnail = &hasThumbnail
+	}
+}
+
+// ColorInfo color interpretation, bit depth per sample and resolution
+type ColorInfo struct {
+	Interpretation string  `json:"interpretation"`
+	BitDepth       int     `json:"bit_depth"`
+	DPI            float64 `json:"dpi,omitempty"`
+}
+
+// AnimationInfo animation loop count, 0 for infinite, and per frame delays in milliseconds
+type AnimationInfo struct {
+	Loop   int   `json:"loop"`
+	Delays []int `json:"delays,omitempty"`
+}
+
+// IPTC IPTC-IIM application record fields
+type IPTC struct {
+	Title     string   `json:"title,omitempty"`
+	Headline  string   `json:"headline,omitempty"`
+	Caption   string   `json:"caption,omitempty"`
+	Keywords  []string `json:"keywords,omitempty"`
+	Byline    []string `json:"byline,omitempty"`
+	Credit    string   `json:"credit,omitempty"`
+	Source    string   `json:"source,omitempty"`
+	Copyright string   `json:"copyright,omitempty"`
+	City      string   `json:"city,omitempty"`
+	Country   string   `json:"country,omitempty"`
+}
+
+// ParseIPTC parses IPTC-IIM data, raw or wrapped in Photoshop image resources.
+// Returns nil if no application record fields found
+func ParseIPTC(data []byte) *IPTC {
+	if bytes.HasPrefix(data, []byte("Photoshop 3.0\x00")) || bytes.HasPrefix(data, []byte("8BIM")) {
+		data = photoshopResource(bytes.TrimPrefix(data, []byte("Photoshop 3.0\x00")), 0x0404)
+	}
+	var iptc IPTC
+	var found bool
+	for len(data) >= 5 && data[0] == 0x1c {
+		record, dataset := data[1], data[2]
+		size := int(binary.BigEndian.Uint16(data[3:5]))
+		data = data[5:]
+		if size&0x8000 != 0 {
+			// extended dataset, length of the length
+			n := size & 0x7fff
+			if n > 4 || len(data) < n {
+				break
+			}
+			size = 0
+			for _, b := range data[:n] {
+				size = size<<8 | int(b)
+			}
+			data = data[n:]
+		}
+		if size > len(data) {
+			break
+		}
+		value := strings.TrimSpace(strings.ToValidUTF8(string(data[:size]), ""))
+		data = data[size:]
+		if record != 2 || value == "" {
+			continue
+		}
+		found = true
+		switch dataset {
+		case 5:
+			iptc.Title = value
+		case 25:
+			iptc.Keywords = append(iptc.Keywords, value)
+		case 80:
+			iptc.Byline = append(iptc.Byline, value)
+		case 90:
+			iptc.City = value
+		case 101:
+			iptc.Country = value
+		case 105:
+			iptc.Headline = value
+		case 110:
+			iptc.Credit = value
+		case 115:
+			iptc.Source = value
+		case 116:
+			iptc.Copyright = value
+		case 120:
+			iptc.Caption = value
+		}
+	}
+	if !found {
+		return nil
+	}
+	return &iptc
+}
+
+// photoshopResource returns data of the Photoshop image resource of id
+func photoshopResource(data []byte, id uint16) []byte {
+	for len(data) >= 12 && bytes.HasPrefix(data, []byte("8BIM")) {
+		rid := binary.BigEndian.Uint16(data[4:6])
+		// pascal string name padded to even length
+		nameLen := int(data[6]) + 1
+		nameLen += nameLen & 1
+		if len(data) < 6+nameLen+4 {
+			return nil
+		}
+		data = data[6+nameLen:]
+		size := int(binary.BigEndian.Uint32(data[:4]))
+		data = data[4:]
+		if size > len(data) {
+			return nil
+		}
+		if rid == id {
+			return data[:size]
+		}
+		data = data[min(size+size&1, len(data)):]
+	}
+	return nil
+}
+
+// XMP Dublin Core, XMP Rights and Photoshop schema fields
+type XMP struct {
+	Title        string   `json:"title,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Creator      []string `json:"creator,omitempty"`
+	Keywords     []string `json:"keywords,omitempty"`
+	Rights       string   `json:"rights,omitempty"`
+	UsageTerms   string   `json:"usage_terms,omitempty"`
+	WebStatement string   `json:"web_statement,omitempty"`
+	Marked       *bool    `json:"marked,omitempty"`
+	Credit       string   `json:"credit,omitempty"`
+}
+
+const (
+	xmlnsDC        = "http://purl.org/dc/elements/1.1/"
+	xmlnsXMPRights = "http://ns.adobe.com/xap/1.0/rights/"
+	xmlnsPhotoshop = "http://ns.adobe.com/photoshop/1.0/"
+	xmlnsRDF       = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+// ParseXMP parses XMP packet. Returns nil if no known fields found
+func ParseXMP(data []byte) *XMP {
+	if len(data) == 0 {
+		return nil
+	}
+	var (
+		xmp     XMP
+		found   bool
+		prop    xml.Name
+		text    strings.Builder
+		decoder = xml.NewDecoder(bytes.NewReader(bytes.TrimRight(data, "\x00")))
+	)
+	set := func(name xml.Name, value string) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return
+		}
+		var isSet = true
+		switch name {
+		case xml.Name{Space: xmlnsDC, Local: "title"}:
+			xmp.Title = value
+		case xml.Name{Space: xmlnsDC, Local: "description"}:
+			xmp.Description = value
+		case xml.Name{Space: xmlnsDC, Local: "creator"}:
+			xmp.Creator = append(xmp.Creator, value)
+		case xml.Name{Space: xmlnsDC, Local: "subject"}:
+			xmp.Keywords = append(xmp.Keywords, value)
+		case xml.Name{Space: xmlnsDC, Local: "rights"}:
+			xmp.Rights = value
+		case xml.Name{Space: xmlnsXMPRights, Local: "UsageTerms"}:
+			xmp.UsageTerms = value
+		case xml.Name{Space: xmlnsXMPRights, Local: "WebStatement"}:
+			xmp.WebStatement = value
+		case xml.Name{Space: xmlnsXMPRights, Local: "Marked"}:
+			marked := strings.EqualFold(value, "true")
+			xmp.Marked = &marked
+		case xml.Name{Space: xmlnsPhotoshop, Local: "Credit"}:
+			xmp.Credit = value
+		default:
+			isSet = false
+		}
+		found = found || isSet
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space == xmlnsRDF {
+				if t.Name.Local == "Description" {
+					// simple properties as attributes
+					for _, attr := range t.Attr {
+						set(attr.Name, attr.Value)
+					}
+				}
+				text.Reset()
+				continue
+			}
+			prop = t.Name
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if t.Name.Space == xmlnsRDF && t.Name.Local == "li" || t.Name == prop {
+				// array item, language alternative or simple property value
+				if prop.Local != "" && text.Len() > 0 {
+					set(prop, text.String())
+				}
+				text.Reset()
+			}
+			if t.Name == prop {
+				prop = xml.Name{}
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return &xmp
+}
+
+// ICCProfile ICC profile header and description
+type ICCProfile struct {
+	Description string `json:"description,omitempty"`
+	ColorSpace  string `json:"color_space"`
+	DeviceClass string `json:"device_class"`
+	Version     string `json:"version"`
+}
+
+var iccDeviceClasses = map[string]string{
+	"scnr": "input",
+	"mntr": "display",
+	"prtr": "output",
+	"link": "link",
+	"spac": "color_space",
+	"abst": "abstract",
+	"nmcl": "named_color",
+}
+
+// ParseICCProfile parses ICC profile header and description tag. Returns nil if invalid
+func ParseICCProfile(data []byte) *ICCProfile {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil
+	}
+	icc := &ICCProfile{
+		ColorSpace:  strings.ToLower(strings.TrimSpace(string(data[16:20]))),
+		DeviceClass: iccDeviceClasses[string(data[12:16])],
+		Version:     formatICCVersion(data[8:12]),
+	}
+	count := int(binary.BigEndian.Uint32(data[128:132]))
+	for i := 0; i < count && 132+i*12+12 <= len(data); i++ {
+		entry := data[132+i*12:]
+		if string(entry[:4]) != "desc" {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint32(entry[4:8]))
+		size := int(binary.BigEndian.Uint32(entry[8:12]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			break
+		}
+		icc.Description = parseICCText(data[offset : offset+size])
+		break
+	}
+	return icc
+}
+
+func formatICCVersion(b []byte) string {
+	return strconv.Itoa(int(b[0])) + "." + strconv.Itoa(int(b[1]>>4)) + "." + strconv.Itoa(int(b[1]&0xf))
+}
+
+// parseICCText parses textDescriptionType or multiLocalizedUnicodeType
+func parseICCText(tag []byte) string {
+	if len(tag) < 12 {
+		return ""
+	}
+	switch string(tag[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if n > len(tag)-12 {
+			n = len(tag) - 12
+		}
+		return strings.TrimRight(string(tag[12:12+n]), "\x00 ")
+	case "mluc":
+		if len(tag) < 28 {
+			return ""
+		}
+		// first record
+		length := int(binary.BigEndian.Uint32(tag[20:24]))
+		offset := int(binary.BigEndian.Uint32(tag[24:28]))
+		if offset+length > len(tag) {
+			return ""
+		}
+		u := make([]uint16, length/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(tag[offset+i*2:])
+		}
+		return strings.TrimRight(string(utf16.Decode(u)), "\x00 ")
+	case "text":
+		return strings.TrimRight(string(tag[8:]), "\x00 ")
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package vips
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetadataSections(t *testing.T) {
+	assert.Equal(t, &metadataSections{}, parseMetadataSections(""))
+	assert.Equal(t, &metadataSections{XMP: true, ICC: true}, parseMetadataSections("xmp, icc,foo"))
+	assert.Equal(t, &metadataSections{true, true, true, true, true, true}, parseMetadataSections("all"))
+}
+
+func TestParseIPTC(t *testing.T) {
+	dataset := func(record, dataset byte, value string) []byte {
+		return append([]byte{0x1c, record, dataset, byte(len(value) >> 8), byte(len(value))}, value...)
+	}
+	var iim []byte
+	iim = append(iim, dataset(1, 90, "\x1b%G")...)
+	iim = append(iim, dataset(2, 120, "A caption")...)
+	iim = append(iim, dataset(2, 25, "foo")...)
+	iim = append(iim, dataset(2, 25, "bar")...)
+	iim = append(iim, dataset(2, 110, "Credit")...)
+	iim = append(iim, dataset(2, 116, "(c) Someone")...)
+	expected := &IPTC{
+		Caption:   "A caption",
+		Keywords:  []string{"foo", "bar"},
+		Credit:    "Credit",
+		Copyright: "(c) Someone",
+	}
+	assert.Equal(t, expected, ParseIPTC(iim))
+
+	// wrapped in Photoshop image resources
+	resource := func(id uint16, data []byte) []byte {
+		b := []byte("8BIM")
+		b = binary.BigEndian.AppendUint16(b, id)
+		b = append(b, 0, 0) // empty name padded
+		b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+		b = append(b, data...)
+		if len(data)%2 == 1 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	psd := []byte("Photoshop 3.0\x00")
+	psd = append(psd, resource(0x03ed, []byte{1, 2, 3})...)
+	psd = append(psd, resource(0x0404, iim)...)
+	assert.Equal(t, expected, ParseIPTC(psd))
+
+	assert.Nil(t, ParseIPTC(nil))
+	assert.Nil(t, ParseIPTC(dataset(1, 90, "\x1b%G")))
+}
+
+func TestParseXMP(t *testing.T) {
+	packet := `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmpRights="http://ns.adobe.com/xap/1.0/rights/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    photoshop:Credit="Agency"
+    xmpRights:Marked="True">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Title</rdf:li></rdf:Alt></dc:title>
+   <dc:creator><rdf:Seq><rdf:li>Jane</rdf:li><rdf:li>John</rdf:li></rdf:Seq></dc:creator>
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>foo</rdf:li>
+     <rdf:li>bar</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+   <dc:rights><rdf:Alt><rdf:li xml:lang="x-default">All rights reserved</rdf:li></rdf:Alt></dc:rights>
+   <xmpRights:WebStatement>https://example.com/license</xmpRights:WebStatement>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>` + "\x00"
+	marked := true
+	assert.Equal(t, &XMP{
+		Title:        "Title",
+		Creator:      []string{"Jane", "John"},
+		Keywords:     []string{"foo", "bar"},
+		Rights:       "All rights reserved",
+		WebStatement: "https://example.com/license",
+		Marked:       &marked,
+		Credit:       "Agency",
+	}, ParseXMP([]byte(packet)))
+	assert.Nil(t, ParseXMP(nil))
+	assert.Nil(t, ParseXMP([]byte("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\"></x:xmpmeta>")))
+}
+
+func TestParseICCProfile(t *testing.T) {
+	profile := func(version byte, class, space string, desc []byte) []byte {
+		b := make([]byte, 132)
+		b[8], b[9] = version, 0x10
+		copy(b[12:], class)
+		copy(b[16:], space)
+		copy(b[36:], "acsp")
+		binary.BigEndian.PutUint32(b[128:], 1)
+		b = append(b, "desc"...)
+		b = binary.BigEndian.AppendUint32(b, 144)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(desc)))
+		return append(b, desc...)
+	}
+	v2 := []byte("desc\x00\x00\x00\x00")
+	v2 = binary.BigEndian.AppendUint32(v2, 10)
+	v2 = append(v2, "sRGB v2.1\x00"...)
+	icc := ParseICCProfile(profile(2, "mntr", "RGB ", v2))
+	require.NotNil(t, icc)
+	assert.Equal(t, &ICCProfile{
+		Description: "sRGB v2.1", ColorSpace: "rgb", DeviceClass: "display", Version: "2.1.0",
+	}, icc)
+
+	v4 := []byte("mluc\x00\x00\x00\x00")
+	v4 = binary.BigEndian.AppendUint32(v4, 1)
+	v4 = binary.BigEndian.AppendUint32(v4, 12)
+	v4 = append(v4, "enUS"...)
+	v4 = binary.BigEndian.AppendUint32(v4, 8)
+	v4 = binary.BigEndian.AppendUint32(v4, 28)
+	v4 = append(v4, 0, 'G', 0, 'r', 0, 'a', 0, 'y')
+	assert.Equal(t, &ICCProfile{
+		Description: "Gray", ColorSpace: "gray", DeviceClass: "output", Version: "4.1.0",
+	}, ParseICCProfile(profile(4, "prtr", "GRAY", v4)))
+
+	assert.Nil(t, ParseICCProfile([]byte("foo")))
+}
@@ -0,0 +1,429 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/xudaolong/imagor/imagorpath"
+)
+
+// metadataPolicy export metadata allowlist, GPS stripping and injection
+type metadataPolicy struct {
+	// keeps allowlists of section:field, field kept only if matches all allowlists
+	keeps     []map[string]bool
+	StripGPS  bool
+	Copyright string
+	Artist    string
+	URL       string
+}
+
+// newMetadataPolicy metadata policy from processor config and
+// keep_metadata, strip_gps, copyright, artist, license_url filters
+func (v *Processor) newMetadataPolicy(filters imagorpath.Filters) *metadataPolicy {
+	m := &metadataPolicy{
+		StripGPS:  v.MetadataStripGPS,
+		Copyright: v.MetadataCopyright,
+		Artist:    v.MetadataArtist,
+		URL:       v.MetadataURL,
+	}
+	if len(v.MetadataKeep) > 0 {
+		m.keeps = append(m.keeps, parseMetadataKeep(v.MetadataKeep...))
+	}
+	for _, f := range filters {
+		if v.disableFilters[f.Name] {
+			continue
+		}
+		switch f.Name {
+		case "keep_metadata":
+			m.keeps = append(m.keeps, parseMetadataKeep(strings.Split(f.Args, ",")...))
+		case "strip_gps":
+			m.StripGPS = true
+		case "copyright":
+			m.Copyright = unescapeFilterArgs(f.Args)
+		case "artist":
+			m.Artist = unescapeFilterArgs(f.Args)
+		case "license_url":
+			m.URL = unescapeFilterArgs(f.Args)
+		}
+	}
+	return m
+}
+
+func parseMetadataKeep(fields ...string) map[string]bool {
+	keep := map[string]bool{}
+	for _, field := range fields {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+			keep[field] = true
+		}
+	}
+	return keep
+}
+
+func unescapeFilterArgs(args string) string {
+	if s, err := url.QueryUnescape(args); err == nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(args)
+}
+
+// IsActive if metadata policy modifies metadata
+func (m *metadataPolicy) IsActive() bool {
+	return len(m.keeps) > 0 || m.StripGPS || m.Copyright != "" || m.Artist != "" || m.URL != ""
+}
+
+// Keep if metadata field of section exif, xmp or iptc is allowlisted
+func (m *metadataPolicy) Keep(section, name string) bool {
+	for _, keep := range m.keeps {
+		if !keep[section+":*"] && !keep[section+":"+strings.ToLower(name)] {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply applies metadata policy to the image metadata before export
+func (m *metadataPolicy) Apply(img *Image) error {
+	remove, strs, blobs := m.plan(img.MetadataFields(), img.XMP(), img.IPTC())
+	return img.UpdateMetadata(remove, strs, blobs)
+}
+
+// plan returns metadata fields to be removed, string and blob fields to be set
+func (m *metadataPolicy) plan(
+	fields []string, xmpData, iptcData []byte,
+) (remove []string, strs map[string]string, blobs map[string][]byte) {
+	strs = map[string]string{}
+	blobs = map[string][]byte{}
+	for _, field := range fields {
+		// exif-ifd0-Copyright, GPS in ifd3
+		if !strings.HasPrefix(field, "exif-ifd") || len(field) < 11 {
+			continue
+		}
+		if (m.StripGPS && field[8] == '3') || !m.Keep("exif", field[10:]) {
+			remove = append(remove, field)
+		}
+	}
+	if m.Copyright != "" {
+		strs["exif-ifd0-Copyright"] = exifASCII(m.Copyright)
+	}
+	if m.Artist != "" {
+		strs["exif-ifd0-Artist"] = exifASCII(m.Artist)
+	}
+
+	// injected fields replace the existing ones
+	var inject = &XMP{}
+	var replaced = map[string]bool{}
+	if m.Copyright != "" {
+		marked := true
+		inject.Rights, inject.Marked = m.Copyright, &marked
+		replaced["rights"], replaced["marked"] = true, true
+	}
+	if m.Artist != "" {
+		inject.Creator = []string{m.Artist}
+		replaced["creator"] = true
+	}
+	if m.URL != "" {
+		inject.WebStatement = m.URL
+		replaced["web_statement"] = true
+	}
+	buf := editXMP(xmpData, func(name string) bool {
+		return replaced[name] || (m.StripGPS && strings.HasPrefix(name, "exif:gps")) || !m.Keep("xmp", name)
+	}, inject.properties())
+	if buf == nil && len(xmpData) > 0 {
+		remove = append(remove, "xmp-data")
+	} else if buf != nil && !bytes.Equal(buf, xmpData) {
+		blobs["xmp-data"] = buf
+	}
+
+	// IPTC-IIM has no GPS fields
+	if len(m.keeps) == 0 && m.Copyright == "" && m.Artist == "" {
+		return
+	}
+	iptc := ParseIPTC(iptcData)
+	if iptc == nil {
+		iptc = &IPTC{}
+	}
+	iptc.filter(func(name string) bool { return m.Keep("iptc", name) })
+	if m.Copyright != "" {
+		iptc.Copyright = m.Copyright
+	}
+	if m.Artist != "" {
+		iptc.Byline = []string{m.Artist}
+	}
+	if buf := iptc.Marshal(); buf != nil {
+		blobs["iptc-data"] = buf
+	} else if len(iptcData) > 0 {
+		remove = append(remove, "iptc-data")
+	}
+	return
+}
+
+// xmpFieldNames field names of XMP properties supported by keep_metadata
+var xmpFieldNames = map[string]string{
+	"dc:title":               "title",
+	"dc:description":         "description",
+	"dc:creator":             "creator",
+	"dc:subject":             "keywords",
+	"dc:rights":              "rights",
+	"xmpRights:UsageTerms":   "usage_terms",
+	"xmpRights:WebStatement": "web_statement",
+	"xmpRights:Marked":       "marked",
+	"photoshop:Credit":       "credit",
+}
+
+// xmpFieldName field name of XMP property, or lower case prefixed name if not supported e.g. exif:gpslatitude
+func xmpFieldName(name xml.Name) string {
+	prefixed := name.Space + ":" + name.Local
+	if field, ok := xmpFieldNames[prefixed]; ok {
+		return field
+	}
+	return strings.ToLower(prefixed)
+}
+
+// editXMP removes properties of XMP packet in place, as elements or attributes,
+// other properties passed through. Injected properties are added as a new rdf:Description.
+// Returns nil if no properties left
+func editXMP(data []byte, remove func(name string) bool, inject string) []byte {
+	type edit struct {
+		start, end int64
+		value      []byte
+	}
+	var (
+		edits     []edit
+		kept      bool
+		depth     int
+		descDepth = -1
+		propStart int64
+		rdfEnd    = int64(-1)
+		decoder   = xml.NewDecoder(bytes.NewReader(data))
+	)
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if descDepth < 0 && t.Name.Space == "rdf" && t.Name.Local == "Description" {
+				descDepth = depth
+				// simple properties as attributes
+				end := decoder.InputOffset()
+				tag := data[start:end]
+				for _, attr := range t.Attr {
+					if attr.Name.Space == "" || attr.Name.Space == "xmlns" || attr.Name.Space == "rdf" || attr.Name.Space == "xml" {
+						continue
+					}
+					if !remove(xmpFieldName(attr.Name)) {
+						kept = true
+						continue
+					}
+					attrRegex := regexp.MustCompile(`\s+` + regexp.QuoteMeta(attr.Name.Space+":"+attr.Name.Local) + `\s*=\s*(?:"[^"]*"|'[^']*')`)
+					tag = attrRegex.ReplaceAll(tag, nil)
+				}
+				if len(tag) != int(end-start) {
+					edits = append(edits, edit{start, end, tag})
+				}
+			} else if depth == descDepth+1 {
+				propStart = start
+			}
+		case xml.EndElement:
+			if depth == descDepth+1 {
+				if remove(xmpFieldName(t.Name)) {
+					// including the preceding indentation
+					for propStart > 0 && isXMLSpace(data[propStart-1]) {
+						propStart--
+					}
+					edits = append(edits, edit{propStart, decoder.InputOffset(), nil})
+				} else {
+					kept = true
+				}
+			} else if depth == descDepth {
+				descDepth = -1
+			} else if t.Name.Space == "rdf" && t.Name.Local == "RDF" {
+				rdfEnd = start
+			}
+			depth--
+		}
+	}
+	if inject != "" {
+		if rdfEnd < 0 {
+			// not a valid XMP packet, replaced by injected properties
+			return xmpPacket(inject)
+		}
+		edits = append(edits, edit{rdfEnd, rdfEnd, []byte(xmpDescription(inject))})
+	} else if !kept {
+		return nil
+	}
+	var (
+		buf  = make([]byte, 0, len(data)+len(inject))
+		last int64
+	)
+	for _, e := range edits {
+		buf = append(buf, data[last:e.start]...)
+		buf = append(buf, e.value...)
+		last = e.end
+	}
+	return append(buf, data[last:]...)
+}
+
+func isXMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// exifASCII formats Exif ASCII value the way libvips does
+func exifASCII(value string) string {
+	return fmt.Sprintf("%s (%s, ASCII, %d components, %d bytes)", value, value, len(value)+1, len(value)+1)
+}
+
+// Marshal encodes XMP packet. Returns nil if no fields
+func (x *XMP) Marshal() []byte {
+	if props := x.properties(); props != "" {
+		return xmpPacket(props)
+	}
+	return nil
+}
+
+// properties encodes fields as XMP property elements
+func (x *XMP) properties() string {
+	var b bytes.Buffer
+	alt := func(name, value string) {
+		if value != "" {
+			b.WriteString("   <" + name + "><rdf:Alt><rdf:li xml:lang=\"x-default\">")
+			_ = xml.EscapeText(&b, []byte(value))
+			b.WriteString("</rdf:li></rdf:Alt></" + name + ">\n")
+		}
+	}
+	list := func(name, kind string, values []string) {
+		if len(values) > 0 {
+			b.WriteString("   <" + name + "><rdf:" + kind + ">")
+			for _, value := range values {
+				b.WriteString("<rdf:li>")
+				_ = xml.EscapeText(&b, []byte(value))
+				b.WriteString("</rdf:li>")
+			}
+			b.WriteString("</rdf:" + kind + "></" + name + ">\n")
+		}
+	}
+	simple := func(name, value string) {
+		if value != "" {
+			b.WriteString("   <" + name + ">")
+			_ = xml.EscapeText(&b, []byte(value))
+			b.WriteString("</" + name + ">\n")
+		}
+	}
+	alt("dc:title", x.Title)
+	alt("dc:description", x.Description)
+	list("dc:creator", "Seq", x.Creator)
+	list("dc:subject", "Bag", x.Keywords)
+	alt("dc:rights", x.Rights)
+	alt("xmpRights:UsageTerms", x.UsageTerms)
+	simple("xmpRights:WebStatement", x.WebStatement)
+	if x.Marked != nil && *x.Marked {
+		simple("xmpRights:Marked", "True")
+	} else if x.Marked != nil {
+		simple("xmpRights:Marked", "False")
+	}
+	simple("photoshop:Credit", x.Credit)
+	return b.String()
+}
+
+// xmpPacket wraps XMP property elements in packet
+func xmpPacket(props string) []byte {
+	return []byte(`<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="` + xmlnsRDF + `">
+` + xmpDescription(props) + ` </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`)
+}
+
+// xmpDescription wraps XMP property elements in rdf:Description
+func xmpDescription(props string) string {
+	return `  <rdf:Description rdf:about=""
+    xmlns:dc="` + xmlnsDC + `"
+    xmlns:xmpRights="` + xmlnsXMPRights + `"
+    xmlns:photoshop="` + xmlnsPhotoshop + `">
+` + props + `  </rdf:Description>
+`
+}
+
+func (i *IPTC) filter(keep func(name string) bool) {
+	if !keep("title") {
+		i.Title = ""
+	}
+	if !keep("headline") {
+		i.Headline = ""
+	}
+	if !keep("caption") {
+		i.Caption = ""
+	}
+	if !keep("keywords") {
+		i.Keywords = nil
+	}
+	if !keep("byline") {
+		i.Byline = nil
+	}
+	if !keep("credit") {
+		i.Credit = ""
+	}
+	if !keep("source") {
+		i.Source = ""
+	}
+	if !keep("copyright") {
+		i.Copyright = ""
+	}
+	if !keep("city") {
+		i.City = ""
+	}
+	if !keep("country") {
+		i.Country = ""
+	}
+}
+
+// Marshal encodes IPTC-IIM wrapped in Photoshop image resource. Returns nil if no fields
+func (i *IPTC) Marshal() []byte {
+	var iim []byte
+	dataset := func(record, dataset byte, value string) {
+		if value == "" {
+			return
+		}
+		if len(value) > 0x7fff {
+			value = value[:0x7fff]
+		}
+		iim = append(iim, 0x1c, record, dataset)
+		iim = binary.BigEndian.AppendUint16(iim, uint16(len(value)))
+		iim = append(iim, value...)
+	}
+	dataset(2, 5, i.Title)
+	for _, keyword := range i.Keywords {
+		dataset(2, 25, keyword)
+	}
+	for _, byline := range i.Byline {
+		dataset(2, 80, byline)
+	}
+	dataset(2, 90, i.City)
+	dataset(2, 101, i.Country)
+	dataset(2, 105, i.Headline)
+	dataset(2, 110, i.Credit)
+	dataset(2, 115, i.Source)
+	dataset(2, 116, i.Copyright)
+	dataset(2, 120, i.Caption)
+	if len(iim) == 0 {
+		return nil
+	}
+	// UTF-8 coded character set
+	iim = append([]byte{0x1c, 1, 90, 0, 3, 0x1b, '%', 'G'}, iim...)
+	buf := []byte("Photoshop 3.0\x008BIM\x04\x04\x00\x00")
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(iim)))
+	buf = append(buf, iim...)
+	if len(iim)%2 == 1 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
@@ -0,0 +1,134 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xudaolong/imagor/imagorpath"
+)
+
+func TestMetadataPolicy(t *testing.T) {
+	fields := []string{
+		"exif-data",
+		"exif-ifd0-Copyright",
+		"exif-ifd0-Artist",
+		"exif-ifd0-Model",
+		"exif-ifd2-BodySerialNumber",
+		"exif-ifd3-GPSLatitude",
+		"icc-profile-data",
+	}
+	xmp := (&XMP{Title: "Title", Rights: "(c) Foo", Keywords: []string{"a", "b"}}).Marshal()
+	iptc := (&IPTC{Caption: "Caption", Copyright: "(c) Foo", City: "Paris"}).Marshal()
+
+	t.Run("inactive", func(t *testing.T) {
+		v := NewProcessor()
+		assert.False(t, v.newMetadataPolicy(imagorpath.Parse("filters:quality(80)/a.jpg").Filters).IsActive())
+	})
+	t.Run("strip gps", func(t *testing.T) {
+		v := NewProcessor(WithMetadataStripGPS(true))
+		m := v.newMetadataPolicy(nil)
+		require.True(t, m.IsActive())
+		remove, strs, blobs := m.plan(fields, xmp, iptc)
+		assert.Equal(t, []string{"exif-ifd3-GPSLatitude"}, remove)
+		assert.Empty(t, strs)
+		// passed through without GPS properties
+		assert.Empty(t, blobs)
+
+		gps := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="` + xmlnsRDF + `">
+  <rdf:Description rdf:about="" xmlns:exif="http://ns.adobe.com/exif/1.0/" xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    exif:GPSAltitude="100/1" xmp:CreatorTool="Camera">
+   <exif:GPSLatitude>48,51.5N</exif:GPSLatitude>
+   <exif:GPSVersionID><rdf:Seq><rdf:li>2</rdf:li></rdf:Seq></exif:GPSVersionID>
+   <exif:GPSMapDatum/>
+   <exif:ExposureTime>1/100</exif:ExposureTime>
+  </rdf:Description></rdf:RDF></x:xmpmeta>`)
+		_, _, blobs = m.plan(nil, gps, nil)
+		out := string(blobs["xmp-data"])
+		assert.NotContains(t, out, "GPS")
+		assert.Contains(t, out, `xmp:CreatorTool="Camera"`)
+		assert.Contains(t, out, "<exif:ExposureTime>1/100</exif:ExposureTime>")
+		assert.Contains(t, out, "</rdf:Description>")
+	})
+	t.Run("keep allowlist", func(t *testing.T) {
+		v := NewProcessor(WithMetadataKeep("exif:Copyright,exif:Artist, xmp:rights", "iptc:*"))
+		m := v.newMetadataPolicy(imagorpath.Parse("filters:keep_metadata(exif:copyright,xmp:rights,iptc:copyright)/a.jpg").Filters)
+		remove, strs, blobs := m.plan(fields, xmp, iptc)
+		assert.Equal(t, []string{
+			"exif-ifd0-Artist", "exif-ifd0-Model", "exif-ifd2-BodySerialNumber", "exif-ifd3-GPSLatitude",
+		}, remove)
+		assert.Empty(t, strs)
+		assert.Equal(t, &XMP{Rights: "(c) Foo"}, ParseXMP(blobs["xmp-data"]))
+		assert.Equal(t, &IPTC{Copyright: "(c) Foo"}, ParseIPTC(blobs["iptc-data"]))
+	})
+	t.Run("keep nothing", func(t *testing.T) {
+		m := NewProcessor().newMetadataPolicy(imagorpath.Parse("filters:keep_metadata()/a.jpg").Filters)
+		remove, _, blobs := m.plan(fields, xmp, iptc)
+		assert.Contains(t, remove, "xmp-data")
+		assert.Contains(t, remove, "iptc-data")
+		assert.Contains(t, remove, "exif-ifd0-Copyright")
+		assert.NotContains(t, remove, "exif-data")
+		assert.NotContains(t, remove, "icc-profile-data")
+		assert.Empty(t, blobs)
+	})
+	t.Run("inject", func(t *testing.T) {
+		v := NewProcessor(
+			WithMetadataCopyright("(c) Acme"),
+			WithMetadataURL("https://example.com/license"),
+		)
+		m := v.newMetadataPolicy(imagorpath.Parse("filters:artist(Jane%20Doe)/a.jpg").Filters)
+		remove, strs, blobs := m.plan(nil, nil, nil)
+		assert.Empty(t, remove)
+		assert.Equal(t, map[string]string{
+			"exif-ifd0-Copyright": "(c) Acme ((c) Acme, ASCII, 9 components, 9 bytes)",
+			"exif-ifd0-Artist":    "Jane Doe (Jane Doe, ASCII, 9 components, 9 bytes)",
+		}, strs)
+		marked := true
+		assert.Equal(t, &XMP{
+			Creator:      []string{"Jane Doe"},
+			Rights:       "(c) Acme",
+			WebStatement: "https://example.com/license",
+			Marked:       &marked,
+		}, ParseXMP(blobs["xmp-data"]))
+		assert.Equal(t, &IPTC{Byline: []string{"Jane Doe"}, Copyright: "(c) Acme"}, ParseIPTC(blobs["iptc-data"]))
+	})
+	t.Run("unknown properties passed through", func(t *testing.T) {
+		packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="` + xmlnsRDF + `">
+  <rdf:Description rdf:about="" xmlns:dc="` + xmlnsDC + `" xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:exif="http://ns.adobe.com/exif/1.0/" xmp:CreatorTool="Camera" exif:GPSAltitude="100/1">
+   <dc:rights><rdf:Alt><rdf:li xml:lang="x-default">(c) Foo</rdf:li></rdf:Alt></dc:rights>
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Title</rdf:li></rdf:Alt></dc:title>
+   <exif:ExposureTime>1/100</exif:ExposureTime>
+   <exif:GPSLatitude>48,51.5N</exif:GPSLatitude>
+  </rdf:Description></rdf:RDF></x:xmpmeta>`)
+
+		m := NewProcessor().newMetadataPolicy(imagorpath.Parse("filters:copyright(Acme)/a.jpg").Filters)
+		_, _, blobs := m.plan(nil, packet, nil)
+		out := string(blobs["xmp-data"])
+		assert.Contains(t, out, `xmp:CreatorTool="Camera"`)
+		assert.Contains(t, out, "<exif:ExposureTime>1/100</exif:ExposureTime>")
+		assert.Contains(t, out, "<exif:GPSLatitude>48,51.5N</exif:GPSLatitude>")
+		assert.NotContains(t, out, "(c) Foo")
+		marked := true
+		assert.Equal(t, &XMP{Title: "Title", Rights: "Acme", Marked: &marked}, ParseXMP(blobs["xmp-data"]))
+
+		m = NewProcessor().newMetadataPolicy(imagorpath.Parse("filters:keep_metadata(xmp:*):strip_gps()/a.jpg").Filters)
+		_, _, blobs = m.plan(nil, packet, nil)
+		out = string(blobs["xmp-data"])
+		assert.NotContains(t, out, "GPS")
+		assert.Contains(t, out, `xmp:CreatorTool="Camera"`)
+		assert.Contains(t, out, "<exif:ExposureTime>1/100</exif:ExposureTime>")
+		assert.Equal(t, &XMP{Title: "Title", Rights: "(c) Foo"}, ParseXMP(blobs["xmp-data"]))
+
+		m = NewProcessor().newMetadataPolicy(imagorpath.Parse("filters:keep_metadata(xmp:title,xmp:xmp:CreatorTool)/a.jpg").Filters)
+		_, _, blobs = m.plan(nil, packet, nil)
+		out = string(blobs["xmp-data"])
+		assert.Contains(t, out, `xmp:CreatorTool="Camera"`)
+		assert.NotContains(t, out, "exif:")
+		assert.Equal(t, &XMP{Title: "Title"}, ParseXMP(blobs["xmp-data"]))
+	})
+	t.Run("disabled filters", func(t *testing.T) {
+		v := NewProcessor(WithDisableFilters("copyright"))
+		assert.False(t, v.newMetadataPolicy(imagorpath.Parse("filters:copyright(foo)/a.jpg").Filters).IsActive())
+	})
+}
@@ -3,6 +3,7 @@ package vips
 import (
 	"go.uber.org/zap"
 	"strings"
+	"time"
 )
 
 // Option Processor option
@@ -140,3 +141,96 @@ func WithMaxResolution(res int) Option {
 		}
 	}
 }
+
+// WithAutoFormatBudget with time budget option for encoding format(auto) candidates
+func WithAutoFormatBudget(budget time.Duration) Option {
+	return func(v *Processor) {
+		if budget > 0 {
+			v.AutoFormatBudget = budget
+		}
+	}
+}
+
+// WithMetadataKeep with export metadata allowlist option of exif, xmp and iptc fields
+// e.g. exif:Copyright, xmp:rights, iptc:*
+func WithMetadataKeep(fields ...string) Option {
+	return func(v *Processor) {
+		for _, raw := range fields {
+			for _, field := range strings.Split(raw, ",") {
+				if field = strings.TrimSpace(field); field != "" {
+					v.MetadataKeep = append(v.MetadataKeep, field)
+				}
+			}
+		}
+	}
+}
+
+// WithMetadataStripGPS with strip GPS metadata on export option
+func WithMetadataStripGPS(enabled bool) Option {
+	return func(v *Processor) {
+		v.MetadataStripGPS = enabled
+	}
+}
+
+// WithMetadataCopyright with copyright metadata injected on export option
+func WithMetadataCopyright(copyright string) Option {
+	return func(v *Processor) {
+		v.MetadataCopyright = copyright
+	}
+}
+
+// WithMetadataArtist with artist metadata injected on export option
+func WithMetadataArtist(artist string) Option {
+	return func(v *Processor) {
+		v.MetadataArtist = artist
+	}
+}
+
+// WithMetadataURL with license URL metadata injected on export option
+func WithMetadataURL(url string) Option {
+	return func(v *Processor) {
+		v.MetadataURL = url
+	}
+}
+
+// WithICCProfilesDir with directory of named ICC profiles option for icc(name) filter
+func WithICCProfilesDir(dir string) Option {
+	return func(v *Processor) {
+		v.ICCProfilesDir = dir
+	}
+}
+
+// WithFontsDir with directory of font files option for label fontfile
+func WithFontsDir(dir string) Option {
+	return func(v *Processor) {
+		v.FontsDir = dir
+	}
+}
+
+// WithKernel with default resampling kernel option of resize,
+// nearest, linear, cubic, mitchell, lanczos2 or lanczos3
+func WithKernel(kernel string) Option {
+	return func(v *Processor) {
+		if kernel = strings.TrimSpace(kernel); kernel != "" {
+			v.Kernel = kernel
+		}
+	}
+}
+
+// WithAutoSharpen with sigma of unsharp mask applied after large downscale option, 0 to disable
+func WithAutoSharpen(sigma float64) Option {
+	return func(v *Processor) {
+		if sigma >= 0 {
+			v.AutoSharpen = sigma
+		}
+	}
+}
+
+// WithAutoSharpenRatio with minimum downscale ratio of auto sharpen option
+func WithAutoSharpenRatio(ratio float64) Option {
+	return func(v *Processor) {
+		if ratio > 1 {
+			v.AutoSharpenRatio = ratio
+		}
+	}
+}
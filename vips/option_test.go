@@ -6,6 +6,7 @@ import (
 	"github.com/xudaolong/imagor"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestWithOption(t *testing.T) {
@@ -20,6 +21,17 @@ func TestWithOption(t *testing.T) {
 			WithMaxHeight(998),
 			WithMaxResolution(1666667),
 			WithMozJPEG(true),
+			WithAutoFormatBudget(time.Second*3),
+			WithMetadataKeep("exif:Copyright, xmp:rights", "iptc:*"),
+			WithMetadataStripGPS(true),
+			WithMetadataCopyright("(c) Acme"),
+			WithMetadataArtist("Jane"),
+			WithMetadataURL("https://example.com/license"),
+			WithICCProfilesDir("/etc/imagor/icc"),
+			WithFontsDir("/etc/imagor/fonts"),
+			WithKernel("nearest"),
+			WithAutoSharpen(0.5),
+			WithAutoSharpenRatio(3),
 			WithDebug(true),
 			WithMaxAnimationFrames(3),
 			WithDisableFilters("rgb", "fill, watermark"),
@@ -37,14 +49,31 @@ func TestWithOption(t *testing.T) {
 		assert.Equal(t, 1666667, v.MaxResolution)
 		assert.Equal(t, 3, v.MaxAnimationFrames)
 		assert.Equal(t, true, v.MozJPEG)
+		assert.Equal(t, time.Second*3, v.AutoFormatBudget)
+		assert.Equal(t, []string{"exif:Copyright", "xmp:rights", "iptc:*"}, v.MetadataKeep)
+		assert.True(t, v.MetadataStripGPS)
+		assert.Equal(t, "(c) Acme", v.MetadataCopyright)
+		assert.Equal(t, "Jane", v.MetadataArtist)
+		assert.Equal(t, "https://example.com/license", v.MetadataURL)
+		assert.Equal(t, "/etc/imagor/icc", v.ICCProfilesDir)
+		assert.Equal(t, "/etc/imagor/fonts", v.FontsDir)
+		assert.Equal(t, "nearest", v.Kernel)
+		assert.Equal(t, 0.5, v.AutoSharpen)
+		assert.Equal(t, 3.0, v.AutoSharpenRatio)
 		assert.Equal(t, []string{"rgb", "fill", "watermark"}, v.DisableFilters)
 
 	})
 	t.Run("edge options", func(t *testing.T) {
 		v := NewProcessor(
 			WithConcurrency(-1),
+			WithKernel(" "),
+			WithAutoSharpen(-1),
+			WithAutoSharpenRatio(0.5),
 		)
 		assert.Equal(t, runtime.NumCPU(), v.Concurrency)
+		assert.Equal(t, "", v.Kernel)
+		assert.Equal(t, 0.0, v.AutoSharpen)
+		assert.Equal(t, 2.0, v.AutoSharpenRatio)
 	})
 }
 
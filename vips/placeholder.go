@@ -0,0 +1,281 @@
+package vips
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// placeholderSampleSize max dimension of the thumbnail placeholder hashes computed from
+const placeholderSampleSize = 100
+
+const blurhashCharacters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// placeholder format(blurhash[,x,y]) or format(thumbhash) args
+type placeholder struct {
+	Thumbhash   bool
+	ComponentsX int
+	ComponentsY int
+}
+
+// parsePlaceholder parses format args blurhash, blurhash,x,y or thumbhash
+func parsePlaceholder(args string) (*placeholder, bool) {
+	parts := strings.Split(args, ",")
+	switch strings.TrimSpace(parts[0]) {
+	case "thumbhash":
+		return &placeholder{Thumbhash: true}, true
+	case "blurhash":
+		p := &placeholder{ComponentsX: 4, ComponentsY: 3}
+		if len(parts) > 1 {
+			if n, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+				p.ComponentsX = max(1, min(9, n))
+			}
+		}
+		if len(parts) > 2 {
+			if n, err := strconv.Atoi(strings.TrimSpace(parts[2])); err == nil {
+				p.ComponentsY = max(1, min(9, n))
+			}
+		}
+		return p, true
+	}
+	return nil, false
+}
+
+// Hash computes placeholder hash from a thumbnail sample of the image
+func (p *placeholder) Hash(img *Image) (string, error) {
+	pix, width, height, bands, err := samplePixels(img, placeholderSampleSize)
+	if err != nil {
+		return "", err
+	}
+	if p.Thumbhash {
+		return base64.StdEncoding.EncodeToString(ThumbHash(pix, width, height, bands)), nil
+	}
+	return BlurHash(pix, width, height, bands, p.ComponentsX, p.ComponentsY), nil
+}
+
+// samplePixels returns 8-bit sRGB pixels with 3 or 4 bands
+// of the first frame, downsized to fit within size
+func samplePixels(img *Image, size int) ([]byte, int, int, int, error) {
+	sample, err := img.Copy()
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	defer sample.Close()
+	if err = firstFrame(sample); err != nil {
+		return nil, 0, 0, 0, err
+	}
+	if err = sample.ThumbnailWithSize(size, size, InterestingNone, SizeDown); err != nil {
+		return nil, 0, 0, 0, err
+	}
+	pix, width, height, bands, err := sample.Pixels()
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	if bands != 3 && bands != 4 {
+		return nil, 0, 0, 0, errors.New("vips: unsupported bands " + strconv.Itoa(bands))
+	}
+	return pix, width, height, bands, nil
+}
+
+// BlurHash encodes 8-bit sRGB pixels with 3 or 4 bands into blurhash
+// with 1 to 9 components on each axis. https://blurha.sh
+func BlurHash(pix []byte, width, height, bands, componentsX, componentsY int) string {
+	factors := make([][3]float64, 0, componentsX*componentsY)
+	for j := 0; j < componentsY; j++ {
+		for i := 0; i < componentsX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var r, g, b float64
+			for y := 0; y < height; y++ {
+				by := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) * by
+					k := (y*width + x) * bands
+					r += basis * sRGBToLinear(pix[k])
+					g += basis * sRGBToLinear(pix[k+1])
+					b += basis * sRGBToLinear(pix[k+2])
+				}
+			}
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+	var hash strings.Builder
+	encode83(&hash, (componentsX-1)+(componentsY-1)*9, 1)
+	maximumValue := 1.0
+	if len(factors) > 1 {
+		var actualMax float64
+		for _, f := range factors[1:] {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maximumValue = float64(quantisedMax+1) / 166
+		encode83(&hash, quantisedMax, 1)
+	} else {
+		encode83(&hash, 0, 1)
+	}
+	dc := factors[0]
+	encode83(&hash, linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+	for _, f := range factors[1:] {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+		}
+		encode83(&hash, quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2)
+	}
+	return hash.String()
+}
+
+func encode83(w *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := value / int(math.Pow(83, float64(length-i))) % 83
+		w.WriteByte(blurhashCharacters[digit])
+	}
+}
+
+func sRGBToLinear(c byte) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+// ThumbHash encodes 8-bit sRGB pixels with 3 or 4 bands into thumbhash,
+// width and height up to 100. https://evanw.github.io/thumbhash
+func ThumbHash(pix []byte, width, height, bands int) []byte {
+	var (
+		n                          = width * height
+		avgR, avgG, avgB, avgA     float64
+		alphaAt                    = func(i int) float64 { return 1 }
+		round                      = func(v float64) int { return int(math.Floor(v + 0.5)) }
+		l, p, q, a                 = make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)
+		lx, ly, lLimit, acStart, i int
+	)
+	if bands == 4 {
+		alphaAt = func(i int) float64 { return float64(pix[i*4+3]) / 255 }
+	}
+	// average color
+	for i = 0; i < n; i++ {
+		alpha := alphaAt(i)
+		avgR += alpha / 255 * float64(pix[i*bands])
+		avgG += alpha / 255 * float64(pix[i*bands+1])
+		avgB += alpha / 255 * float64(pix[i*bands+2])
+		avgA += alpha
+	}
+	if avgA > 0 {
+		avgR /= avgA
+		avgG /= avgA
+		avgB /= avgA
+	}
+	hasAlpha := avgA < float64(n)
+	lLimit = 7
+	if hasAlpha {
+		// fewer luminance bits if there's alpha
+		lLimit = 5
+	}
+	maxWH := float64(max(width, height))
+	lx = max(1, round(float64(lLimit*width)/maxWH))
+	ly = max(1, round(float64(lLimit*height)/maxWH))
+	// RGBA to LPQA, composite atop the average color
+	for i = 0; i < n; i++ {
+		alpha := alphaAt(i)
+		r := avgR*(1-alpha) + alpha/255*float64(pix[i*bands])
+		g := avgG*(1-alpha) + alpha/255*float64(pix[i*bands+1])
+		b := avgB*(1-alpha) + alpha/255*float64(pix[i*bands+2])
+		l[i] = (r + g + b) / 3
+		p[i] = (r+g)/2 - b
+		q[i] = r - g
+		a[i] = alpha
+	}
+	// DCT into DC and normalized AC terms
+	encodeChannel := func(channel []float64, nx, ny int) (dc float64, ac []float64, scale float64) {
+		fx := make([]float64, width)
+		for cy := 0; cy < ny; cy++ {
+			for cx := 0; cx*ny < nx*(ny-cy); cx++ {
+				var f float64
+				for x := 0; x < width; x++ {
+					fx[x] = math.Cos(math.Pi / float64(width) * float64(cx) * (float64(x) + 0.5))
+				}
+				for y := 0; y < height; y++ {
+					fy := math.Cos(math.Pi / float64(height) * float64(cy) * (float64(y) + 0.5))
+					for x := 0; x < width; x++ {
+						f += channel[x+y*width] * fx[x] * fy
+					}
+				}
+				f /= float64(n)
+				if cx > 0 || cy > 0 {
+					ac = append(ac, f)
+					scale = math.Max(scale, math.Abs(f))
+				} else {
+					dc = f
+				}
+			}
+		}
+		if scale > 0 {
+			for i := range ac {
+				ac[i] = 0.5 + 0.5/scale*ac[i]
+			}
+		}
+		return
+	}
+	lDC, lAC, lScale := encodeChannel(l, max(3, lx), max(3, ly))
+	pDC, pAC, pScale := encodeChannel(p, 3, 3)
+	qDC, qAC, qScale := encodeChannel(q, 3, 3)
+	var aDC, aScale float64
+	var aAC []float64
+	if hasAlpha {
+		aDC, aAC, aScale = encodeChannel(a, 5, 5)
+	}
+	// constants
+	isLandscape := width > height
+	header24 := round(63*lDC) | round(31.5+31.5*pDC)<<6 | round(31.5+31.5*qDC)<<12 | round(31*lScale)<<18
+	header16 := round(63*pScale)<<3 | round(63*qScale)<<9
+	if hasAlpha {
+		header24 |= 1 << 23
+	}
+	if isLandscape {
+		header16 |= ly | 1<<15
+	} else {
+		header16 |= lx
+	}
+	hash := []byte{
+		byte(header24), byte(header24 >> 8), byte(header24 >> 16),
+		byte(header16), byte(header16 >> 8),
+	}
+	acStart = 5
+	channels := [][]float64{lAC, pAC, qAC}
+	if hasAlpha {
+		hash = append(hash, byte(round(15*aDC)|round(15*aScale)<<4))
+		acStart = 6
+		channels = append(channels, aAC)
+	}
+	// varying factors
+	var acIndex int
+	for _, ac := range channels {
+		for _, f := range ac {
+			k := acStart + acIndex>>1
+			for len(hash) <= k {
+				hash = append(hash, 0)
+			}
+			hash[k] |= byte(round(15*f) << ((acIndex & 1) << 2))
+			acIndex++
+		}
+	}
+	return hash
+}
@@ -0,0 +1,47 @@
+package vips
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholderHash(t *testing.T) {
+	tests := []struct {
+		width, height, bands int
+		blurhash, thumbhash  string
+	}{
+		{20, 13, 3, "L8H2cr-;S}-;~VpGV@x@SvR*SiIn", "3/cBBYCGdpmWtWxWB2tpNWmviPAz"},
+		{7, 30, 4, "LFHB_C_4SN_4%KawX7rqWGXQSiX9", "HeeBAQAHBxYqgFJQjwR2d1mvhZhGh4g="},
+	}
+	for _, tt := range tests {
+		pix := make([]byte, tt.width*tt.height*tt.bands)
+		for i := range pix {
+			pix[i] = byte(i * 37 % 251)
+		}
+		assert.Equal(t, tt.blurhash, BlurHash(pix, tt.width, tt.height, tt.bands, 4, 3))
+		assert.Equal(t, tt.thumbhash, base64.StdEncoding.EncodeToString(ThumbHash(pix, tt.width, tt.height, tt.bands)))
+	}
+	solid := []byte{255, 0, 0}
+	assert.Len(t, BlurHash(solid, 1, 1, 3, 1, 1), 6)
+	assert.Len(t, BlurHash(solid, 1, 1, 3, 9, 9), 6+2*80)
+}
+
+func TestParsePlaceholder(t *testing.T) {
+	tests := []struct {
+		args     string
+		expected *placeholder
+	}{
+		{"blurhash", &placeholder{ComponentsX: 4, ComponentsY: 3}},
+		{"blurhash,5,6", &placeholder{ComponentsX: 5, ComponentsY: 6}},
+		{"blurhash,0,10", &placeholder{ComponentsX: 1, ComponentsY: 9}},
+		{"thumbhash", &placeholder{Thumbhash: true}},
+		{"webp", nil},
+	}
+	for _, tt := range tests {
+		ph, ok := parsePlaceholder(tt.args)
+		assert.Equal(t, tt.expected, ph, tt.args)
+		assert.Equal(t, tt.expected != nil, ok, tt.args)
+	}
+}
@@ -31,9 +31,13 @@ var imageTypeMap = map[string]ImageType{
 // Process implements imagor.Processor interface
 func (v *Processor) Process(
 	ctx context.Context, blob *imagor.Blob, p imagorpath.Params, load imagor.LoadFunc,
-) (*imagor.Blob, error) {
+) (_ *imagor.Blob, err error) {
 	ctx = withContext(ctx)
 	defer contextDone(ctx)
+	defer func() {
+		// libvips evaluation killed by context done
+		err = wrapContextErr(ctx, err)
+	}()
 	var (
 		thumbnailNotSupported bool
 		upscale               = true
@@ -43,12 +47,19 @@ func (v *Processor) Process(
 		orient                int
 		img                   *Image
 		format                = ImageTypeUnknown
+		autoFormat            map[ImageType]bool
+		target                *targetQuality
+		hash                  *placeholder
+		imgStats              *stats
+		imgHash               string
+		sections              *metadataSections
 		maxN                  = v.MaxAnimationFrames
 		maxBytes              int
 		page                  = 1
 		dpi                   = 0
 		focalRects            []focal
-		err                   error
+		rs                    = v.newResample()
+		rf                    = v.newResizeFit()
 	)
 	if p.Trim {
 		thumbnailNotSupported = true
@@ -68,7 +79,17 @@ func (v *Processor) Process(
 		}
 		switch p.Name {
 		case "format":
-			if imageType, ok := imageTypeMap[p.Args]; ok {
+			if accept, ok := parseAutoFormat(p.Args); ok {
+				autoFormat = accept
+			} else if algo, ok := parseImageHash(p.Args); ok {
+				// perceptual hash from the first frame
+				imgHash = algo
+				maxN = 1
+			} else if ph, ok := parsePlaceholder(p.Args); ok {
+				// placeholder hash from the first frame
+				hash = ph
+				maxN = 1
+			} else if imageType, ok := imageTypeMap[p.Args]; ok {
 				format = supportedSaveFormat(imageType)
 				if !IsAnimationSupported(format) {
 					// no frames if export format not support animation
@@ -117,19 +138,61 @@ func (v *Processor) Process(
 				thumbnailNotSupported = true
 			}
 			break
-		case "trim", "focal", "rotate":
+		case "trim", "focal", "rotate", "redact":
 			thumbnailNotSupported = true
 			break
+		case "kernel":
+			rs.setKernel(p.Args)
+			break
+		case "auto_sharpen":
+			rs.parseAutoSharpen(p.Args)
+			break
+		case "fit", "min_width", "min_height", "max_width", "max_height":
+			if rf.parse(p.Name, p.Args) && p.Name == "fit" {
+				upscale = true
+			}
+			break
 		case "strip_exif":
 			stripExif = true
 			break
+		case "stats":
+			imgStats = parseStats(p.Args)
+			break
+		case "metadata":
+			sections = parseMetadataSections(p.Args)
+			break
 		}
 	}
+	if rs.kernel == KernelNearest && upscale {
+		// hard pixels of nearest upscaling, not to be resampled on load
+		thumbnailNotSupported = true
+	}
+	// percentage and aspect ratio dimensions
+	resolveDimensions(&p, 0, 0)
+	pending := pendingDimensions(p)
+	if rf.IsActive() && !pending {
+		rf.apply(&p, &stretch)
+	}
 
 	if !thumbnailNotSupported &&
 		p.CropBottom == 0.0 && p.CropTop == 0.0 && p.CropLeft == 0.0 && p.CropRight == 0.0 {
 		// apply shrink-on-load where possible
-		if p.FitIn {
+		if pending || rf.resolve || rs.hasKernel {
+			// box by header dimensions, output resized after load.
+			// libvips thumbnail resamples with lanczos3 only, shrinks to the kernel ratio
+			if header, err := blob.DecodeHeader(); err == nil && header != nil && dpi == 0 {
+				if size := rf.shrinkSize(
+					p, header.Width, header.Height, stretch, upscale, rs.shrinkRatio(),
+				); size > 0 {
+					if img, err = v.NewThumbnail(
+						ctx, blob, size, size, InterestingNone, SizeDown, maxN, page, dpi,
+					); err != nil {
+						return nil, err
+					}
+					rf.srcWidth, rf.srcHeight = header.Width, header.Height
+				}
+			}
+		} else if p.FitIn {
 			if p.Width > 0 || p.Height > 0 {
 				w := p.Width
 				h := p.Height
@@ -161,7 +224,8 @@ func (v *Processor) Process(
 				thumbnail = true
 			}
 		} else {
-			if p.Width > 0 && p.Height > 0 {
+			// gravity with offsets crops after resize
+			if p.Width > 0 && p.Height > 0 && p.Gravity == "" {
 				interest := InterestingNone
 				if p.Smart {
 					interest = InterestingAttention
@@ -206,14 +270,22 @@ func (v *Processor) Process(
 			}
 		}
 	}
-	if !thumbnail {
+	if img == nil {
 		if thumbnailNotSupported {
 			if img, err = v.NewImage(ctx, blob, maxN, page, dpi); err != nil {
 				return nil, err
 			}
 		} else {
+			w, h := v.MaxWidth, v.MaxHeight
+			if imgHash != "" && !p.Meta {
+				// perceptual hash output from a small thumbnail
+				w, h = imageHashLoadSize, imageHashLoadSize
+			} else if hash != nil && !p.Meta {
+				// placeholder hash output from a tiny thumbnail
+				w, h = placeholderSampleSize, placeholderSampleSize
+			}
 			if img, err = v.NewThumbnail(
-				ctx, blob, v.MaxWidth, v.MaxHeight,
+				ctx, blob, w, h,
 				InterestingNone, SizeDown, maxN, page, dpi,
 			); err != nil {
 				return nil, err
@@ -223,12 +295,39 @@ func (v *Processor) Process(
 	// this should be called BEFORE vipscontext.contextDone
 	defer img.Close()
 
+	if rs.sigma > 0 {
+		rs.size = max(img.Width(), img.PageHeight())
+		if thumbnail || rf.srcWidth > 0 {
+			// shrink-on-load, source dimensions from header
+			if header, err := blob.DecodeHeader(); err == nil && header != nil {
+				rs.size = max(rs.size, header.Width, header.Height)
+			}
+		}
+	}
+
 	if orient > 0 {
 		// orient rotate before resize
 		if err = img.Rotate(getAngle(orient)); err != nil {
 			return nil, err
 		}
 	}
+	var redacted bool
+	for _, f := range p.Filters {
+		if f.Name == "redact" && !v.disableFilters[f.Name] {
+			// redact regions of the source image before crop and resize
+			if err = redact(ctx, img, strings.Split(f.Args, ",")...); err != nil {
+				return nil, WrapErr(err)
+			}
+			redacted = true
+		}
+	}
+	if redacted {
+		// exif and its embedded thumbnail may reveal the redacted regions
+		stripExif = true
+		if err = img.RemoveExif(); err != nil {
+			return nil, WrapErr(err)
+		}
+	}
 	var (
 		quality     int
 		bitdepth    int
@@ -296,6 +395,11 @@ func (v *Processor) Process(
 		case "palette":
 			palette = true
 			break
+		case "target_quality":
+			if t, ok := parseTargetQuality(p.Args); ok {
+				target = t
+			}
+			break
 		case "bitdepth":
 			bitdepth, _ = strconv.Atoi(p.Args)
 			break
@@ -304,16 +408,94 @@ func (v *Processor) Process(
 			break
 		}
 	}
-	if err := v.process(ctx, img, p, load, thumbnail, stretch, upscale, focalRects); err != nil {
+	if err := v.process(ctx, img, p, load, thumbnail, stretch, upscale, focalRects, rs, rf); err != nil {
 		return nil, WrapErr(err)
 	}
 	if p.Meta {
 		// metadata without export
-		return imagor.NewBlobFromJsonMarshal(metadata(img, format, stripExif)), nil
+		meta := metadata(img, format, stripExif)
+		if rf.IsActive() {
+			meta.Fit = rf.info()
+		}
+		if sections != nil {
+			sections.Apply(img, meta)
+		}
+		if hash != nil {
+			str, err := hash.Hash(img)
+			if err != nil {
+				return nil, WrapErr(err)
+			}
+			if hash.Thumbhash {
+				meta.Thumbhash = str
+			} else {
+				meta.Blurhash = str
+			}
+		}
+		if imgStats != nil {
+			if err := imgStats.Apply(img, meta); err != nil {
+				return nil, WrapErr(err)
+			}
+		}
+		if imgHash != "" {
+			str, err := imageHash(img, imgHash)
+			if err != nil {
+				return nil, WrapErr(err)
+			}
+			switch imgHash {
+			case "phash":
+				meta.PHash = str
+			case "dhash":
+				meta.DHash = str
+			case "ahash":
+				meta.AHash = str
+			}
+		}
+		return imagor.NewBlobFromJsonMarshal(meta), nil
+	}
+	if imgHash != "" {
+		str, err := imageHash(img, imgHash)
+		if err != nil {
+			return nil, WrapErr(err)
+		}
+		blob := imagor.NewBlobFromBytes([]byte(str))
+		blob.SetContentType("text/plain; charset=utf-8")
+		return blob, nil
+	}
+	if hash != nil {
+		str, err := hash.Hash(img)
+		if err != nil {
+			return nil, WrapErr(err)
+		}
+		blob := imagor.NewBlobFromBytes([]byte(str))
+		blob.SetContentType("text/plain; charset=utf-8")
+		return blob, nil
+	}
+	if policy := v.newMetadataPolicy(p.Filters); policy.IsActive() {
+		if err := policy.Apply(img); err != nil {
+			return nil, WrapErr(err)
+		}
 	}
 	format = supportedSaveFormat(format) // convert to supported export format
+	var candidates []autoFormatCandidate
+	if autoFormat != nil {
+		candidates = autoFormatCandidates(img, blob, autoFormat)
+	}
+	if target != nil && img.Height() > img.PageHeight() {
+		// target quality not supported for animation
+		target = nil
+	}
 	for {
-		buf, err := v.export(img, format, compression, quality, palette, bitdepth, &p.Filters)
+		var buf []byte
+		if len(candidates) > 0 {
+			buf, format, quality, err = v.exportAuto(ctx, img, candidates, target, compression, quality, palette, bitdepth, &p.Filters)
+			candidates = nil // max_bytes continues with the chosen format
+			target = nil
+		} else if target != nil && isTargetQualitySupported(format) {
+			buf, quality, err = v.exportTargetQuality(ctx, img, format, target, compression, palette, bitdepth, &p.Filters)
+			target = nil // max_bytes continues with the target quality
+		} else {
+			buf, err = v.export(img, format, compression, quality, palette, bitdepth, false, &p.Filters)
+		}
 		if err != nil {
 			return nil, WrapErr(err)
 		}
@@ -353,7 +535,7 @@ func (v *Processor) Process(
 }
 
 func (v *Processor) process(
-	ctx context.Context, img *Image, p imagorpath.Params, load imagor.LoadFunc, thumbnail, stretch, upscale bool, focalRects []focal,
+	ctx context.Context, img *Image, p imagorpath.Params, load imagor.LoadFunc, thumbnail, stretch, upscale bool, focalRects []focal, rs *resample, rf *resizeFit,
 ) error {
 	var (
 		origWidth  = float64(img.Width())
@@ -407,6 +589,11 @@ func (v *Processor) process(
 			return err
 		}
 	}
+	if pendingDimensions(p) || rf.resolve {
+		// exact output dimensions of the image after crop
+		width, height := rf.sourceSize(img)
+		rf.resolveParams(&p, &stretch, &upscale, width, height)
+	}
 	var (
 		w = p.Width
 		h = p.Height
@@ -428,17 +615,29 @@ func (v *Processor) process(
 	if !thumbnail {
 		if p.FitIn {
 			if upscale || w < img.Width() || h < img.PageHeight() {
+				if err := rs.resize(img, w, h, true, false); err != nil {
+					return err
+				}
 				if err := img.Thumbnail(w, h, InterestingNone); err != nil {
 					return err
 				}
+				if _, err := v.CheckResolution(img, nil); err != nil {
+					return err
+				}
 			}
 		} else if stretch {
 			if upscale || (w < img.Width() && h < img.PageHeight()) {
+				if err := rs.resize(img, w, h, false, true); err != nil {
+					return err
+				}
 				if err := img.ThumbnailWithSize(
 					w, h, InterestingNone, SizeForce,
 				); err != nil {
 					return err
 				}
+				if _, err := v.CheckResolution(img, nil); err != nil {
+					return err
+				}
 			}
 		} else if upscale || w < img.Width() || h < img.PageHeight() {
 			interest := InterestingCentre
@@ -457,15 +656,24 @@ func (v *Processor) process(
 					interest = InterestingHigh
 				}
 			}
+			cropWidth, cropHeight := float64(img.Width()), float64(img.PageHeight())
+			if err := rs.resize(img, w, h, false, false); err != nil {
+				return err
+			}
 			if len(focalRects) > 0 {
 				focalX, focalY := parseFocalPoint(focalRects...)
 				if err := v.FocalThumbnail(
 					img, w, h,
-					(focalX-cropLeft)/float64(img.Width()),
-					(focalY-cropTop)/float64(img.PageHeight()),
+					(focalX-cropLeft)/cropWidth,
+					(focalY-cropTop)/cropHeight,
 				); err != nil {
 					return err
 				}
+			} else if p.Gravity != "" {
+				gx, gy := parseGravity(p.Gravity)
+				if err := v.GravityThumbnail(img, w, h, gx, gy, p.GravityX, p.GravityY); err != nil {
+					return err
+				}
 			} else {
 				if err := v.Thumbnail(img, w, h, interest, SizeBoth); err != nil {
 					return err
@@ -476,6 +684,16 @@ func (v *Processor) process(
 			}
 		}
 	}
+	if err := rs.sharpen(img); err != nil {
+		return err
+	}
+	if rf.pad && !v.hasFilter(p.Filters, "fill") {
+		// padded by fill filter otherwise
+		if err := v.containPad(ctx, img, w, h); err != nil {
+			return err
+		}
+	}
+	rf.width, rf.height = img.Width(), img.PageHeight()
 	if p.HFlip {
 		if err := img.Flip(DirectionHorizontal); err != nil {
 			return err
@@ -535,6 +753,26 @@ type Metadata struct {
 	Pages       int            `json:"pages"`
 	Bands       int            `json:"bands"`
 	Exif        map[string]any `json:"exif"`
+	Blurhash    string         `json:"blurhash,omitempty"`
+	Thumbhash   string         `json:"thumbhash,omitempty"`
+	PHash       string         `json:"phash,omitempty"`
+	DHash       string         `json:"dhash,omitempty"`
+	AHash       string         `json:"ahash,omitempty"`
+
+	XMP               *XMP           `json:"xmp,omitempty"`
+	IPTC              *IPTC          `json:"iptc,omitempty"`
+	ICC               *ICCProfile    `json:"icc,omitempty"`
+	Color             *ColorInfo     `json:"color,omitempty"`
+	Animation         *AnimationInfo `json:"animation,omitempty"`
+	EmbeddedThumbnail *bool          `json:"embedded_thumbnail,omitempty"`
+	Fit               *FitInfo       `json:"fit,omitempty"`
+
+	DominantColor string         `json:"dominant_color,omitempty"`
+	Palette       []PaletteColor `json:"palette,omitempty"`
+	AverageColor  string         `json:"average_color,omitempty"`
+	Histogram     *Histogram     `json:"histogram,omitempty"`
+	HasAlpha      *bool          `json:"has_alpha,omitempty"`
+	Grayscale     *bool          `json:"grayscale,omitempty"`
 }
 
 func metadata(img *Image, format ImageType, stripExif bool) *Metadata {
@@ -575,7 +813,7 @@ func supportedSaveFormat(format ImageType) ImageType {
 }
 
 func (v *Processor) export(
-	image *Image, format ImageType, compression int, quality int, palette bool, bitdepth int, params *imagorpath.Filters,
+	image *Image, format ImageType, compression int, quality int, palette bool, bitdepth int, lossless bool, params *imagorpath.Filters,
 ) ([]byte, error) {
 	switch format {
 	case ImageTypePNG:
@@ -598,6 +836,7 @@ func (v *Processor) export(
 		if quality > 0 {
 			opts.Quality = quality
 		}
+		opts.Lossless = lossless
 		return image.ExportWebp(opts)
 	case ImageTypeTIFF:
 		opts := NewTiffExportParams()
@@ -666,6 +905,7 @@ func (v *Processor) export(
 		if quality > 0 {
 			opts.Quality = quality
 		}
+		opts.Lossless = lossless
 		return image.ExportAvif(opts)
 	case ImageTypeHEIF:
 		opts := NewHeifExportParams()
@@ -683,7 +923,8 @@ func (v *Processor) export(
 		opts := NewJpegExportParams()
 		if v.MozJPEG {
 			opts.Quality = 75
-			opts.StripMetadata = true
+			// metadata policy preserves allowlisted and injected metadata, ICC filters embed profile
+			opts.StripMetadata = !v.newMetadataPolicy(*params).IsActive() && !hasICCFilter(*params)
 			opts.OptimizeCoding = true
 			opts.Interlace = true
 			opts.OptimizeScans = true
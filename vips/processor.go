@@ -2,11 +2,14 @@ package vips
 
 import (
 	"context"
+	"errors"
 	"math"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/xudaolong/imagor"
 	"go.uber.org/zap"
 )
@@ -17,6 +20,9 @@ type FilterFunc func(ctx context.Context, img *Image, load imagor.LoadFunc, args
 // FilterMap filter handler map
 type FilterMap map[string]FilterFunc
 
+// maxEntitiesSize maximum expanded size of SVG entity references
+const maxEntitiesSize = 1 << 20
+
 var processorLock sync.RWMutex
 var processorCount int
 
@@ -36,9 +42,31 @@ type Processor struct {
 	MaxResolution      int
 	MaxAnimationFrames int
 	MozJPEG            bool
+	AutoFormatBudget   time.Duration
+	MetadataKeep       []string
+	MetadataStripGPS   bool
+	MetadataCopyright  string
+	MetadataArtist     string
+	MetadataURL        string
+	ICCProfilesDir     string
+	FontsDir           string
+	Kernel             string
+	AutoSharpen        float64
+	AutoSharpenRatio   float64
 	Debug              bool
 
-	disableFilters map[string]bool
+	disableFilters          map[string]bool
+	targetQualityIterations *prometheus.HistogramVec
+}
+
+// Describe implements prometheus.Collector for processor metrics
+func (v *Processor) Describe(ch chan<- *prometheus.Desc) {
+	v.targetQualityIterations.Describe(ch)
+}
+
+// Collect implements prometheus.Collector for processor metrics
+func (v *Processor) Collect(ch chan<- prometheus.Metric) {
+	v.targetQualityIterations.Collect(ch)
 }
 
 // NewProcessor create Processor
@@ -50,19 +78,43 @@ func NewProcessor(options ...Option) *Processor {
 		Concurrency:        1,
 		MaxFilterOps:       -1,
 		MaxAnimationFrames: -1,
+		AutoFormatBudget:   time.Second,
+		AutoSharpenRatio:   2,
 		Logger:             zap.NewNop(),
 		disableFilters:     map[string]bool{},
+
+		targetQualityIterations: newTargetQualityIterations(),
 	}
 	v.Filters = FilterMap{
 		"watermark":        v.watermark,
 		"round_corner":     roundCorner,
+		"circle":           circle,
+		"ellipse":          ellipse,
+		"polygon":          polygon,
+		"mask":             v.mask,
+		"border":           v.border,
+		"frame":            frame,
 		"rotate":           rotate,
-		"label":            label,
+		"affine":           v.affine,
+		"perspective":      v.perspective,
+		"template":         v.template,
+		"collage":          v.collage,
+		"label":            v.label,
 		"grayscale":        grayscale,
 		"brightness":       brightness,
 		"background_color": backgroundColor,
 		"contrast":         contrast,
 		"modulate":         modulate,
+		"gamma":            gamma,
+		"levels":           levels,
+		"curves":           curves,
+		"tint":             tint,
+		"sepia":            sepia,
+		"duotone":          duotone,
+		"invert":           invert,
+		"posterize":        posterize,
+		"threshold":        threshold,
+		"equalize":         equalize,
 		"hue":              hue,
 		"saturation":       saturation,
 		"rgb":              rgb,
@@ -70,6 +122,8 @@ func NewProcessor(options ...Option) *Processor {
 		"sharpen":          sharpen,
 		"strip_icc":        stripIcc,
 		"strip_exif":       stripExif,
+		"colorspace":       colorspace,
+		"icc":              v.icc,
 		"trim":             trim,
 		"set_frames":       setFrames,
 		"padding":          v.padding,
@@ -145,7 +199,9 @@ func newImageFromBlob(
 	}
 	if blob.BlobType() == imagor.BlobTypeMemory {
 		buf, width, height, bands, _ := blob.Memory()
-		return LoadImageFromMemory(buf, width, height, bands)
+		img, err := LoadImageFromMemory(buf, width, height, bands)
+		contextEval(ctx, img)
+		return img, err
 	}
 	reader, _, err := blob.NewReader()
 	if err != nil {
@@ -164,8 +220,11 @@ func newImageFromBlob(
 		defer func() {
 			_ = r.Close()
 		}()
-		return loadImageFromBMP(r)
+		img, err = loadImageFromBMP(r)
+		contextEval(ctx, img)
+		return img, err
 	}
+	contextEval(ctx, img)
 	return img, err
 }
 
@@ -182,7 +241,9 @@ func newThumbnailFromBlob(
 	}
 	src := NewSource(reader)
 	contextDefer(ctx, src.Close)
-	return src.LoadThumbnail(width, height, crop, size, params)
+	img, err := src.LoadThumbnail(width, height, crop, size, params)
+	contextEval(ctx, img)
+	return img, err
 }
 
 // NewThumbnail creates new thumbnail with resize and crop from imagor.Blob
@@ -190,6 +251,9 @@ func (v *Processor) NewThumbnail(
 	ctx context.Context, blob *imagor.Blob, width, height int, crop Interesting,
 	size Size, n, page int, dpi int,
 ) (*Image, error) {
+	if err := v.checkHeader(blob, n, page, dpi); err != nil {
+		return nil, err
+	}
 	var params = NewImportParams()
 	if dpi > 0 {
 		params.Density.Set(dpi)
@@ -256,6 +320,9 @@ func (v *Processor) newThumbnailFallback(
 
 // NewImage creates new Image from imagor.Blob
 func (v *Processor) NewImage(ctx context.Context, blob *imagor.Blob, n, page int, dpi int) (*Image, error) {
+	if err := v.checkHeader(blob, n, page, dpi); err != nil {
+		return nil, err
+	}
 	var params = NewImportParams()
 	if dpi > 0 {
 		params.Density.Set(dpi)
@@ -321,6 +388,32 @@ func (v *Processor) FocalThumbnail(img *Image, w, h int, fx, fy float64) (err er
 	return img.ExtractArea(int(left), int(top), w, h)
 }
 
+// GravityThumbnail handles thumbnail with crop positioned by gravity gx, gy from 0 to 1,
+// and x, y offsets in pixels inwards from the edges
+func (v *Processor) GravityThumbnail(img *Image, w, h int, gx, gy float64, x, y int) (err error) {
+	var imageWidth, imageHeight float64
+	// exif orientation greater 5-8 are 90 or 270 degrees, w and h swapped
+	if img.Orientation() > 4 {
+		imageWidth = float64(img.PageHeight())
+		imageHeight = float64(img.Width())
+	} else {
+		imageWidth = float64(img.Width())
+		imageHeight = float64(img.PageHeight())
+	}
+	if float64(w)/float64(h) > imageWidth/imageHeight {
+		if err = img.Thumbnail(w, v.MaxHeight, InterestingNone); err != nil {
+			return
+		}
+	} else {
+		if err = img.Thumbnail(v.MaxWidth, h, InterestingNone); err != nil {
+			return
+		}
+	}
+	left := gravityOffset(img.Width()-w, gx, x)
+	top := gravityOffset(img.PageHeight()-h, gy, y)
+	return img.ExtractArea(left, top, w, h)
+}
+
 func (v *Processor) animatedThumbnailWithCrop(
 	img *Image, w, h int, crop Interesting, size Size,
 ) (err error) {
@@ -360,6 +453,56 @@ func (v *Processor) CheckResolution(img *Image, err error) (*Image, error) {
 	return img, nil
 }
 
+// checkDimensions check transformed image dimensions for image bomb prevention
+func (v *Processor) checkDimensions(width, height float64) error {
+	if width > float64(v.MaxWidth) || height > float64(v.MaxHeight) ||
+		width*height > float64(v.MaxResolution) {
+		return imagor.ErrMaxResolutionExceeded
+	}
+	return nil
+}
+
+// checkHeader check image dimensions decoded from blob header for image bomb prevention,
+// before the image being loaded by libvips
+func (v *Processor) checkHeader(blob *imagor.Blob, n, page, dpi int) error {
+	if blob == nil {
+		return nil
+	}
+	header, err := blob.DecodeHeader()
+	if err != nil || header == nil {
+		// leave it to libvips
+		return nil
+	}
+	if header.Entities > maxEntitiesSize {
+		return imagor.ErrMaxResolutionExceeded
+	}
+	if header.Width == 0 || header.Height == 0 {
+		return nil
+	}
+	var width, height = float64(header.Width), float64(header.Height)
+	if typ := blob.BlobType(); dpi > 0 && (typ == imagor.BlobTypePDF || typ == imagor.BlobTypeSVG) {
+		// rendered at 72 dpi by default
+		width = math.Ceil(width * float64(dpi) / 72)
+		height = math.Ceil(height * float64(dpi) / 72)
+	}
+	pages := 1
+	if isMultiPage(blob, n, page) && header.Pages > 1 {
+		pages = header.Pages
+		if page > 1 || page < -1 {
+			pages = 1
+		} else if n > 1 && n < pages {
+			pages = n
+		} else if n < -1 && -n < pages {
+			pages = -n
+		}
+	}
+	if width > float64(v.MaxWidth) || height > float64(v.MaxHeight) ||
+		width*height*float64(pages) > float64(v.MaxResolution) {
+		return imagor.ErrMaxResolutionExceeded
+	}
+	return nil
+}
+
 func isMultiPage(blob *imagor.Blob, n, page int) bool {
 	return blob != nil && (blob.SupportsAnimation() || blob.BlobType() == imagor.BlobTypePDF) && ((n != 1 && n != 0) || (page != 1 && page != 0))
 }
@@ -386,6 +529,22 @@ func recalculateImage(img *Image, n, page int) (int, int) {
 	return n, page
 }
 
+// wrapContextErr reports error of a killed libvips evaluation as context error,
+// other errors returned as is even if context is done
+func wrapContextErr(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	e := ctx.Err()
+	if e == nil || (!isContextKilled(ctx) && !errors.Is(err, e)) {
+		return err
+	}
+	if errors.Is(e, context.DeadlineExceeded) {
+		return imagor.ErrTimeout
+	}
+	return e
+}
+
 // WrapErr wraps error to become imagor.Error
 func WrapErr(err error) error {
 	if err == nil {
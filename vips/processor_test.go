@@ -2,16 +2,21 @@ package vips
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/bits"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -340,6 +345,28 @@ func TestProcessor(t *testing.T) {
 			http.MethodGet, "/unsafe/dancing-banana.gif", nil))
 		assert.Equal(t, 422, w.Code)
 	})
+	t.Run("resolution exceeded header", func(t *testing.T) {
+		ctx := context.Background()
+		p := NewProcessor(
+			WithMaxResolution(300*300),
+			WithDebug(true),
+		)
+		for _, buf := range [][]byte{
+			[]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x01\x86\xa0\x00\x01\x86\xa0\x08\x06\x00\x00\x00\x00\x00\x00\x00"),
+			[]byte("GIF89a\xFF\xFF\xFF\xFF\x00\x00\x00\x3B\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"),
+			[]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1000" height="1000"></svg>`),
+			[]byte(`<!DOCTYPE svg [<!ENTITY a "&b;&b;"><!ENTITY b "&a;&a;">]><svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"><text>&a;</text></svg>`),
+		} {
+			blob := imagor.NewBlobFromBytes(buf)
+			img, err := p.Process(ctx, blob, imagorpath.Params{}, nil)
+			assert.Empty(t, img)
+			assert.Equal(t, imagor.ErrMaxResolutionExceeded, err, string(buf))
+		}
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "dancing-banana.gif"))
+		img, err := p.Process(ctx, blob, imagorpath.Parse("fit-in/100x100/dancing-banana.gif"), nil)
+		assert.Empty(t, img)
+		assert.Equal(t, imagor.ErrMaxResolutionExceeded, err)
+	})
 	t.Run("invalid BMP", func(t *testing.T) {
 		ctx := context.Background()
 		blob := imagor.NewBlobFromBytes([]byte("BMabcdasdfasdfasdfasdfasdfasdfasdfasdfasdfasdf"))
@@ -351,6 +378,580 @@ func TestProcessor(t *testing.T) {
 		assert.Empty(t, img)
 		assert.Error(t, err)
 	})
+	t.Run("process timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher.png"))
+		p := NewProcessor(
+			WithDebug(true),
+		)
+		img, err := p.Process(ctx, blob, imagorpath.Parse("fit-in/200x200/filters:blur(5):format(jpeg)/gopher.png"), nil)
+		assert.Empty(t, img)
+		assert.Equal(t, imagor.ErrTimeout, err)
+	})
+	t.Run("process error after context done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		ctx = withContext(ctx)
+		defer contextDone(ctx)
+		e := errors.New("vips error")
+		assert.Equal(t, e, wrapContextErr(ctx, e))
+		assert.Equal(t, imagor.ErrTimeout, wrapContextErr(ctx, context.DeadlineExceeded))
+		// killed by eval signal
+		ctx.Value(contextRefKey{}).(*contextRef).killed.Store(true)
+		assert.Equal(t, imagor.ErrTimeout, wrapContextErr(ctx, e))
+		assert.NoError(t, wrapContextErr(ctx, nil))
+
+		ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		ctx = withContext(ctx)
+		defer contextDone(ctx)
+		assert.Equal(t, e, wrapContextErr(ctx, e))
+		assert.Equal(t, context.Canceled, wrapContextErr(ctx, context.Canceled))
+	})
+	t.Run("target quality", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "demo1.jpg"))
+		fixed, err := p.Process(context.Background(), blob,
+			imagorpath.Parse("fit-in/200x200/filters:quality(95):format(webp)/demo1.jpg"), nil)
+		require.NoError(t, err)
+		blob = imagor.NewBlobFromFile(filepath.Join(testDataDir, "demo1.jpg"))
+		out, err := p.Process(context.Background(), blob,
+			imagorpath.Parse("fit-in/200x200/filters:target_quality(ssim,0.9):format(webp)/demo1.jpg"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, imagor.BlobTypeWEBP, out.BlobType())
+		assert.Less(t, out.Size(), fixed.Size())
+	})
+	t.Run("image hash", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		hashes := map[string]string{}
+		for _, algo := range []string{"phash", "dhash", "ahash"} {
+			blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher.png"))
+			out, err := p.Process(context.Background(), blob,
+				imagorpath.Parse("fit-in/300x300/filters:format("+algo+")/gopher.png"), nil)
+			require.NoError(t, err)
+			assert.Equal(t, "text/plain; charset=utf-8", out.ContentType())
+			buf, err := out.ReadAll()
+			require.NoError(t, err)
+			assert.Regexp(t, "^[0-9a-f]{16}$", string(buf))
+			hashes[algo] = string(buf)
+		}
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher.png"))
+		out, err := p.Process(context.Background(), blob,
+			imagorpath.Parse("meta/filters:format(phash)/gopher.png"), nil)
+		require.NoError(t, err)
+		buf, err := out.ReadAll()
+		require.NoError(t, err)
+		var meta Metadata
+		require.NoError(t, json.Unmarshal(buf, &meta))
+		// resized image is perceptually similar
+		a, _ := strconv.ParseUint(hashes["phash"], 16, 64)
+		b, _ := strconv.ParseUint(meta.PHash, 16, 64)
+		assert.LessOrEqual(t, bits.OnesCount64(a^b), 10)
+	})
+	t.Run("metadata policy", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true), WithMetadataKeep("exif:Copyright,exif:Artist,exif:Make"))
+		img := processTestImage(t, p,
+			"fit-in/100x100/filters:copyright(Acme):license_url(https%3A%2F%2Fexample.com):strip_gps()/Canon_40D.jpg", nil)
+		exif := img.Exif()
+		assert.Equal(t, "Acme", exif["Copyright"])
+		assert.Equal(t, "Canon", exif["Make"])
+		assert.Empty(t, exif["Model"])
+		assert.Empty(t, exif["GPSLatitude"])
+		xmp := ParseXMP(img.XMP())
+		require.NotNil(t, xmp)
+		assert.Equal(t, "https://example.com", xmp.WebStatement)
+	})
+	t.Run("template", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		load := func(image string) (*imagor.Blob, error) {
+			if image == "card.json" {
+				return imagor.NewBlobFromBytes([]byte(`{
+					"width": 600, "height": 315, "background": "white",
+					"layers": [
+						{"type": "image", "width": 315, "height": 315, "fit": "cover", "gravity": "north"},
+						{"type": "image", "src": "gopher-front.png", "x": -20, "y": -20, "width": 80, "fit": "contain", "opacity": 0.8, "blend": "multiply"},
+						{"type": "rect", "x": 335, "y": 20, "width": 245, "height": 60, "radius": 8, "color": "{{color}}", "opacity": 0.6},
+						{"type": "text", "x": 335, "y": 100, "width": 245, "size": 28, "bold": true, "text": "{{title}}", "color": "black"},
+						{"type": "text", "x": -10, "y": -10, "size": 16, "markup": true, "text": "<i>{{title}}</i>", "color": "black"}
+					]
+				}`)), nil
+			}
+			if image == "large.json" {
+				return imagor.NewBlobFromBytes([]byte(strings.Repeat(" ", templateMaxSize+1))), nil
+			}
+			return imagor.NewBlobFromFile(filepath.Join(testDataDir, image)), nil
+		}
+		img := processTestImage(t, p,
+			"filters:template(card.json,title=Hello%20%26%20World,color=red):format(png)/gopher.png", load)
+		assert.Equal(t, 600, img.Width())
+		assert.Equal(t, 315, img.PageHeight())
+
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher.png"))
+		_, err := p.Process(context.Background(), blob, imagorpath.Parse(
+			"filters:template(gopher.png)/gopher.png"), load)
+		assert.Equal(t, http.StatusBadRequest, imagor.WrapError(err).Code)
+
+		_, err = p.Process(context.Background(), blob, imagorpath.Parse(
+			"filters:template(large.json)/gopher.png"), load)
+		assert.Equal(t, imagor.ErrMaxSizeExceeded, err)
+	})
+	t.Run("watermark options", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		load := func(image string) (*imagor.Blob, error) {
+			return imagor.NewBlobFromFile(filepath.Join(testDataDir, image)), nil
+		}
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "demo1.jpg"))
+		for _, path := range []string{
+			"fit-in/400x400/filters:watermark(gopher-front.png,gravity=south_east,margin=5p,size=20p,blend=multiply,alpha=30)/demo1.jpg",
+			"fit-in/400x400/filters:watermark(gopher-front.png,tile,angle=30,spacing=20,size=15p,alpha=60)/demo1.jpg",
+			"fit-in/200x150/filters:watermark(gopher-front.png,repeat,bottom,0,30,30,spacing=10,blend=screen)/demo1.jpg",
+			"fit-in/400x400/filters:watermark(gopher-front.png,center,center,angle=15,min_size=100)/demo1.jpg",
+		} {
+			out, err := p.Process(context.Background(), blob, imagorpath.Parse(path), load)
+			require.NoError(t, err, path)
+			buf, err := out.ReadAll()
+			require.NoError(t, err)
+			assert.NotEmpty(t, buf)
+		}
+		// skipped below min size without loading the watermark
+		out, err := p.Process(context.Background(), blob, imagorpath.Parse(
+			"fit-in/100x100/filters:watermark(missing.png,min_size=200)/demo1.jpg"), func(string) (*imagor.Blob, error) {
+			return nil, imagor.ErrNotFound
+		})
+		require.NoError(t, err)
+		assert.NotNil(t, out)
+	})
+	t.Run("redact", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		for _, c := range []struct {
+			path  string
+			x, y  int
+			color []float64
+		}{
+			{"fit-in/100x100/filters:redact(0,0,0.5,0.5,fill,red):format(png)/demo1.jpg", 10, 10, []float64{255, 0, 0}},
+			{"100x100:200x200/filters:redact(100,100,50,50,fill,blue):format(png)/demo1.jpg", 10, 10, []float64{0, 0, 255}},
+			{"50x50/filters:redact(0,0,10,10,fill,red):redact(150,150,50,50,fill,lime):format(png)/demo1.jpg", 45, 45, []float64{0, 255, 0}},
+		} {
+			point, err := processTestImage(t, p, c.path, nil).GetPoint(c.x, c.y)
+			require.NoError(t, err)
+			assert.InDeltaSlice(t, c.color, point[:3], 2, c.path)
+		}
+		for _, path := range []string{
+			"fit-in/100x100/filters:redact(0.2,0.2,0.5,0.5):redact(0,0,20,20,gaussian,8)/dancing-banana.gif",
+			"fit-in/100x100/filters:redact(0.1,0.1,0.3,0.3,pixelate,16)/gopher.png",
+		} {
+			assert.NotZero(t, processTestImage(t, p, path, nil).Width(), path)
+		}
+		_, err := p.Process(context.Background(), imagor.NewBlobFromFile(filepath.Join(testDataDir, "demo1.jpg")),
+			imagorpath.Parse("filters:redact(0,0,a,10)/demo1.jpg"), nil)
+		assert.Equal(t, http.StatusBadRequest, imagor.WrapError(err).Code)
+
+		// exif stripped from the redacted image
+		out, err := p.Process(context.Background(), imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher-exif-orientation-cw90.png")),
+			imagorpath.Parse("meta/filters:redact(0,0,10,10)/gopher-exif-orientation-cw90.png"), nil)
+		require.NoError(t, err)
+		buf, err := out.ReadAll()
+		require.NoError(t, err)
+		meta := Metadata{}
+		require.NoError(t, json.Unmarshal(buf, &meta))
+		assert.Empty(t, meta.Exif)
+	})
+	t.Run("kernel", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		// 2x2 red, blue, lime, white upscaled with hard pixels
+		blob := imagor.NewBlobFromMemory([]byte{
+			255, 0, 0, 0, 0, 255,
+			0, 255, 0, 255, 255, 255,
+		}, 2, 2, 3)
+		img := processTestBlob(t, p, blob, "8x8/filters:kernel(nearest):format(png)/", nil)
+		assert.Equal(t, 8, img.Width())
+		assert.Equal(t, 8, img.Height())
+		for _, c := range []struct {
+			x, y  int
+			color []float64
+		}{
+			{3, 0, []float64{255, 0, 0}},
+			{4, 3, []float64{0, 0, 255}},
+			{0, 4, []float64{0, 255, 0}},
+			{7, 7, []float64{255, 255, 255}},
+		} {
+			point, err := img.GetPoint(c.x, c.y)
+			require.NoError(t, err)
+			assert.Equal(t, c.color, point[:3])
+		}
+
+		p = NewProcessor(WithDebug(true), WithKernel("mitchell"), WithAutoSharpen(0.5))
+		for _, c := range []struct {
+			path          string
+			width, height int
+			animated      bool
+		}{
+			{"fit-in/100x100/demo1.jpg", 100, 100, false},
+			{"stretch/60x40/filters:kernel(cubic):auto_sharpen(1,1.5)/demo1.jpg", 60, 40, false},
+			{"50x80/filters:kernel(linear)/gopher.png", 50, 80, false},
+			{"50x80/filters:kernel(lanczos3):auto_sharpen(0)/gopher.png", 50, 80, false},
+			{"300x100/filters:kernel(lanczos2):focal(589x401:1000x814)/gopher.png", 300, 100, false},
+			{"fit-in/50x50/filters:kernel(nearest)/dancing-banana.gif", 47, 50, true},
+			{"40x30/filters:kernel(linear)/dancing-banana.gif", 40, 30, true},
+		} {
+			img := processTestImage(t, p, c.path, nil)
+			assert.Equal(t, c.width, img.Width(), c.path)
+			assert.Equal(t, c.height, img.PageHeight(), c.path)
+			assert.Equal(t, c.animated, isAnimated(img), c.path)
+		}
+	})
+	t.Run("fit", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		for _, c := range []struct {
+			path          string
+			width, height int
+		}{
+			{"400x300/filters:fit(cover)/demo1.jpg", 400, 300},
+			{"fit-in/400x300/filters:fit(contain):format(png)/demo1.jpg", 400, 300},
+			{"400x300/filters:fit(fill)/demo1.jpg", 400, 300},
+			{"400x300/filters:fit(inside)/demo1.jpg", 300, 300},
+			{"400x300/filters:fit(inside):no_upscale()/demo1.jpg", 200, 200},
+			{"400x300/filters:fit(outside)/demo1.jpg", 400, 400},
+			{"400x300/filters:fit(cover):max_width(200)/demo1.jpg", 200, 150},
+			{"fit-in/400x300/filters:max_width(150)/demo1.jpg", 150, 150},
+			{"filters:min_width(400)/demo1.jpg", 400, 400},
+			{"0x100/filters:fit(outside):max_width(80)/gopher.png", 73, 100},
+			{"100x50/filters:fit(contain)/dancing-banana.gif", 100, 50},
+			{"400x300/filters:fit(contain):fill(white):format(png)/demo1.jpg", 400, 300},
+		} {
+			img := processTestImage(t, p, c.path, nil)
+			assert.Equal(t, c.width, img.Width(), c.path)
+			assert.Equal(t, c.height, img.PageHeight(), c.path)
+		}
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "demo1.jpg"))
+		out, err := p.Process(context.Background(), blob,
+			imagorpath.Parse("meta/400x300/filters:fit(outside)/demo1.jpg"), nil)
+		require.NoError(t, err)
+		buf, err := out.ReadAll()
+		require.NoError(t, err)
+		var meta Metadata
+		require.NoError(t, json.Unmarshal(buf, &meta))
+		assert.Equal(t, &FitInfo{Mode: "outside", Width: 400, Height: 400}, meta.Fit)
+		assert.Equal(t, 400, meta.Width)
+		assert.Equal(t, 400, meta.Height)
+	})
+	t.Run("path dimensions", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		for _, c := range []struct {
+			path          string
+			width, height int
+		}{
+			{"100x16:9/demo1.jpg", 100, 56},
+			{"16:9x90/demo1.jpg", 160, 90},
+			{"0x16:9/demo1.jpg", 200, 113},
+			{"50%25x0/demo1.jpg", 100, 100},
+			{"fit-in/50%25x25%25/demo1.jpg", 50, 50},
+			{"50%25x10%25/filters:fit(outside)/demo1.jpg", 100, 100},
+			{"100x50/north-east+10+20/demo1.jpg", 100, 50},
+			{"40x30/south_west/dancing-banana.gif", 40, 30},
+		} {
+			img := processTestImage(t, p, c.path, nil)
+			assert.Equal(t, c.width, img.Width(), c.path)
+			assert.Equal(t, c.height, img.PageHeight(), c.path)
+		}
+		// red, lime, blue, white columns cropped by gravity and offsets
+		for _, c := range []struct {
+			path  string
+			color []float64
+		}{
+			{"2x2/east/filters:format(png)/", []float64{0, 0, 255}},
+			{"2x2/east+1+0/filters:format(png)/", []float64{0, 255, 0}},
+			{"2x2/west/filters:format(png)/", []float64{255, 0, 0}},
+			{"2x2/west+1-1/filters:format(png)/", []float64{0, 255, 0}},
+		} {
+			blob := imagor.NewBlobFromMemory([]byte{
+				255, 0, 0, 0, 255, 0, 0, 0, 255, 255, 255, 255,
+				255, 0, 0, 0, 255, 0, 0, 0, 255, 255, 255, 255,
+			}, 4, 2, 3)
+			img := processTestBlob(t, p, blob, c.path, nil)
+			assert.Equal(t, 2, img.Width(), c.path)
+			point, err := img.GetPoint(0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, c.color, point[:3], c.path)
+		}
+	})
+	t.Run("tone", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		for _, image := range []string{"gopher.png", "dancing-banana.gif", "2bands.png", "demo1.jpg"} {
+			for _, filter := range []string{
+				"gamma(2.2)", "levels(20,230,1.2)", "curves(0x0:64x40:192x220:255x255,,0x20:255x255)",
+				"tint(orange,40)", "sepia()", "sepia(50)", "duotone(navy,pink)", "invert()",
+				"posterize(4)", "threshold(100)", "equalize()",
+			} {
+				path := "fit-in/100x100/filters:" + filter + "/" + image
+				img := processTestImage(t, p, path, nil)
+				assert.Equal(t, image != "demo1.jpg", img.HasAlpha(), path)
+				assert.Equal(t, image == "dancing-banana.gif", isAnimated(img), path)
+			}
+		}
+		// 2x1 of rgb(200,100,50) and rgb(20,40,60)
+		for _, c := range []struct {
+			filter string
+			colors [2][]float64
+		}{
+			{"invert()", [2][]float64{{55, 155, 205}, {235, 215, 195}}},
+			{"threshold(100)", [2][]float64{{255, 255, 255}, {0, 0, 0}}},
+			{"levels(20,230,1)", [2][]float64{{219, 97, 36}, {0, 24, 49}}},
+			{"sepia()", [2][]float64{{165, 147, 114}, {50, 45, 35}}},
+		} {
+			blob := imagor.NewBlobFromMemory([]byte{200, 100, 50, 20, 40, 60}, 2, 1, 3)
+			img := processTestBlob(t, p, blob, "filters:"+c.filter+":format(png)/", nil)
+			for x, color := range c.colors {
+				point, err := img.GetPoint(x, 0)
+				require.NoError(t, err)
+				assert.InDeltaSlice(t, color, point[:3], 1, c.filter)
+			}
+		}
+	})
+	t.Run("shapes", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		load := func(image string) (*imagor.Blob, error) {
+			return imagor.NewBlobFromFile(filepath.Join(testDataDir, image)), nil
+		}
+		for _, c := range []struct {
+			path          string
+			width, height int
+			alpha         bool
+		}{
+			{"200x150/filters:circle():format(png)/demo1.jpg", 200, 150, true},
+			{"200x150/filters:ellipse(white):format(png)/demo1.jpg", 200, 150, false},
+			{"200x150/filters:polygon(0.5x0:1x1:0x1):format(png)/demo1.jpg", 200, 150, true},
+			{"200x150/filters:mask(gopher-front.png):format(png)/demo1.jpg", 200, 150, true},
+			{"200x150/filters:mask(demo1.jpg,luminance):format(png)/demo1.jpg", 200, 150, true},
+			{"200x150/filters:border(10,red):format(png)/demo1.jpg", 220, 170, false},
+			{"200x150/filters:border(10,red,30):format(png)/demo1.jpg", 220, 170, true},
+			{"200x150/filters:frame(10,blue,20):format(png)/demo1.jpg", 200, 150, true},
+			{"100x100/filters:circle():border(4,white,50)/dancing-banana.gif", 108, 108, true},
+		} {
+			img := processTestImage(t, p, c.path, load)
+			assert.Equal(t, c.width, img.Width(), c.path)
+			assert.Equal(t, c.height, img.PageHeight(), c.path)
+			assert.Equal(t, c.alpha, img.HasAlpha(), c.path)
+		}
+	})
+	t.Run("collage", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		load := func(image string) (*imagor.Blob, error) {
+			return imagor.NewBlobFromFile(filepath.Join(testDataDir, image)), nil
+		}
+		img := processTestImage(t, p,
+			"fit-in/100x100/filters:collage(gopher-front.png,dancing-banana.gif,demo1.jpg,cell=100x100,gutter=10,captions):format(png)/gopher.png", load)
+		assert.Equal(t, 230, img.Width())
+		assert.Equal(t, 230+2*21, img.PageHeight())
+
+		img = processTestImage(t, p,
+			"fit-in/100x100/filters:collage(gopher-front.png,demo1.jpg,layout=masonry,cols=3,background=none):format(png)/gopher.png", load)
+		assert.True(t, img.HasAlpha())
+	})
+	t.Run("rich label", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		img := processTestImage(t, p,
+			"200x200/filters:label(%D8%B3%D9%84%D8%A7%D9%85%20%26%20%E4%BD%A0%E5%A5%BD%20%2419.99,-10,bottom,24,white,0,sans,"+
+				"bold,width=80p,stroke=2,stroke_color=black,shadow=3,shadow_blur=2,background=red,padding=6)/gopher.png", nil)
+		assert.Equal(t, 200, img.Width())
+		assert.Equal(t, 200, img.PageHeight())
+
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher.png"))
+		_, err := p.Process(context.Background(), blob, imagorpath.Parse(
+			"200x200/filters:label(sale,center,center,20,white,0,sans,fontfile=missing.ttf)/gopher.png"),
+			func(image string) (*imagor.Blob, error) {
+				return nil, imagor.ErrNotFound
+			})
+		assert.Equal(t, imagor.ErrNotFound, err)
+	})
+	t.Run("transform", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		img := processTestImage(t, p, "200x200/filters:rotate(45,none,bilinear)/gopher.png", nil)
+		assert.InDelta(t, 283, img.Width(), 2)
+		assert.True(t, img.HasAlpha())
+
+		img = processTestImage(t, p, "200x200/filters:rotate(45,crop)/gopher.png", nil)
+		assert.InDelta(t, 141, img.Width(), 2)
+		assert.InDelta(t, 141, img.PageHeight(), 2)
+
+		// negative angles rotate clockwise
+		img = processTestImage(t, p, "200x100/filters:rotate(-90)/gopher.png", nil)
+		assert.Equal(t, 100, img.Width())
+		assert.Equal(t, 200, img.PageHeight())
+
+		// interpolated rotation not applicable to animated images
+		img = processTestImage(t, p, "100x150/filters:rotate(45)/dancing-banana.gif", nil)
+		assert.True(t, isAnimated(img))
+		assert.Equal(t, 100, img.Width())
+		assert.Equal(t, 150, img.PageHeight())
+
+		img = processTestImage(t, p, "200x200/filters:affine(1,0.5,0,1,white)/gopher.png", nil)
+		assert.InDelta(t, 300, img.Width(), 2)
+		assert.Equal(t, 200, img.PageHeight())
+
+		img = processTestImage(t, p, "200x200/filters:perspective(0.1,0.1,0.9,0,1,1,0,0.9):format(jpeg)/gopher.png", nil)
+		assert.Equal(t, 201, img.Width())
+		assert.Equal(t, 201, img.PageHeight())
+
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher.png"))
+		_, err := p.Process(context.Background(), blob,
+			imagorpath.Parse("200x200/filters:affine(100,0,0,1)/gopher.png"), nil)
+		assert.Equal(t, imagor.ErrMaxResolutionExceeded, err)
+	})
+	t.Run("colorspace", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		img := processTestImage(t, p, "fit-in/100x100/filters:colorspace(cmyk,perceptual):format(jpeg)/Canon_40D.jpg", nil)
+		assert.Equal(t, InterpretationCMYK, img.Interpretation())
+		assert.True(t, img.HasICCProfile())
+
+		gray := processTestImage(t, p, "fit-in/100x100/filters:colorspace(gray)/gopher.png", nil)
+		assert.Equal(t, InterpretationBW, gray.Interpretation())
+		assert.False(t, gray.HasICCProfile())
+
+		p = NewProcessor(WithDebug(true), WithICCProfilesDir(t.TempDir()))
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher.png"))
+		_, err := p.Process(context.Background(), blob,
+			imagorpath.Parse("filters:icc(..%2Fsecret)/gopher.png"), nil)
+		assert.Equal(t, imagor.NewError("icc profile not found", http.StatusBadRequest), err)
+
+		// 16-bit source preserved
+		buf, err := os.ReadFile(filepath.Join(testDataDir, "gopher.png"))
+		require.NoError(t, err)
+		src, err := LoadImageFromBuffer(buf, nil)
+		require.NoError(t, err)
+		defer src.Close()
+		require.NoError(t, src.ToColorSpace(InterpretationRGB16))
+		require.True(t, src.Is16Bit())
+		buf, err = src.ExportPng(nil)
+		require.NoError(t, err)
+		dir := t.TempDir()
+		p3 := processTestBlob(t, p, imagor.NewBlobFromBytes(buf), "filters:colorspace(p3)/", nil)
+		require.True(t, p3.HasICCProfile())
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "p3.icc"), p3.ICCProfile(), 0644))
+		p = NewProcessor(WithDebug(true), WithICCProfilesDir(dir))
+		for _, path := range []string{
+			"filters:colorspace(srgb)/",
+			"filters:colorspace(p3,perceptual)/",
+			"filters:colorspace(gray)/",
+			"filters:icc(p3)/",
+			"filters:icc(p3.icc,absolute)/",
+		} {
+			img := processTestBlob(t, p, imagor.NewBlobFromBytes(buf), path, nil)
+			assert.True(t, img.Is16Bit(), path)
+		}
+	})
+	t.Run("metadata sections", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "Canon_40D.jpg"))
+		out, err := p.Process(context.Background(), blob,
+			imagorpath.Parse("meta/filters:metadata(color,thumbnail)/Canon_40D.jpg"), nil)
+		require.NoError(t, err)
+		buf, err := out.ReadAll()
+		require.NoError(t, err)
+		var meta Metadata
+		require.NoError(t, json.Unmarshal(buf, &meta))
+		require.NotNil(t, meta.Color)
+		assert.Equal(t, "srgb", meta.Color.Interpretation)
+		assert.Equal(t, 8, meta.Color.BitDepth)
+		assert.Equal(t, float64(72), meta.Color.DPI)
+		assert.NotNil(t, meta.EmbeddedThumbnail)
+		assert.Nil(t, meta.XMP)
+		assert.Nil(t, meta.Animation)
+
+		blob = imagor.NewBlobFromFile(filepath.Join(testDataDir, "dancing-banana.gif"))
+		out, err = p.Process(context.Background(), blob,
+			imagorpath.Parse("meta/filters:metadata(animation)/dancing-banana.gif"), nil)
+		require.NoError(t, err)
+		buf, err = out.ReadAll()
+		require.NoError(t, err)
+		meta = Metadata{}
+		require.NoError(t, json.Unmarshal(buf, &meta))
+		require.NotNil(t, meta.Animation)
+		assert.NotEmpty(t, meta.Animation.Delays)
+	})
+	t.Run("stats", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher.png"))
+		out, err := p.Process(context.Background(), blob,
+			imagorpath.Parse("meta/filters:stats(dominant,palette,3,alpha)/gopher.png"), nil)
+		require.NoError(t, err)
+		buf, err := out.ReadAll()
+		require.NoError(t, err)
+		var meta Metadata
+		require.NoError(t, json.Unmarshal(buf, &meta))
+		assert.Regexp(t, "^#[0-9a-f]{6}$", meta.DominantColor)
+		assert.LessOrEqual(t, len(meta.Palette), 3)
+		assert.Equal(t, meta.DominantColor, meta.Palette[0].Color)
+		require.NotNil(t, meta.HasAlpha)
+		assert.Nil(t, meta.Grayscale)
+		assert.Nil(t, meta.Histogram)
+	})
+	t.Run("placeholder", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true))
+		blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, "gopher.png"))
+		out, err := p.Process(context.Background(), blob,
+			imagorpath.Parse("fit-in/200x200/filters:format(blurhash,5,4)/gopher.png"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "text/plain; charset=utf-8", out.ContentType())
+		buf, err := out.ReadAll()
+		require.NoError(t, err)
+		assert.Len(t, buf, 6+2*(5*4-1))
+
+		blob = imagor.NewBlobFromFile(filepath.Join(testDataDir, "dancing-banana.gif"))
+		out, err = p.Process(context.Background(), blob,
+			imagorpath.Parse("meta/filters:format(thumbhash)/dancing-banana.gif"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, imagor.BlobTypeJSON, out.BlobType())
+		buf, err = out.ReadAll()
+		require.NoError(t, err)
+		assert.Contains(t, string(buf), `"thumbhash":"`)
+		assert.NotContains(t, string(buf), `"blurhash"`)
+	})
+	t.Run("format auto", func(t *testing.T) {
+		p := NewProcessor(WithDebug(true), WithAutoFormatBudget(time.Second*10))
+		tests := []struct {
+			path  string
+			types []imagor.BlobType
+		}{
+			{"fit-in/100x100/filters:format(auto)/demo1.jpg", []imagor.BlobType{imagor.BlobTypeJPEG}},
+			{"fit-in/100x100/filters:format(auto,webp)/demo1.jpg", []imagor.BlobType{imagor.BlobTypeJPEG, imagor.BlobTypeWEBP}},
+			{"fit-in/100x100/filters:format(auto)/gopher.png", []imagor.BlobType{imagor.BlobTypePNG}},
+			{"fit-in/100x100/filters:format(auto,avif,webp)/gopher.png", []imagor.BlobType{imagor.BlobTypePNG, imagor.BlobTypeWEBP}},
+			{"filters:format(auto,avif)/dancing-banana.gif", []imagor.BlobType{imagor.BlobTypeGIF}},
+		}
+		for _, tt := range tests {
+			params := imagorpath.Parse(tt.path)
+			blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, params.Image))
+			out, err := p.Process(context.Background(), blob, params, nil)
+			require.NoError(t, err, tt.path)
+			assert.Contains(t, tt.types, out.BlobType(), tt.path)
+		}
+	})
+}
+
+// processTestImage processes the test data image of path, returns all frames of the result image
+func processTestImage(t *testing.T, p *Processor, path string, load imagor.LoadFunc) *Image {
+	t.Helper()
+	blob := imagor.NewBlobFromFile(filepath.Join(testDataDir, imagorpath.Parse(path).Image))
+	return processTestBlob(t, p, blob, path, load)
+}
+
+// processTestBlob processes blob by path, returns all frames of the result image
+func processTestBlob(t *testing.T, p *Processor, blob *imagor.Blob, path string, load imagor.LoadFunc) *Image {
+	t.Helper()
+	out, err := p.Process(context.Background(), blob, imagorpath.Parse(path), load)
+	require.NoError(t, err, path)
+	buf, err := out.ReadAll()
+	require.NoError(t, err, path)
+	params := NewImportParams()
+	params.NumPages.Set(-1)
+	img, err := LoadImageFromBuffer(buf, params)
+	require.NoError(t, err, path)
+	t.Cleanup(img.Close)
+	return img
 }
 
 func doGoldenTests(t *testing.T, resultDir string, tests []test, opts ...Option) {
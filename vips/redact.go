@@ -0,0 +1,145 @@
+package vips
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/xudaolong/imagor"
+)
+
+// redactMinBlock min pixelate block size of redact
+const redactMinBlock = 4
+
+// parseRedactRegion parses x,y,w,h of redact filter in pixels of the source image,
+// or fractions of the image dimensions if x, y below 1 and w, h not above 1,
+// clamped within the image
+func parseRedactRegion(args []string, width, height int) (x, y, w, h int, ok bool) {
+	if len(args) < 4 {
+		return
+	}
+	var v [4]float64
+	for i := 0; i < 4; i++ {
+		var err error
+		if v[i], err = strconv.ParseFloat(strings.TrimSpace(args[i]), 64); err != nil {
+			return
+		}
+	}
+	if v[0] < 1 && v[1] < 1 && v[2] <= 1 && v[3] <= 1 {
+		v[0], v[2] = v[0]*float64(width), v[2]*float64(width)
+		v[1], v[3] = v[1]*float64(height), v[3]*float64(height)
+	}
+	left, top := max(0, math.Floor(v[0])), max(0, math.Floor(v[1]))
+	right := min(float64(width), math.Ceil(v[0]+v[2]))
+	bottom := min(float64(height), math.Ceil(v[1]+v[3]))
+	if right <= left || bottom <= top {
+		return
+	}
+	return int(left), int(top), int(right - left), int(bottom - top), true
+}
+
+// orientRegion maps region x,y,w,h of the image displayed by exif orientation
+// to the stored image of width x height
+func orientRegion(orientation, x, y, w, h, width, height int) (int, int, int, int) {
+	switch orientation {
+	case 2:
+		return width - x - w, y, w, h
+	case 3:
+		return width - x - w, height - y - h, w, h
+	case 4:
+		return x, height - y - h, w, h
+	case 5:
+		return y, x, h, w
+	case 6:
+		return y, height - x - w, h, w
+	case 7:
+		return width - y - h, height - x - w, h, w
+	case 8:
+		return width - y - h, x, h, w
+	}
+	return x, y, w, h
+}
+
+// redact obscures the region x,y,w,h[,mode[,value]] of every frame by mode
+// pixelate of block size, gaussian blur of sigma or fill of color.
+// Applied to the source image before crop and resize,
+// coordinates relative to the image displayed by exif orientation
+func redact(ctx context.Context, img *Image, args ...string) (err error) {
+	pageHeight := img.PageHeight()
+	orientation := img.Orientation()
+	width, height := img.Width(), pageHeight
+	if orientation > 4 {
+		// exif orientation greater 5-8 are 90 or 270 degrees, w and h swapped
+		width, height = height, width
+	}
+	x, y, w, h, ok := parseRedactRegion(args, width, height)
+	if !ok {
+		return imagor.NewError("invalid redact region", http.StatusBadRequest)
+	}
+	x, y, w, h = orientRegion(orientation, x, y, w, h, img.Width(), pageHeight)
+	var mode, value string
+	if len(args) > 4 {
+		mode = strings.TrimSpace(args[4])
+	}
+	if len(args) > 5 {
+		value = strings.TrimSpace(args[5])
+	}
+	if err = prepareTone(img); err != nil {
+		return
+	}
+	n := img.Height() / pageHeight
+	for i := 0; i < n; i++ {
+		var region *Image
+		if region, err = img.Copy(); err != nil {
+			return
+		}
+		contextDefer(ctx, region.Close)
+		// single page so that extract area applies to the frame only
+		if err = region.SetPageHeight(region.Height()); err != nil {
+			return
+		}
+		if err = region.ExtractArea(x, i*pageHeight+y, w, h); err != nil {
+			return
+		}
+		if err = redactRegion(region, mode, value); err != nil {
+			return
+		}
+		if err = img.Insert(region, x, i*pageHeight+y); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func redactRegion(region *Image, mode, value string) error {
+	w, h := region.Width(), region.Height()
+	switch mode {
+	case "gaussian":
+		sigma, _ := strconv.ParseFloat(value, 64)
+		if sigma <= 0 {
+			sigma = float64(max(w, h)) / 10
+		}
+		return region.GaussianBlur(max(sigma, 1))
+	case "fill":
+		c := getColor(nil, value)
+		scale := 1.0
+		if region.Is16Bit() {
+			scale = 257
+		}
+		a := []float64{0, 0, 0}
+		b := []float64{float64(c.R) * scale, float64(c.G) * scale, float64(c.B) * scale}
+		if region.HasAlpha() {
+			a = append(a, 0)
+			b = append(b, 255*scale)
+		}
+		return region.Linear(a, b)
+	default:
+		block, _ := strconv.Atoi(value)
+		if block <= 0 {
+			block = min(w, h) / 10
+		}
+		return region.Pixelate(min(max(block, redactMinBlock), w, h))
+	}
+}
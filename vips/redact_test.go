@@ -0,0 +1,46 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRedactRegion(t *testing.T) {
+	x, y, w, h, ok := parseRedactRegion([]string{"10", "20", "30", "40"}, 200, 100)
+	assert.True(t, ok)
+	assert.Equal(t, []int{10, 20, 30, 40}, []int{x, y, w, h})
+
+	x, y, w, h, ok = parseRedactRegion([]string{"0.25", "0.5", "0.5", "0.25", "pixelate"}, 200, 100)
+	assert.True(t, ok)
+	assert.Equal(t, []int{50, 50, 100, 25}, []int{x, y, w, h})
+
+	// clamped within the image
+	x, y, w, h, ok = parseRedactRegion([]string{"-10", "90", "50", "50"}, 200, 100)
+	assert.True(t, ok)
+	assert.Equal(t, []int{0, 90, 40, 10}, []int{x, y, w, h})
+
+	_, _, _, _, ok = parseRedactRegion([]string{"300", "0", "10", "10"}, 200, 100)
+	assert.False(t, ok)
+	_, _, _, _, ok = parseRedactRegion([]string{"0", "0", "10"}, 200, 100)
+	assert.False(t, ok)
+	_, _, _, _, ok = parseRedactRegion([]string{"0", "0", "a", "10"}, 200, 100)
+	assert.False(t, ok)
+}
+
+func TestOrientRegion(t *testing.T) {
+	// region 10,20,30,40 of the displayed image, stored image 200x100
+	for orientation, expected := range map[int][]int{
+		1: {10, 20, 30, 40},
+		2: {160, 20, 30, 40},
+		3: {160, 40, 30, 40},
+		4: {10, 40, 30, 40},
+		5: {20, 10, 40, 30},
+		6: {20, 60, 40, 30},
+		7: {140, 60, 40, 30},
+		8: {140, 10, 40, 30},
+	} {
+		x, y, w, h := orientRegion(orientation, 10, 20, 30, 40, 200, 100)
+		assert.Equal(t, expected, []int{x, y, w, h}, orientation)
+	}
+}
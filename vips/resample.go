@@ -0,0 +1,122 @@
+package vips
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// autoSharpenSigma default sigma of auto_sharpen filter
+const autoSharpenSigma = 0.5
+
+// resample resampling kernel of resize and unsharp mask after downscale
+type resample struct {
+	kernel    Kernel
+	hasKernel bool
+	sigma     float64
+	ratio     float64
+	size      int // longer side of the source image
+}
+
+func (v *Processor) newResample() *resample {
+	rs := &resample{
+		kernel: KernelLanczos3,
+		sigma:  v.AutoSharpen,
+		ratio:  v.AutoSharpenRatio,
+	}
+	rs.setKernel(v.Kernel)
+	return rs
+}
+
+// setKernel sets resampling kernel by name, ignored if unknown.
+// lanczos3 is the kernel of libvips thumbnail hence resized by thumbnail as is
+func (rs *resample) setKernel(name string) {
+	if kernel, ok := kernels[strings.ToLower(strings.TrimSpace(name))]; ok {
+		rs.kernel = kernel
+		rs.hasKernel = kernel != KernelLanczos3
+	}
+}
+
+// shrinkRatio ratio of the image shrunk on load to the output,
+// so that the resampling kernel resizes from a larger image
+func (rs *resample) shrinkRatio() float64 {
+	if rs.hasKernel {
+		return 2
+	}
+	return 1
+}
+
+// parseAutoSharpen parses sigma[,ratio] of auto_sharpen filter, sigma 0 to disable
+func (rs *resample) parseAutoSharpen(args string) {
+	splits := strings.Split(args, ",")
+	rs.sigma = autoSharpenSigma
+	if s := strings.TrimSpace(splits[0]); s != "" {
+		if sigma, err := strconv.ParseFloat(s, 64); err == nil && sigma >= 0 {
+			rs.sigma = sigma
+		}
+	}
+	if len(splits) > 1 {
+		if ratio, err := strconv.ParseFloat(strings.TrimSpace(splits[1]), 64); err == nil && ratio > 1 {
+			rs.ratio = ratio
+		}
+	}
+}
+
+// resampleScale horizontal and vertical scale of width x height to w x h,
+// fit within w x h if fitIn, exact w x h if stretch, cover w x h otherwise
+func resampleScale(width, height, w, h int, fitIn, stretch bool) (hscale, vscale float64) {
+	hscale = float64(w) / float64(width)
+	vscale = float64(h) / float64(height)
+	if stretch {
+		return
+	}
+	if fitIn {
+		hscale = math.Min(hscale, vscale)
+	} else {
+		hscale = math.Max(hscale, vscale)
+	}
+	return hscale, hscale
+}
+
+// resize resizes image with the resampling kernel prior to thumbnail,
+// so that the thumbnail only crops if needed
+func (rs *resample) resize(img *Image, w, h int, fitIn, stretch bool) error {
+	if !rs.hasKernel || w <= 0 || h <= 0 {
+		return nil
+	}
+	width, height := img.Width(), img.PageHeight()
+	// exif orientation greater 5-8 are 90 or 270 degrees, rotated by thumbnail
+	rotated := img.Orientation() > 4
+	if rotated {
+		width, height = height, width
+	}
+	hscale, vscale := resampleScale(width, height, w, h, fitIn, stretch)
+	if rotated {
+		hscale, vscale = vscale, hscale
+	}
+	if hscale == 1 && vscale == 1 {
+		return nil
+	}
+	pageHeight := img.PageHeight()
+	if img.Height() == pageHeight {
+		return img.Resize(hscale, vscale, rs.kernel)
+	}
+	// keep whole pages of animation
+	newPageHeight := max(1, int(math.Round(float64(pageHeight)*vscale)))
+	if err := img.Resize(hscale, float64(newPageHeight)/float64(pageHeight), rs.kernel); err != nil {
+		return err
+	}
+	return img.SetPageHeight(newPageHeight)
+}
+
+// sharpen applies unsharp mask if the image downscaled from the source by ratio,
+// skipped for animation
+func (rs *resample) sharpen(img *Image) error {
+	if rs.sigma <= 0 || rs.size <= 0 || isAnimated(img) {
+		return nil
+	}
+	if float64(rs.size)/float64(max(img.Width(), img.PageHeight())) < rs.ratio {
+		return nil
+	}
+	return img.Sharpen(rs.sigma, 1, 2)
+}
@@ -0,0 +1,45 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResampleScale(t *testing.T) {
+	h, v := resampleScale(200, 100, 50, 50, true, false)
+	assert.Equal(t, []float64{0.25, 0.25}, []float64{h, v})
+
+	h, v = resampleScale(200, 100, 50, 50, false, false)
+	assert.Equal(t, []float64{0.5, 0.5}, []float64{h, v})
+
+	h, v = resampleScale(200, 100, 50, 50, false, true)
+	assert.Equal(t, []float64{0.25, 0.5}, []float64{h, v})
+
+	h, v = resampleScale(2, 2, 8, 8, false, false)
+	assert.Equal(t, []float64{4, 4}, []float64{h, v})
+}
+
+func TestResampleParse(t *testing.T) {
+	rs := &resample{kernel: KernelLanczos3, ratio: 2}
+	rs.setKernel("unknown")
+	assert.False(t, rs.hasKernel)
+	rs.setKernel(" Nearest")
+	assert.True(t, rs.hasKernel)
+	assert.Equal(t, KernelNearest, rs.kernel)
+	assert.Equal(t, 2.0, rs.shrinkRatio())
+	rs.setKernel("lanczos3")
+	assert.False(t, rs.hasKernel)
+	assert.Equal(t, KernelLanczos3, rs.kernel)
+	assert.Equal(t, 1.0, rs.shrinkRatio())
+
+	rs.parseAutoSharpen("")
+	assert.Equal(t, autoSharpenSigma, rs.sigma)
+	assert.Equal(t, 2.0, rs.ratio)
+	rs.parseAutoSharpen("1.2,3")
+	assert.Equal(t, 1.2, rs.sigma)
+	assert.Equal(t, 3.0, rs.ratio)
+	rs.parseAutoSharpen("0,0.5")
+	assert.Equal(t, 0.0, rs.sigma)
+	assert.Equal(t, 3.0, rs.ratio)
+}
@@ -0,0 +1,233 @@
+package vips
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/xudaolong/imagor"
+)
+
+// shapeMaxPoints max number of polygon points
+const shapeMaxPoints = 100
+
+// newShapeImage loads SVG elements rendered on transparent canvas of width x height
+func newShapeImage(width, height int, elements string) (*Image, error) {
+	return LoadImageFromBuffer([]byte(fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">%s</svg>`,
+		width, height, width, height, elements,
+	)), nil)
+}
+
+// replicatePages replicates single page overlay to the number of pages of image
+func replicatePages(overlay, img *Image) error {
+	if n := img.Height() / img.PageHeight(); n > 1 {
+		return overlay.Replicate(1, n)
+	}
+	return nil
+}
+
+// clipShape clips image to SVG shape element, transparent outside the shape
+// or flattened on background color if specified
+func clipShape(ctx context.Context, img *Image, shape string, args ...string) (err error) {
+	shapeImg, err := newShapeImage(img.Width(), img.PageHeight(), shape)
+	if err != nil {
+		return
+	}
+	contextDefer(ctx, shapeImg.Close)
+	if err = replicatePages(shapeImg, img); err != nil {
+		return
+	}
+	if err = img.Composite(shapeImg, BlendModeDestIn, 0, 0); err != nil {
+		return
+	}
+	if len(args) > 0 && args[0] != "" {
+		return img.Flatten(getColor(img, args[0]))
+	}
+	return
+}
+
+func circle(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	w, h := float64(img.Width()), float64(img.PageHeight())
+	return clipShape(ctx, img, fmt.Sprintf(
+		`<circle cx="%g" cy="%g" r="%g" fill="#fff"/>`, w/2, h/2, min(w, h)/2,
+	), args...)
+}
+
+func ellipse(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	w, h := float64(img.Width()), float64(img.PageHeight())
+	return clipShape(ctx, img, fmt.Sprintf(
+		`<ellipse cx="%g" cy="%g" rx="%g" ry="%g" fill="#fff"/>`, w/2, h/2, w/2, h/2,
+	), args...)
+}
+
+func polygon(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	points, ok := parsePolygon(args[0], float64(img.Width()), float64(img.PageHeight()))
+	if !ok {
+		return
+	}
+	return clipShape(ctx, img, fmt.Sprintf(
+		`<polygon points="%s" fill="#fff"/>`, points,
+	), args[1:]...)
+}
+
+// parsePolygon parses points AxB:CxD:ExF to SVG polygon points,
+// fraction of width x height if all values are between 0 and 1
+func parsePolygon(arg string, width, height float64) (string, bool) {
+	values := strings.FieldsFunc(arg, argSplit)
+	if len(values) < 6 || len(values)%2 != 0 || len(values) > shapeMaxPoints*2 {
+		return "", false
+	}
+	var nums = make([]float64, len(values))
+	var fraction = true
+	for i, value := range values {
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", false
+		}
+		nums[i] = n
+		fraction = fraction && n >= 0 && n <= 1
+	}
+	var points []string
+	for i := 0; i < len(nums); i += 2 {
+		x, y := nums[i], nums[i+1]
+		if fraction {
+			x, y = x*width, y*height
+		}
+		points = append(points, fmt.Sprintf("%g,%g", x, y))
+	}
+	return strings.Join(points, " "), true
+}
+
+func (v *Processor) mask(ctx context.Context, img *Image, load imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 || args[0] == "" {
+		return
+	}
+	image := args[0]
+	if unescape, e := url.QueryUnescape(args[0]); e == nil {
+		image = unescape
+	}
+	var mode string
+	if len(args) > 1 {
+		mode = strings.TrimSpace(args[1])
+	}
+	blob, err := load(image)
+	if err != nil {
+		return
+	}
+	mask, err := v.NewThumbnail(
+		ctx, blob, img.Width(), img.PageHeight(), InterestingNone, SizeForce, 1, 1, 0)
+	if err != nil {
+		return
+	}
+	contextDefer(ctx, mask.Close)
+	// alpha of the mask if available, luminance otherwise
+	if mode == "luminance" || (mode != "alpha" && !mask.HasAlpha()) {
+		if err = mask.LuminanceMask(); err != nil {
+			return
+		}
+	}
+	if err = replicatePages(mask, img); err != nil {
+		return
+	}
+	return img.Composite(mask, BlendModeDestIn, 0, 0)
+}
+
+// borderColor border color defaults to black
+func borderColor(img *Image, args []string, i int) *Color {
+	if len(args) > i && args[i] != "" {
+		return getColor(img, args[i])
+	}
+	return &Color{}
+}
+
+func (v *Processor) border(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	width, _ := strconv.Atoi(strings.TrimSpace(args[0]))
+	if width <= 0 {
+		return
+	}
+	c := borderColor(img, args, 1)
+	var radius int
+	if len(args) > 2 {
+		radius, _ = strconv.Atoi(strings.TrimSpace(args[2]))
+	}
+	w, h := img.Width()+width*2, img.PageHeight()+width*2
+	if err = v.checkDimensions(float64(w), float64(h)); err != nil {
+		return
+	}
+	if img.Bands() < 3 {
+		if err = img.ToColorSpace(InterpretationSRGB); err != nil {
+			return
+		}
+	}
+	if radius <= 0 {
+		return img.EmbedBackgroundRGBA(width, width, w, h, &ColorRGBA{R: c.R, G: c.G, B: c.B, A: 255})
+	}
+	// inner corners follow the outer radius
+	if inner := radius - width; inner > 0 {
+		if err = clipShape(ctx, img, fmt.Sprintf(
+			`<rect x="0" y="0" width="%d" height="%d" rx="%d" fill="#fff"/>`,
+			img.Width(), img.PageHeight(), inner,
+		)); err != nil {
+			return
+		}
+	}
+	if err = img.AddAlpha(); err != nil {
+		return
+	}
+	if err = img.EmbedBackgroundRGBA(width, width, w, h, &ColorRGBA{}); err != nil {
+		return
+	}
+	rect, err := newRectImage(w, h, radius, c, 1)
+	if err != nil {
+		return
+	}
+	contextDefer(ctx, rect.Close)
+	if err = replicatePages(rect, img); err != nil {
+		return
+	}
+	return img.Composite(rect, BlendModeDestOver, 0, 0)
+}
+
+func frame(ctx context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	width, _ := strconv.Atoi(strings.TrimSpace(args[0]))
+	w, h := img.Width(), img.PageHeight()
+	if width <= 0 || width*2 >= min(w, h) {
+		return
+	}
+	c := borderColor(img, args, 1)
+	var radius int
+	if len(args) > 2 {
+		radius, _ = strconv.Atoi(strings.TrimSpace(args[2]))
+	}
+	if radius > 0 {
+		if err = clipShape(ctx, img, fmt.Sprintf(
+			`<rect x="0" y="0" width="%d" height="%d" rx="%d" fill="#fff"/>`, w, h, radius,
+		)); err != nil {
+			return
+		}
+	}
+	stroke, err := newShapeImage(w, h, fmt.Sprintf(
+		`<rect x="%g" y="%g" width="%d" height="%d" rx="%d" fill="none" stroke="#%02x%02x%02x" stroke-width="%d"/>`,
+		float64(width)/2, float64(width)/2, w-width, h-width, max(radius-width/2, 0), c.R, c.G, c.B, width,
+	))
+	if err != nil {
+		return
+	}
+	contextDefer(ctx, stroke.Close)
+	if err = replicatePages(stroke, img); err != nil {
+		return
+	}
+	return img.Composite(stroke, BlendModeOver, 0, 0)
+}
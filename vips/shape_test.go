@@ -0,0 +1,24 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePolygon(t *testing.T) {
+	points, ok := parsePolygon("10x0:100x50:0x100", 200, 100)
+	assert.True(t, ok)
+	assert.Equal(t, "10,0 100,50 0,100", points)
+
+	points, ok = parsePolygon("0.5x0:1x1:0x1", 200, 100)
+	assert.True(t, ok)
+	assert.Equal(t, "100,0 200,100 0,100", points)
+
+	_, ok = parsePolygon("0x0:100x100", 200, 100)
+	assert.False(t, ok)
+	_, ok = parsePolygon("0x0:100x100:50", 200, 100)
+	assert.False(t, ok)
+	_, ok = parsePolygon("0x0:100x100:ax1", 200, 100)
+	assert.False(t, ok)
+}
@@ -0,0 +1,65 @@
+package vips
+
+import "math"
+
+const (
+	ssimWindow = 8
+	ssimStride = 4
+	ssimC1     = (0.01 * 255) * (0.01 * 255)
+	ssimC2     = (0.03 * 255) * (0.03 * 255)
+)
+
+// SSIM computes the mean structural similarity index of two 8-bit single band images
+// of the same dimensions, over 8x8 windows. Returns 1 for identical images
+func SSIM(a, b []byte, width, height int) float64 {
+	if width <= 0 || height <= 0 || len(a) < width*height || len(b) < width*height {
+		return 0
+	}
+	var (
+		ww    = min(ssimWindow, width)
+		wh    = min(ssimWindow, height)
+		total float64
+		count int
+	)
+	for y := 0; y+wh <= height; y += ssimStride {
+		for x := 0; x+ww <= width; x += ssimStride {
+			total += ssimWindowAt(a, b, width, x, y, ww, wh)
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// DSSIM structural dissimilarity 1/SSIM - 1. Returns 0 for identical images
+func DSSIM(a, b []byte, width, height int) float64 {
+	ssim := SSIM(a, b, width, height)
+	if ssim <= 0 {
+		return math.Inf(1)
+	}
+	return 1/ssim - 1
+}
+
+func ssimWindowAt(a, b []byte, stride, x, y, ww, wh int) float64 {
+	var sumA, sumB, sumAA, sumBB, sumAB float64
+	for j := y; j < y+wh; j++ {
+		row := j * stride
+		for i := x; i < x+ww; i++ {
+			pa, pb := float64(a[row+i]), float64(b[row+i])
+			sumA += pa
+			sumB += pb
+			sumAA += pa * pa
+			sumBB += pb * pb
+			sumAB += pa * pb
+		}
+	}
+	n := float64(ww * wh)
+	meanA, meanB := sumA/n, sumB/n
+	varA := sumAA/n - meanA*meanA
+	varB := sumBB/n - meanB*meanB
+	covAB := sumAB/n - meanA*meanB
+	return ((2*meanA*meanB + ssimC1) * (2*covAB + ssimC2)) /
+		((meanA*meanA + meanB*meanB + ssimC1) * (varA + varB + ssimC2))
+}
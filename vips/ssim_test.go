@@ -0,0 +1,49 @@
+package vips
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSIM(t *testing.T) {
+	w, h := 64, 48
+	a := make([]byte, w*h)
+	noise := make([]byte, w*h)
+	for i := range a {
+		a[i] = byte((i%w)*3 + i/w)
+		noise[i] = a[i]
+		if i%2 == 0 && noise[i] < 235 {
+			noise[i] += 20
+		}
+	}
+	assert.Equal(t, 1.0, SSIM(a, a, w, h))
+	assert.Equal(t, 0.0, DSSIM(a, a, w, h))
+	ssim := SSIM(a, noise, w, h)
+	assert.True(t, ssim > 0 && ssim < 1)
+	assert.InDelta(t, 1/ssim-1, DSSIM(a, noise, w, h), 1e-9)
+	assert.Equal(t, 1.0, SSIM(a[:12], a[:12], 4, 3), "smaller than window")
+	assert.Equal(t, 0.0, SSIM(a[:10], a[:10], 4, 3), "buffer too small")
+	assert.True(t, math.IsInf(DSSIM(nil, nil, 0, 0), 1))
+}
+
+func TestParseTargetQuality(t *testing.T) {
+	tests := []struct {
+		args     string
+		expected *targetQuality
+	}{
+		{"0.98", &targetQuality{Value: 0.98}},
+		{"ssim,0.95", &targetQuality{Value: 0.95}},
+		{"dssim, 0.01", &targetQuality{DSSIM: true, Value: 0.01}},
+		{"ssim,1.5", nil},
+		{"dssim,-1", nil},
+		{"psnr,40", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		target, ok := parseTargetQuality(tt.args)
+		assert.Equal(t, tt.expected, target, tt.args)
+		assert.Equal(t, tt.expected != nil, ok, tt.args)
+	}
+}
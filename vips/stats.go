@@ -0,0 +1,275 @@
+package vips
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// statsSampleSize max dimension of the thumbnail image statistics computed from
+	statsSampleSize = 200
+	// defaultPaletteSize default number of palette colors
+	defaultPaletteSize = 5
+	// maxPaletteSize max number of palette colors
+	maxPaletteSize = 16
+	// grayscaleTolerance max channel difference of a pixel considered gray
+	grayscaleTolerance = 12
+)
+
+// PaletteColor palette color with the fraction of pixels it represents
+type PaletteColor struct {
+	Color  string  `json:"color"`
+	Weight float64 `json:"weight"`
+}
+
+// Histogram per channel pixel counts of 256 bins
+type Histogram struct {
+	Red   []int `json:"red"`
+	Green []int `json:"green"`
+	Blue  []int `json:"blue"`
+	Alpha []int `json:"alpha,omitempty"`
+}
+
+// stats statistics selected by stats() filter for meta output
+type stats struct {
+	Dominant    bool
+	Palette     bool
+	Average     bool
+	Histogram   bool
+	Alpha       bool
+	Grayscale   bool
+	PaletteSize int
+}
+
+// parseStats parses stats filter args e.g. dominant,palette,8,average,histogram,alpha,grayscale.
+// Number sets the palette size. Empty args selects all except histogram
+func parseStats(args string) *stats {
+	s := &stats{PaletteSize: defaultPaletteSize}
+	var selected bool
+	for _, arg := range strings.Split(args, ",") {
+		arg = strings.TrimSpace(strings.ToLower(arg))
+		if n, err := strconv.Atoi(arg); err == nil {
+			s.PaletteSize = max(1, min(maxPaletteSize, n))
+			continue
+		}
+		switch arg {
+		case "dominant":
+			s.Dominant = true
+		case "palette":
+			s.Palette = true
+		case "average":
+			s.Average = true
+		case "histogram":
+			s.Histogram = true
+		case "alpha":
+			s.Alpha = true
+		case "grayscale":
+			s.Grayscale = true
+		default:
+			continue
+		}
+		selected = true
+	}
+	if !selected {
+		s.Dominant, s.Palette, s.Average, s.Alpha, s.Grayscale = true, true, true, true, true
+	}
+	return s
+}
+
+// Apply computes the selected statistics from a thumbnail sample of the image into meta
+func (s *stats) Apply(img *Image, meta *Metadata) error {
+	pix, width, height, bands, err := samplePixels(img, statsSampleSize)
+	if err != nil {
+		return err
+	}
+	s.apply(meta, pix, width*height, bands)
+	return nil
+}
+
+func (s *stats) apply(meta *Metadata, pix []byte, n, bands int) {
+	if s.Dominant || s.Palette {
+		palette := Palette(pix, n, bands, s.PaletteSize)
+		if s.Dominant && len(palette) > 0 {
+			meta.DominantColor = palette[0].Color
+		}
+		if s.Palette {
+			meta.Palette = palette
+		}
+	}
+	if s.Average {
+		meta.AverageColor = averageColor(pix, n, bands)
+	}
+	if s.Histogram {
+		meta.Histogram = histogram(pix, n, bands)
+	}
+	if s.Alpha {
+		hasAlpha := false
+		for i := 0; bands == 4 && i < n; i++ {
+			if pix[i*4+3] < 255 {
+				hasAlpha = true
+				break
+			}
+		}
+		meta.HasAlpha = &hasAlpha
+	}
+	if s.Grayscale {
+		grayscale := isGrayscale(pix, n, bands)
+		meta.Grayscale = &grayscale
+	}
+}
+
+// Palette quantizes 8-bit sRGB pixels with 3 or 4 bands into up to size colors
+// using median cut, ordered by the fraction of pixels they represent.
+// Pixels mostly transparent are excluded
+func Palette(pix []byte, n, bands, size int) []PaletteColor {
+	var colors [][3]byte
+	for i := 0; i < n; i++ {
+		k := i * bands
+		if bands == 4 && pix[k+3] < 128 {
+			continue
+		}
+		colors = append(colors, [3]byte{pix[k], pix[k+1], pix[k+2]})
+	}
+	if len(colors) == 0 {
+		return nil
+	}
+	boxes := []colorBox{newColorBox(colors)}
+	for len(boxes) < size {
+		// split the box of the largest volume weighted by pixel count
+		var idx = -1
+		var score float64
+		for i, b := range boxes {
+			if b.Range == 0 {
+				continue
+			}
+			if s := float64(b.Range) * float64(len(b.Colors)); s > score {
+				idx, score = i, s
+			}
+		}
+		if idx < 0 {
+			break
+		}
+		a, b := boxes[idx].Split()
+		boxes[idx] = a
+		boxes = append(boxes, b)
+	}
+	sort.SliceStable(boxes, func(i, j int) bool {
+		return len(boxes[i].Colors) > len(boxes[j].Colors)
+	})
+	palette := make([]PaletteColor, len(boxes))
+	for i, b := range boxes {
+		var r, g, bl int
+		for _, c := range b.Colors {
+			r += int(c[0])
+			g += int(c[1])
+			bl += int(c[2])
+		}
+		l := len(b.Colors)
+		palette[i] = PaletteColor{
+			Color:  hexColor(float64(r)/float64(l), float64(g)/float64(l), float64(bl)/float64(l)),
+			Weight: math.Round(float64(l)/float64(len(colors))*10000) / 10000,
+		}
+	}
+	return palette
+}
+
+type colorBox struct {
+	Colors  [][3]byte
+	Channel int
+	Range   int
+}
+
+func newColorBox(colors [][3]byte) colorBox {
+	b := colorBox{Colors: colors}
+	for c := 0; c < 3; c++ {
+		lo, hi := 255, 0
+		for _, px := range colors {
+			lo = min(lo, int(px[c]))
+			hi = max(hi, int(px[c]))
+		}
+		if hi-lo > b.Range || c == 0 {
+			b.Channel, b.Range = c, hi-lo
+		}
+	}
+	return b
+}
+
+// Split splits box at the median of its widest channel
+func (b colorBox) Split() (colorBox, colorBox) {
+	sort.Slice(b.Colors, func(i, j int) bool {
+		return b.Colors[i][b.Channel] < b.Colors[j][b.Channel]
+	})
+	mid := len(b.Colors) / 2
+	// keep the same values on one side
+	for mid > 0 && b.Colors[mid][b.Channel] == b.Colors[mid-1][b.Channel] {
+		mid--
+	}
+	if mid == 0 {
+		mid = len(b.Colors) / 2
+		for mid < len(b.Colors) && b.Colors[mid][b.Channel] == b.Colors[mid-1][b.Channel] {
+			mid++
+		}
+	}
+	return newColorBox(b.Colors[:mid]), newColorBox(b.Colors[mid:])
+}
+
+// averageColor alpha weighted average color
+func averageColor(pix []byte, n, bands int) string {
+	var r, g, b, total float64
+	for i := 0; i < n; i++ {
+		k := i * bands
+		alpha := 1.0
+		if bands == 4 {
+			alpha = float64(pix[k+3]) / 255
+		}
+		r += float64(pix[k]) * alpha
+		g += float64(pix[k+1]) * alpha
+		b += float64(pix[k+2]) * alpha
+		total += alpha
+	}
+	if total == 0 {
+		return ""
+	}
+	return hexColor(r/total, g/total, b/total)
+}
+
+func histogram(pix []byte, n, bands int) *Histogram {
+	h := &Histogram{Red: make([]int, 256), Green: make([]int, 256), Blue: make([]int, 256)}
+	if bands == 4 {
+		h.Alpha = make([]int, 256)
+	}
+	for i := 0; i < n; i++ {
+		k := i * bands
+		h.Red[pix[k]]++
+		h.Green[pix[k+1]]++
+		h.Blue[pix[k+2]]++
+		if bands == 4 {
+			h.Alpha[pix[k+3]]++
+		}
+	}
+	return h
+}
+
+// isGrayscale if no more than 1% of the visible pixels are colored beyond tolerance
+func isGrayscale(pix []byte, n, bands int) bool {
+	var colored, visible int
+	for i := 0; i < n; i++ {
+		k := i * bands
+		if bands == 4 && pix[k+3] == 0 {
+			continue
+		}
+		visible++
+		r, g, b := int(pix[k]), int(pix[k+1]), int(pix[k+2])
+		if max(r, g, b)-min(r, g, b) > grayscaleTolerance {
+			colored++
+		}
+	}
+	return colored*100 <= visible
+}
+
+func hexColor(r, g, b float64) string {
+	return fmt.Sprintf("#%02x%02x%02x", int(math.Round(r)), int(math.Round(g)), int(math.Round(b)))
+}
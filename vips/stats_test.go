@@ -0,0 +1,56 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStats(t *testing.T) {
+	assert.Equal(t, &stats{
+		Dominant: true, Palette: true, Average: true, Alpha: true, Grayscale: true,
+		PaletteSize: defaultPaletteSize,
+	}, parseStats(""))
+	assert.Equal(t, &stats{Palette: true, Histogram: true, PaletteSize: 8}, parseStats("palette,8,histogram"))
+	assert.Equal(t, &stats{Dominant: true, PaletteSize: maxPaletteSize}, parseStats("dominant,100"))
+}
+
+func TestStats(t *testing.T) {
+	// 3/4 red, 1/4 blue, bottom right pixel half transparent
+	pix := make([]byte, 0, 16*4)
+	for i := 0; i < 16; i++ {
+		if i < 12 {
+			pix = append(pix, 255, 0, 0, 255)
+		} else {
+			pix = append(pix, 0, 0, 255, 255)
+		}
+	}
+	pix[15*4+3] = 0
+	meta := &Metadata{}
+	(&stats{
+		Dominant: true, Palette: true, Average: true, Histogram: true, Alpha: true, Grayscale: true,
+		PaletteSize: 4,
+	}).apply(meta, pix, 16, 4)
+	assert.Equal(t, "#ff0000", meta.DominantColor)
+	assert.Equal(t, []PaletteColor{
+		{Color: "#ff0000", Weight: 0.8},
+		{Color: "#0000ff", Weight: 0.2},
+	}, meta.Palette)
+	assert.Equal(t, "#cc0033", meta.AverageColor)
+	require.NotNil(t, meta.Histogram)
+	assert.Equal(t, 12, meta.Histogram.Red[255])
+	assert.Equal(t, 4, meta.Histogram.Blue[255])
+	assert.Equal(t, 1, meta.Histogram.Alpha[0])
+	require.NotNil(t, meta.HasAlpha)
+	assert.True(t, *meta.HasAlpha)
+	require.NotNil(t, meta.Grayscale)
+	assert.False(t, *meta.Grayscale)
+
+	gray := []byte{0, 0, 0, 128, 130, 126, 255, 255, 255}
+	meta = &Metadata{}
+	(&stats{Alpha: true, Grayscale: true}).apply(meta, gray, 3, 3)
+	assert.False(t, *meta.HasAlpha)
+	assert.True(t, *meta.Grayscale)
+	assert.Empty(t, Palette([]byte{0, 0, 0, 0}, 1, 4, 5))
+}
@@ -0,0 +1,146 @@
+package vips
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xudaolong/imagor/imagorpath"
+	"go.uber.org/zap"
+)
+
+const (
+	targetQualityMin           = 10
+	targetQualityMax           = 95
+	maxTargetQualityIterations = 8
+)
+
+func newTargetQualityIterations() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "vips_target_quality_iterations",
+			Help:    "A histogram of encode iterations used by target_quality filter",
+			Buckets: []float64{1, 2, 3, 4, 5, 6, 7, 8},
+		},
+		[]string{"format"},
+	)
+}
+
+// targetQuality perceptual similarity target of target_quality(ssim|dssim,value) filter
+type targetQuality struct {
+	DSSIM bool
+	Value float64
+}
+
+// parseTargetQuality parses target_quality args e.g. ssim,0.98 dssim,0.01 or 0.98 defaults to ssim
+func parseTargetQuality(args string) (t *targetQuality, ok bool) {
+	metric, value, found := strings.Cut(args, ",")
+	if !found {
+		metric, value = "ssim", metric
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return nil, false
+	}
+	switch strings.TrimSpace(metric) {
+	case "ssim":
+		if v <= 0 || v > 1 {
+			return nil, false
+		}
+		return &targetQuality{Value: v}, true
+	case "dssim":
+		if v < 0 {
+			return nil, false
+		}
+		return &targetQuality{DSSIM: true, Value: v}, true
+	}
+	return nil, false
+}
+
+// Score returns the similarity score of out against ref and if it meets the target
+func (t *targetQuality) Score(ref, out []byte, width, height int) (float64, bool) {
+	if t.DSSIM {
+		score := DSSIM(ref, out, width, height)
+		return score, score <= t.Value
+	}
+	score := SSIM(ref, out, width, height)
+	return score, score >= t.Value
+}
+
+// isTargetQualitySupported lossy export formats with quality parameter
+func isTargetQualitySupported(format ImageType) bool {
+	switch format {
+	case ImageTypeJPEG, ImageTypeWEBP, ImageTypeAVIF, ImageTypeHEIF, ImageTypeJP2K:
+		return true
+	}
+	return false
+}
+
+// exportTargetQuality bisects the encoder quality for the lowest quality
+// that meets the perceptual similarity target against img.
+// Falls back to the max quality if the target is never met
+func (v *Processor) exportTargetQuality(
+	ctx context.Context, img *Image, format ImageType, target *targetQuality,
+	compression int, palette bool, bitdepth int, params *imagorpath.Filters,
+) (buf []byte, quality int, err error) {
+	ref, width, height, err := img.Luma()
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		lo         = targetQualityMin
+		hi         = targetQualityMax
+		iterations int
+	)
+	for lo <= hi && iterations < maxTargetQualityIterations {
+		if err = ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		q := (lo + hi) / 2
+		var b []byte
+		if b, err = v.export(img, format, compression, q, palette, bitdepth, false, params); err != nil {
+			return nil, 0, err
+		}
+		iterations++
+		score, ok := v.scoreTargetQuality(b, ref, width, height, target)
+		if v.Debug {
+			v.Logger.Debug("target-quality",
+				zap.String("format", ImageTypes[format]),
+				zap.Int("quality", q),
+				zap.Float64("score", score),
+				zap.Int("bytes", len(b)))
+		}
+		if ok {
+			buf, quality = b, q
+			hi = q - 1
+		} else {
+			lo = q + 1
+		}
+	}
+	if buf == nil {
+		if buf, err = v.export(img, format, compression, targetQualityMax, palette, bitdepth, false, params); err != nil {
+			return nil, 0, err
+		}
+		quality = targetQualityMax
+		iterations++
+	}
+	v.targetQualityIterations.WithLabelValues(ImageTypes[format]).Observe(float64(iterations))
+	return buf, quality, nil
+}
+
+// scoreTargetQuality decodes the encoded buf and scores against ref luma
+func (v *Processor) scoreTargetQuality(
+	buf, ref []byte, width, height int, target *targetQuality,
+) (float64, bool) {
+	img, err := LoadImageFromBuffer(buf, nil)
+	if err != nil {
+		return 0, false
+	}
+	defer img.Close()
+	out, w, h, err := img.Luma()
+	if err != nil || w != width || h != height {
+		return 0, false
+	}
+	return target.Score(ref, out, width, height)
+}
@@ -0,0 +1,480 @@
+package vips
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/xudaolong/imagor"
+)
+
+const (
+	// templateMaxLayers max number of layers of a template
+	templateMaxLayers = 50
+	// templateMaxSize max size of template file loaded from storages
+	templateMaxSize = 1 << 20
+)
+
+var templateVarRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+var blendModes = map[string]BlendMode{
+	"clear":       BlendModeClear,
+	"source":      BlendModeSource,
+	"over":        BlendModeOver,
+	"in":          BlendModeIn,
+	"out":         BlendModeOut,
+	"atop":        BlendModeAtop,
+	"dest":        BlendModeDest,
+	"dest_over":   BlendModeDestOver,
+	"dest_in":     BlendModeDestIn,
+	"dest_out":    BlendModeDestOut,
+	"dest_atop":   BlendModeDestAtop,
+	"xor":         BlendModeXOR,
+	"add":         BlendModeAdd,
+	"saturate":    BlendModeSaturate,
+	"multiply":    BlendModeMultiply,
+	"screen":      BlendModeScreen,
+	"overlay":     BlendModeOverlay,
+	"darken":      BlendModeDarken,
+	"lighten":     BlendModeLighten,
+	"color_dodge": BlendModeColorDodge,
+	"color_burn":  BlendModeColorBurn,
+	"hard_light":  BlendModeHardLight,
+	"soft_light":  BlendModeSoftLight,
+	"difference":  BlendModeDifference,
+	"exclusion":   BlendModeExclusion,
+}
+
+// Template layered composition template of canvas, image, text and rect layers
+type Template struct {
+	Width      int               `json:"width"`
+	Height     int               `json:"height"`
+	Background string            `json:"background"`
+	Vars       map[string]string `json:"vars"`
+	Layers     []TemplateLayer   `json:"layers"`
+}
+
+// TemplateLayer image, text or rect layer of template
+type TemplateLayer struct {
+	Type    string   `json:"type"`
+	X       int      `json:"x"`
+	Y       int      `json:"y"`
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	Opacity *float64 `json:"opacity"`
+	Blend   string   `json:"blend"`
+	Color   string   `json:"color"`
+
+	// image layer, empty src for the source image
+	Src     string `json:"src"`
+	Fit     string `json:"fit"`
+	Gravity string `json:"gravity"`
+
+	// rect layer
+	Radius int `json:"radius"`
+
+	// text layer
+	Text        string  `json:"text"`
+	Font        string  `json:"font"`
+	FontFile    string  `json:"font_file"`
+	Size        int     `json:"size"`
+	Align       string  `json:"align"`
+	Bold        bool    `json:"bold"`
+	Italic      bool    `json:"italic"`
+	Markup      bool    `json:"markup"`
+	Spacing     int     `json:"spacing"`
+	Stroke      int     `json:"stroke"`
+	StrokeColor string  `json:"stroke_color"`
+	Shadow      int     `json:"shadow"`
+	ShadowBlur  float64 `json:"shadow_blur"`
+	ShadowColor string  `json:"shadow_color"`
+	Background  string  `json:"background"`
+	Padding     int     `json:"padding"`
+}
+
+// ParseTemplate decodes template JSON and substitutes {{name}} variables,
+// with key=value args overriding the template vars
+func ParseTemplate(buf []byte, args ...string) (*Template, error) {
+	var t Template
+	if err := json.Unmarshal(buf, &t); err != nil {
+		return nil, err
+	}
+	if len(t.Layers) > templateMaxLayers {
+		return nil, fmt.Errorf("exceeded %d layers", templateMaxLayers)
+	}
+	vars := map[string]string{}
+	for key, value := range t.Vars {
+		vars[key] = value
+	}
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			if s, err := url.QueryUnescape(value); err == nil {
+				value = s
+			}
+			vars[strings.TrimSpace(key)] = value
+		}
+	}
+	expand := func(s string, escape bool) string {
+		return templateVarRegexp.ReplaceAllStringFunc(s, func(m string) string {
+			value := vars[templateVarRegexp.FindStringSubmatch(m)[1]]
+			if escape {
+				return html.EscapeString(value)
+			}
+			return value
+		})
+	}
+	t.Background = expand(t.Background, false)
+	for i := range t.Layers {
+		l := &t.Layers[i]
+		for _, s := range []*string{
+			&l.Src, &l.Color, &l.Font, &l.FontFile,
+			&l.StrokeColor, &l.ShadowColor, &l.Background,
+		} {
+			*s = expand(*s, false)
+		}
+		// vars substituted into markup as plain text
+		l.Text = expand(l.Text, l.Markup)
+	}
+	return &t, nil
+}
+
+func (v *Processor) template(ctx context.Context, img *Image, load imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	name := args[0]
+	if unescape, e := url.QueryUnescape(name); e == nil {
+		name = unescape
+	}
+	var blob *imagor.Blob
+	if blob, err = load(name); err != nil {
+		return
+	}
+	if blob.Size() > templateMaxSize {
+		return imagor.ErrMaxSizeExceeded
+	}
+	reader, _, err := blob.NewReader()
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	buf, err := io.ReadAll(io.LimitReader(reader, templateMaxSize+1))
+	if err != nil {
+		return
+	}
+	if len(buf) > templateMaxSize {
+		return imagor.ErrMaxSizeExceeded
+	}
+	t, err := ParseTemplate(buf, args[1:]...)
+	if err != nil {
+		return imagor.NewError("invalid template: "+err.Error(), http.StatusBadRequest)
+	}
+	// source image for image layers of empty src
+	source, err := img.Copy()
+	if err != nil {
+		return
+	}
+	contextDefer(ctx, source.Close)
+	if err = firstFrame(source); err != nil {
+		return
+	}
+	var canvas = img
+	if t.Width > 0 && t.Height > 0 {
+		if err = v.checkDimensions(float64(t.Width), float64(t.Height)); err != nil {
+			return
+		}
+		var opacity float64
+		if t.Background != "" && t.Background != "none" {
+			opacity = 1
+		}
+		if canvas, err = newRectImage(t.Width, t.Height, 0, getColor(nil, t.Background), opacity); err != nil {
+			return
+		}
+		contextDefer(ctx, canvas.Close)
+	} else if err = firstFrame(img); err != nil {
+		return
+	}
+	if err = prepareLayer(canvas); err != nil {
+		return
+	}
+	for _, l := range t.Layers {
+		switch l.Type {
+		case "image":
+			err = v.templateImage(ctx, canvas, source, load, l)
+		case "text":
+			err = v.templateText(canvas, load, l)
+		case "rect":
+			err = v.templateRect(ctx, canvas, l)
+		}
+		if err != nil {
+			return
+		}
+	}
+	if canvas != img {
+		out, err := vipsCopyImage(canvas.image)
+		if err != nil {
+			return err
+		}
+		img.setImage(out)
+	}
+	return
+}
+
+func (v *Processor) templateImage(
+	ctx context.Context, canvas, source *Image, load imagor.LoadFunc, l TemplateLayer,
+) (err error) {
+	if err = v.checkDimensions(float64(l.Width), float64(l.Height)); err != nil {
+		return
+	}
+	var layer *Image
+	if l.Src == "" {
+		layer, err = source.Copy()
+	} else {
+		var blob *imagor.Blob
+		if blob, err = load(l.Src); err != nil {
+			return
+		}
+		// shrink-on-load to the layer box, resized by fit after load
+		w, h := v.MaxWidth, v.MaxHeight
+		if header, e := blob.DecodeHeader(); e == nil && header != nil {
+			if size := templateLoadSize(header.Width, header.Height, l.Width, l.Height); size > 0 {
+				w, h = size, size
+			}
+		}
+		layer, err = v.NewThumbnail(ctx, blob, w, h, InterestingNone, SizeDown, 1, 1, 0)
+	}
+	if err != nil {
+		return
+	}
+	contextDefer(ctx, layer.Close)
+	dx, dy, err := fitImage(layer, l.Width, l.Height, l.Fit, l.Gravity)
+	if err != nil {
+		return
+	}
+	if err = prepareLayer(layer); err != nil {
+		return
+	}
+	if err = applyOpacity(layer, l.Opacity); err != nil {
+		return
+	}
+	w, h := l.Width, l.Height
+	if w <= 0 {
+		w = layer.Width()
+	}
+	if h <= 0 {
+		h = layer.PageHeight()
+	}
+	x, y := layerPosition(canvas, l.X, l.Y, w, h)
+	return canvas.Composite(layer, blendMode(l.Blend), x+dx, y+dy)
+}
+
+func (v *Processor) templateText(canvas *Image, load imagor.LoadFunc, l TemplateLayer) (err error) {
+	if l.Text == "" {
+		return
+	}
+	size := l.Size
+	if size <= 0 {
+		size = 20
+	}
+	font := l.Font
+	if font == "" {
+		font = defaultLabelFont
+	}
+	opts := &LabelOptions{
+		Text:          l.Text,
+		Font:          labelFont(font, l.Bold, l.Italic, size),
+		Size:          size,
+		Width:         max(0, min(l.Width, v.MaxWidth)),
+		Spacing:       max(0, min(l.Spacing, labelMaxSpacing)),
+		Color:         getColor(nil, l.Color),
+		Opacity:       1,
+		Stroke:        max(0, min(l.Stroke, labelMaxStroke)),
+		StrokeColor:   getColor(nil, l.StrokeColor),
+		ShadowX:       max(-labelMaxShadow, min(l.Shadow, labelMaxShadow)),
+		ShadowY:       max(-labelMaxShadow, min(l.Shadow, labelMaxShadow)),
+		ShadowBlur:    max(0, min(l.ShadowBlur, labelMaxStroke)),
+		ShadowColor:   getColor(nil, l.ShadowColor),
+		ShadowOpacity: 1 - labelDefaultShadowAlpha/100.0,
+		Padding:       max(0, min(l.Padding, labelMaxPadding)),
+	}
+	if l.Opacity != nil {
+		opts.Opacity = max(0, min(*l.Opacity, 1))
+	}
+	if l.Background != "" {
+		opts.Background = getColor(nil, l.Background)
+	}
+	if !l.Markup {
+		opts.Text = html.EscapeString(opts.Text)
+	}
+	opts.X, opts.Y = layerPosition(canvas, l.X, l.Y, opts.Width, size)
+	switch l.Align {
+	case "center":
+		opts.Align = AlignCenter
+		opts.X += opts.Width / 2
+	case "right":
+		opts.Align = AlignHigh
+		opts.X += opts.Width
+	}
+	if l.X < 0 && opts.Width == 0 {
+		// anchored by the right edge without text box width
+		opts.Align = AlignHigh
+	}
+	if l.Y < 0 {
+		// anchored by the bottom edge as text height unknown before rendering
+		opts.VAlign = AlignHigh
+	}
+	if l.FontFile != "" {
+		if opts.FontFile, err = v.loadFontFile(load, l.FontFile); err != nil {
+			return
+		}
+	}
+	return canvas.RichLabel(opts)
+}
+
+func (v *Processor) templateRect(ctx context.Context, canvas *Image, l TemplateLayer) (err error) {
+	if l.Width <= 0 || l.Height <= 0 {
+		return
+	}
+	if err = v.checkDimensions(float64(l.Width), float64(l.Height)); err != nil {
+		return
+	}
+	opacity := 1.0
+	if l.Opacity != nil {
+		opacity = max(0, min(*l.Opacity, 1))
+	}
+	rect, err := newRectImage(l.Width, l.Height, l.Radius, getColor(nil, l.Color), opacity)
+	if err != nil {
+		return
+	}
+	contextDefer(ctx, rect.Close)
+	x, y := layerPosition(canvas, l.X, l.Y, l.Width, l.Height)
+	return canvas.Composite(rect, blendMode(l.Blend), x, y)
+}
+
+// templateLoadSize square load box of image by header dimensions to cover
+// the layer box in either orientation. Returns 0 if not shrinking
+func templateLoadSize(imageWidth, imageHeight, width, height int) int {
+	if imageWidth <= 0 || imageHeight <= 0 {
+		return 0
+	}
+	iw, ih := float64(imageWidth), float64(imageHeight)
+	w, h := float64(width), float64(height)
+	scale := max(w/iw, h/ih, w/ih, h/iw)
+	if scale <= 0 || scale >= 1 {
+		return 0
+	}
+	return int(math.Ceil(max(iw, ih) * scale))
+}
+
+// newRectImage rectangle of color with rounded corners of radius by SVG
+func newRectImage(width, height, radius int, c *Color, opacity float64) (*Image, error) {
+	return LoadImageFromBuffer([]byte(fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`+
+			`<rect x="0" y="0" width="%d" height="%d" rx="%d" fill="#%02x%02x%02x" fill-opacity="%g"/></svg>`,
+		width, height, width, height, max(radius, 0), c.R, c.G, c.B, opacity,
+	)), nil)
+}
+
+// fitImage resizes image to width x height by fit cover, contain or fill.
+// Returns offset of the image within the box by gravity for contain
+func fitImage(img *Image, width, height int, fit, gravity string) (dx, dy int, err error) {
+	if width <= 0 && height <= 0 {
+		return
+	}
+	iw, ih := float64(img.Width()), float64(img.PageHeight())
+	if width <= 0 || height <= 0 {
+		// scale by the given dimension
+		scale := float64(width) / iw
+		if width <= 0 {
+			scale = float64(height) / ih
+		}
+		err = img.ThumbnailWithSize(
+			max(1, int(math.Round(iw*scale))), max(1, int(math.Round(ih*scale))), InterestingNone, SizeForce)
+		return
+	}
+	gx, gy := parseGravity(gravity)
+	switch fit {
+	case "fill":
+		err = img.ThumbnailWithSize(width, height, InterestingNone, SizeForce)
+	case "contain":
+		if err = img.ThumbnailWithSize(width, height, InterestingNone, SizeBoth); err != nil {
+			return
+		}
+		dx = int(gx * float64(width-img.Width()))
+		dy = int(gy * float64(height-img.PageHeight()))
+	default:
+		if gravity == "smart" {
+			return 0, 0, img.ThumbnailWithSize(width, height, InterestingAttention, SizeBoth)
+		}
+		scale := math.Max(float64(width)/iw, float64(height)/ih)
+		w := max(width, int(math.Ceil(iw*scale)))
+		h := max(height, int(math.Ceil(ih*scale)))
+		if err = img.ThumbnailWithSize(w, h, InterestingNone, SizeForce); err != nil {
+			return
+		}
+		err = img.ExtractArea(int(gx*float64(w-width)), int(gy*float64(h-height)), width, height)
+	}
+	return
+}
+
+// parseGravity horizontal and vertical position fractions of gravity,
+// e.g. north, south_east, defaults to center
+func parseGravity(gravity string) (float64, float64) {
+	gx, gy := 0.5, 0.5
+	gravity = strings.ReplaceAll(gravity, "_", "")
+	if strings.HasPrefix(gravity, "north") {
+		gy = 0
+	} else if strings.HasPrefix(gravity, "south") {
+		gy = 1
+	}
+	if strings.HasSuffix(gravity, "west") {
+		gx = 0
+	} else if strings.HasSuffix(gravity, "east") {
+		gx = 1
+	}
+	return gx, gy
+}
+
+// layerPosition negative position from the right and bottom of the canvas
+func layerPosition(canvas *Image, x, y, w, h int) (int, int) {
+	if x < 0 {
+		x += canvas.Width() - w
+	}
+	if y < 0 {
+		y += canvas.PageHeight() - h
+	}
+	return x, y
+}
+
+// prepareLayer converts image to sRGB with alpha for compositing
+func prepareLayer(img *Image) (err error) {
+	if img.Bands() < 3 {
+		if err = img.ToColorSpace(InterpretationSRGB); err != nil {
+			return
+		}
+	}
+	return img.AddAlpha()
+}
+
+func applyOpacity(img *Image, opacity *float64) error {
+	if opacity == nil || *opacity >= 1 {
+		return nil
+	}
+	return img.Linear([]float64{1, 1, 1, max(*opacity, 0)}, []float64{0, 0, 0, 0})
+}
+
+func blendMode(name string) BlendMode {
+	if mode, ok := blendModes[name]; ok {
+		return mode
+	}
+	return BlendModeOver
+}
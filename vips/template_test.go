@@ -0,0 +1,68 @@
+package vips
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tpl, err := ParseTemplate([]byte(`{
+		"width": 1200, "height": 630, "background": "{{bg}}",
+		"vars": {"bg": "white", "title": "Default"},
+		"layers": [
+			{"type": "image", "src": "photos/{{id}}.jpg", "fit": "cover", "gravity": "north", "blend": "multiply"},
+			{"type": "rect", "x": 0, "y": -1, "width": 1200, "height": 120, "color": "black", "opacity": 0.5},
+			{"type": "text", "text": "{{ title }} {{missing}}", "size": 48, "color": "white"}
+		]
+	}`), "title=Hello%20World", "id=42", "invalid")
+	require.NoError(t, err)
+	assert.Equal(t, 1200, tpl.Width)
+	assert.Equal(t, "white", tpl.Background)
+	require.Len(t, tpl.Layers, 3)
+	assert.Equal(t, "photos/42.jpg", tpl.Layers[0].Src)
+	assert.Equal(t, BlendModeMultiply, blendMode(tpl.Layers[0].Blend))
+	assert.Equal(t, 0.5, *tpl.Layers[1].Opacity)
+	assert.Equal(t, "Hello World ", tpl.Layers[2].Text)
+	assert.Equal(t, BlendModeOver, blendMode(tpl.Layers[2].Blend))
+
+	// vars escaped in markup text
+	tpl, err = ParseTemplate([]byte(`{"layers": [
+		{"type": "text", "text": "<b>{{title}}</b>", "markup": true},
+		{"type": "text", "text": "<b>{{title}}</b>"}
+	]}`), "title=%3Ci%3EA%20%26%20B")
+	require.NoError(t, err)
+	assert.Equal(t, "<b>&lt;i&gt;A &amp; B</b>", tpl.Layers[0].Text)
+	assert.Equal(t, "<b><i>A & B</b>", tpl.Layers[1].Text)
+
+	_, err = ParseTemplate([]byte(`{"layers": [`))
+	assert.Error(t, err)
+	_, err = ParseTemplate([]byte(`{"layers": [` + strings.Repeat(`{},`, templateMaxLayers) + `{}]}`))
+	assert.Error(t, err)
+}
+
+func TestParseGravity(t *testing.T) {
+	for gravity, expected := range map[string][2]float64{
+		"":           {0.5, 0.5},
+		"center":     {0.5, 0.5},
+		"north":      {0.5, 0},
+		"south_east": {1, 1},
+		"northwest":  {0, 0},
+		"west":       {0, 0.5},
+	} {
+		gx, gy := parseGravity(gravity)
+		assert.Equal(t, expected, [2]float64{gx, gy}, gravity)
+	}
+}
+
+func TestTemplateLoadSize(t *testing.T) {
+	// cover 300x300 of 2000x1000 in either orientation
+	assert.Equal(t, 600, templateLoadSize(2000, 1000, 300, 300))
+	assert.Equal(t, 600, templateLoadSize(1000, 2000, 300, 300))
+	assert.Equal(t, 200, templateLoadSize(2000, 1000, 100, 0))
+	assert.Equal(t, 0, templateLoadSize(2000, 1000, 0, 0))
+	assert.Equal(t, 0, templateLoadSize(200, 100, 300, 300))
+	assert.Equal(t, 0, templateLoadSize(0, 0, 300, 300))
+}
@@ -0,0 +1,296 @@
+package vips
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xudaolong/imagor"
+)
+
+// toneCurve maps channel value from 0 to 1 of band 0 red, 1 green or 2 blue
+type toneCurve func(band int, v float64) float64
+
+// lumaMatrix recombines RGB to Rec. 601 luma on all bands
+var lumaMatrix = [9]float64{
+	0.299, 0.587, 0.114,
+	0.299, 0.587, 0.114,
+	0.299, 0.587, 0.114,
+}
+
+// sepiaMatrix recombines RGB to sepia tone
+var sepiaMatrix = [9]float64{
+	0.393, 0.769, 0.189,
+	0.349, 0.686, 0.168,
+	0.272, 0.534, 0.131,
+}
+
+// prepareTone converts image to sRGB preserving 16-bit depth and alpha
+func prepareTone(img *Image) error {
+	if it := img.Interpretation(); it != InterpretationSRGB && it != InterpretationRGB16 {
+		return img.ToColorSpace(rgbInterpretation(img))
+	}
+	return nil
+}
+
+// applyTone maps the RGB bands of image by tone curve via lookup table
+func applyTone(img *Image, curve toneCurve) error {
+	if err := prepareTone(img); err != nil {
+		return err
+	}
+	size := 256
+	if img.Is16Bit() {
+		size = 65536
+	}
+	return img.MapLUT(toneLUT(size, curve))
+}
+
+// toneLUT lookup table of size entries of interleaved RGB
+func toneLUT(size int, curve toneCurve) []float64 {
+	m := float64(size - 1)
+	lut := make([]float64, size*3)
+	for i := 0; i < size; i++ {
+		for band := 0; band < 3; band++ {
+			v := curve(band, float64(i)/m)
+			lut[i*3+band] = math.Round(max(0, min(v, 1)) * m)
+		}
+	}
+	return lut
+}
+
+// recombTone recombines the RGB bands of image by matrix blended with identity by amount
+func recombTone(img *Image, matrix [9]float64, amount float64) error {
+	if err := prepareTone(img); err != nil {
+		return err
+	}
+	amount = max(0, min(amount, 1))
+	for i := range matrix {
+		identity := 0.0
+		if i%4 == 0 {
+			identity = 1
+		}
+		matrix[i] = identity + (matrix[i]-identity)*amount
+	}
+	return img.Recomb(matrix)
+}
+
+// colorValues RGB of color from 0 to 1
+func colorValues(c *Color) [3]float64 {
+	return [3]float64{float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255}
+}
+
+func gamma(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	g, _ := strconv.ParseFloat(args[0], 64)
+	if g <= 0 || g == 1 {
+		return
+	}
+	return applyTone(img, func(_ int, v float64) float64 {
+		return math.Pow(v, 1/g)
+	})
+}
+
+func levels(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	var black, white, g = 0.0, 255.0, 1.0
+	if len(args) > 0 && args[0] != "" {
+		black, _ = strconv.ParseFloat(args[0], 64)
+	}
+	if len(args) > 1 && args[1] != "" {
+		white, _ = strconv.ParseFloat(args[1], 64)
+	}
+	if len(args) > 2 && args[2] != "" {
+		g, _ = strconv.ParseFloat(args[2], 64)
+	}
+	black, white = max(0, min(black, 255)), max(0, min(white, 255))
+	if white <= black || g <= 0 || (black == 0 && white == 255 && g == 1) {
+		return
+	}
+	return applyTone(img, func(_ int, v float64) float64 {
+		v = max(0, min((v*255-black)/(white-black), 1))
+		return math.Pow(v, 1/g)
+	})
+}
+
+func curves(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	// master curve of all channels, followed by red, green and blue curves
+	var fns [4]func(float64) float64
+	var ok bool
+	for i := 0; i < len(args) && i < 4; i++ {
+		if points := parseCurvePoints(args[i]); len(points) > 0 {
+			fns[i] = monotoneCurve(points)
+			ok = true
+		}
+	}
+	if !ok {
+		return
+	}
+	return applyTone(img, func(band int, v float64) float64 {
+		if fns[0] != nil {
+			v = fns[0](v)
+		}
+		if fn := fns[band+1]; fn != nil {
+			v = fn(v)
+		}
+		return v
+	})
+}
+
+// parseCurvePoints parses curve points AxB:CxD... of values 0 to 255 normalized to 0 to 1
+func parseCurvePoints(arg string) (points [][2]float64) {
+	values := strings.FieldsFunc(arg, argSplit)
+	if len(values)%2 != 0 || len(values) > 2*256 {
+		return nil
+	}
+	for i := 0; i < len(values); i += 2 {
+		x, e1 := strconv.ParseFloat(strings.TrimSpace(values[i]), 64)
+		y, e2 := strconv.ParseFloat(strings.TrimSpace(values[i+1]), 64)
+		if e1 != nil || e2 != nil {
+			return nil
+		}
+		points = append(points, [2]float64{max(0, min(x, 255)) / 255, max(0, min(y, 255)) / 255})
+	}
+	return
+}
+
+// monotoneCurve monotone cubic interpolation through points,
+// constant beyond the first and last points
+func monotoneCurve(points [][2]float64) func(float64) float64 {
+	sort.Slice(points, func(i, j int) bool {
+		return points[i][0] < points[j][0]
+	})
+	// drop duplicated x
+	var p [][2]float64
+	for i, pt := range points {
+		if i == 0 || pt[0] > p[len(p)-1][0] {
+			p = append(p, pt)
+		}
+	}
+	n := len(p)
+	if n == 1 {
+		y := p[0][1]
+		return func(float64) float64 { return y }
+	}
+	// Fritsch-Carlson tangents
+	d := make([]float64, n-1)
+	for i := 0; i < n-1; i++ {
+		d[i] = (p[i+1][1] - p[i][1]) / (p[i+1][0] - p[i][0])
+	}
+	m := make([]float64, n)
+	m[0], m[n-1] = d[0], d[n-2]
+	for i := 1; i < n-1; i++ {
+		if d[i-1]*d[i] <= 0 {
+			m[i] = 0
+		} else {
+			m[i] = (d[i-1] + d[i]) / 2
+		}
+	}
+	for i := 0; i < n-1; i++ {
+		if d[i] == 0 {
+			m[i], m[i+1] = 0, 0
+			continue
+		}
+		a, b := m[i]/d[i], m[i+1]/d[i]
+		if s := a*a + b*b; s > 9 {
+			t := 3 / math.Sqrt(s)
+			m[i], m[i+1] = t*a*d[i], t*b*d[i]
+		}
+	}
+	return func(x float64) float64 {
+		if x <= p[0][0] {
+			return p[0][1]
+		}
+		if x >= p[n-1][0] {
+			return p[n-1][1]
+		}
+		i := sort.Search(n, func(i int) bool { return p[i][0] > x }) - 1
+		h := p[i+1][0] - p[i][0]
+		t := (x - p[i][0]) / h
+		t2, t3 := t*t, t*t*t
+		return (2*t3-3*t2+1)*p[i][1] + (t3-2*t2+t)*h*m[i] +
+			(-2*t3+3*t2)*p[i+1][1] + (t3-t2)*h*m[i+1]
+	}
+}
+
+func tint(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 || args[0] == "" {
+		return
+	}
+	c := colorValues(getColor(img, args[0]))
+	amount := 50.0
+	if len(args) > 1 {
+		amount, _ = strconv.ParseFloat(args[1], 64)
+	}
+	a := max(0, min(amount, 100)) / 100
+	return applyTone(img, func(band int, v float64) float64 {
+		return v * (1 - a + a*c[band])
+	})
+}
+
+func sepia(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	amount := 100.0
+	if len(args) > 0 && args[0] != "" {
+		amount, _ = strconv.ParseFloat(args[0], 64)
+	}
+	return recombTone(img, sepiaMatrix, amount/100)
+}
+
+func duotone(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) < 2 {
+		return
+	}
+	shadow, highlight := colorValues(getColor(img, args[0])), colorValues(getColor(img, args[1]))
+	if err = recombTone(img, lumaMatrix, 1); err != nil {
+		return
+	}
+	return applyTone(img, func(band int, v float64) float64 {
+		return shadow[band] + (highlight[band]-shadow[band])*v
+	})
+}
+
+func invert(_ context.Context, img *Image, _ imagor.LoadFunc, _ ...string) (err error) {
+	return applyTone(img, func(_ int, v float64) float64 {
+		return 1 - v
+	})
+}
+
+func posterize(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	n, _ := strconv.Atoi(args[0])
+	if n < 2 || n > 255 {
+		return
+	}
+	steps := float64(n - 1)
+	return applyTone(img, func(_ int, v float64) float64 {
+		return math.Round(v*steps) / steps
+	})
+}
+
+func threshold(_ context.Context, img *Image, _ imagor.LoadFunc, args ...string) (err error) {
+	t := 128.0
+	if len(args) > 0 && args[0] != "" {
+		t, _ = strconv.ParseFloat(args[0], 64)
+	}
+	t = max(0, min(t, 255)) / 255
+	if err = recombTone(img, lumaMatrix, 1); err != nil {
+		return
+	}
+	return applyTone(img, func(_ int, v float64) float64 {
+		if v >= t {
+			return 1
+		}
+		return 0
+	})
+}
+
+func equalize(_ context.Context, img *Image, _ imagor.LoadFunc, _ ...string) (err error) {
+	if err = prepareTone(img); err != nil {
+		return
+	}
+	return img.Equalize()
+}
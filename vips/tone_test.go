@@ -0,0 +1,54 @@
+package vips
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToneLUT(t *testing.T) {
+	lut := toneLUT(256, func(band int, v float64) float64 {
+		return 1 - v
+	})
+	assert.Len(t, lut, 256*3)
+	assert.Equal(t, []float64{255, 255, 255}, lut[:3])
+	assert.Equal(t, []float64{0, 0, 0}, lut[255*3:])
+
+	lut = toneLUT(65536, func(band int, v float64) float64 {
+		return v * 2
+	})
+	assert.Equal(t, 65535.0, lut[65535*3])
+	assert.Equal(t, 2.0, lut[3])
+}
+
+func TestParseCurvePoints(t *testing.T) {
+	assert.Equal(t, [][2]float64{{0, 0}, {1, 1}}, parseCurvePoints("0x0:255x300"))
+	assert.Nil(t, parseCurvePoints("0x0:255"))
+	assert.Nil(t, parseCurvePoints("0xa"))
+	assert.Nil(t, parseCurvePoints(""))
+}
+
+func TestMonotoneCurve(t *testing.T) {
+	fn := monotoneCurve([][2]float64{{1, 1}, {0, 0}, {0.5, 0.75}})
+	assert.Equal(t, 0.0, fn(0))
+	assert.InDelta(t, 0.75, fn(0.5), 1e-9)
+	assert.Equal(t, 1.0, fn(1))
+	prev := 0.0
+	for x := 0.0; x <= 1; x += 0.01 {
+		y := fn(x)
+		assert.GreaterOrEqual(t, y, prev-1e-9)
+		assert.LessOrEqual(t, y, 1.0)
+		prev = y
+	}
+
+	// constant beyond the first and last points
+	fn = monotoneCurve([][2]float64{{0.2, 0.1}, {0.8, 0.9}})
+	assert.Equal(t, 0.1, fn(0))
+	assert.Equal(t, 0.9, fn(1))
+	assert.InDelta(t, 0.5, fn(0.5), 1e-9)
+
+	fn = monotoneCurve([][2]float64{{0.5, 0.3}})
+	assert.Equal(t, 0.3, fn(0.9))
+	assert.False(t, math.IsNaN(fn(0)))
+}
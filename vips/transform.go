@@ -0,0 +1,151 @@
+package vips
+
+import (
+	"math"
+	"strings"
+)
+
+// defaultInterpolator interpolator of rotate, affine and perspective filters
+const defaultInterpolator = "bicubic"
+
+// interpolators libvips interpolator names
+var interpolators = map[string]bool{
+	"nearest":  true,
+	"bilinear": true,
+	"bicubic":  true,
+	"lbb":      true,
+	"nohalo":   true,
+	"vsqbs":    true,
+}
+
+// transformOptions background, interpolator and crop args of rotate, affine and perspective filters
+type transformOptions struct {
+	Background   *ColorRGBA
+	Transparent  bool
+	Interpolator string
+	Crop         bool
+}
+
+// parseTransformOptions parses args in any order: interpolator name, crop,
+// or background color, none or transparent.
+// Background defaults to transparent if image has alpha, black otherwise
+func parseTransformOptions(img *Image, args ...string) *transformOptions {
+	o := &transformOptions{Interpolator: defaultInterpolator}
+	var background string
+	for _, arg := range args {
+		arg = strings.ToLower(strings.TrimSpace(arg))
+		switch {
+		case arg == "":
+		case interpolators[arg]:
+			o.Interpolator = arg
+		case arg == "crop":
+			o.Crop = true
+		default:
+			background = arg
+		}
+	}
+	switch background {
+	case "none", "transparent":
+		o.Transparent = true
+	case "":
+		o.Transparent = img.HasAlpha()
+	}
+	if o.Transparent {
+		o.Background = &ColorRGBA{}
+	} else {
+		c := getColor(img, background)
+		o.Background = &ColorRGBA{R: c.R, G: c.G, B: c.B, A: 255}
+	}
+	return o
+}
+
+// prepare converts image to 3 or 4 bands, adding alpha for transparent background
+func (o *transformOptions) prepare(img *Image) (err error) {
+	if img.Bands() < 3 {
+		if err = img.ToColorSpace(rgbInterpretation(img)); err != nil {
+			return
+		}
+	}
+	if o.Transparent && !img.HasAlpha() {
+		return img.AddAlpha()
+	}
+	return
+}
+
+// inscribedRect largest axis aligned rectangle within w x h rectangle rotated by angle in degrees
+func inscribedRect(w, h int, angle float64) (int, int) {
+	if w <= 0 || h <= 0 {
+		return 0, 0
+	}
+	rad := angle * math.Pi / 180
+	sin, cos := math.Abs(math.Sin(rad)), math.Abs(math.Cos(rad))
+	long, short := float64(max(w, h)), float64(min(w, h))
+	var wr, hr float64
+	if short <= 2*sin*cos*long || math.Abs(sin-cos) < 1e-10 {
+		// half constrained, corners of the rectangle touch the longer sides
+		x := short / 2
+		if w >= h {
+			wr, hr = x/sin, x/cos
+		} else {
+			wr, hr = x/cos, x/sin
+		}
+	} else {
+		cos2 := cos*cos - sin*sin
+		wr = (float64(w)*cos - float64(h)*sin) / cos2
+		hr = (float64(h)*cos - float64(w)*sin) / cos2
+	}
+	return min(w, int(math.Floor(wr))), min(h, int(math.Floor(hr)))
+}
+
+// perspectiveSize output size of the quadrilateral top-left, top-right, bottom-right, bottom-left
+func perspectiveSize(quad [4][2]float64) (int, int) {
+	dist := func(a, b [2]float64) float64 {
+		return math.Hypot(a[0]-b[0], a[1]-b[1])
+	}
+	w := math.Max(dist(quad[0], quad[1]), dist(quad[3], quad[2]))
+	h := math.Max(dist(quad[0], quad[3]), dist(quad[1], quad[2]))
+	return int(math.Round(w)), int(math.Round(h))
+}
+
+// homography computes the 3x3 matrix mapping the corners of w x h rectangle
+// to the quadrilateral top-left, top-right, bottom-right, bottom-left.
+// Returns false if the quadrilateral is degenerate
+func homography(w, h float64, quad [4][2]float64) ([9]float64, bool) {
+	rect := [4][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}}
+	// x = (h0 u + h1 v + h2) / (h6 u + h7 v + 1), y = (h3 u + h4 v + h5) / (h6 u + h7 v + 1)
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		u, v := rect[i][0], rect[i][1]
+		x, y := quad[i][0], quad[i][1]
+		a[i*2] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		a[i*2+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+	// gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return [9]float64{}, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+	var m [9]float64
+	for i := 0; i < 8; i++ {
+		m[i] = a[i][8] / a[i][i]
+	}
+	m[8] = 1
+	return m, true
+}
@@ -0,0 +1,51 @@
+package vips
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInscribedRect(t *testing.T) {
+	w, h := inscribedRect(200, 100, 0)
+	assert.Equal(t, 200, w)
+	assert.Equal(t, 100, h)
+
+	w, h = inscribedRect(100, 100, 45)
+	assert.InDelta(t, 70, w, 1)
+	assert.InDelta(t, 70, h, 1)
+
+	w, h = inscribedRect(400, 100, 30)
+	assert.Greater(t, w, 0)
+	assert.Greater(t, h, 0)
+	// corners of the rotated crop stay within the original rectangle
+	rad := 30 * math.Pi / 180
+	x := float64(w)/2*math.Cos(rad) + float64(h)/2*math.Sin(rad)
+	y := float64(w)/2*math.Sin(rad) + float64(h)/2*math.Cos(rad)
+	assert.LessOrEqual(t, x, 200.0+1e-6)
+	assert.LessOrEqual(t, y, 50.0+1e-6)
+
+	w, h = inscribedRect(0, 100, 30)
+	assert.Zero(t, w)
+	assert.Zero(t, h)
+}
+
+func TestHomography(t *testing.T) {
+	quad := [4][2]float64{{10, 20}, {210, 5}, {230, 160}, {0, 140}}
+	w, h := perspectiveSize(quad)
+	assert.Equal(t, 231, w)
+	assert.Equal(t, 156, h)
+
+	m, ok := homography(float64(w), float64(h), quad)
+	assert.True(t, ok)
+	rect := [4][2]float64{{0, 0}, {float64(w), 0}, {float64(w), float64(h)}, {0, float64(h)}}
+	for i, p := range rect {
+		d := m[6]*p[0] + m[7]*p[1] + m[8]
+		assert.InDelta(t, quad[i][0], (m[0]*p[0]+m[1]*p[1]+m[2])/d, 1e-6)
+		assert.InDelta(t, quad[i][1], (m[3]*p[0]+m[4]*p[1]+m[5])/d, 1e-6)
+	}
+
+	_, ok = homography(100, 100, [4][2]float64{{0, 0}, {0, 0}, {0, 0}, {0, 0}})
+	assert.False(t, ok)
+}
@@ -119,6 +119,24 @@ type ColorRGBA struct {
 	R, G, B, A uint8
 }
 
+// LabelOptions represents text label rendering options
+type LabelOptions struct {
+	Text, Font, FontFile string
+	X, Y, Size           int
+	Align, VAlign        Align
+	Width, Spacing       int
+	Color                *Color
+	Opacity              float64
+	Stroke               int
+	StrokeColor          *Color
+	ShadowX, ShadowY     int
+	ShadowBlur           float64
+	ShadowColor          *Color
+	ShadowOpacity        float64
+	Background           *Color
+	Padding              int
+}
+
 // Interpretation represents VIPS_INTERPRETATION type
 type Interpretation int
 
@@ -146,6 +164,11 @@ const (
 	InterpretationHSV       Interpretation = C.VIPS_INTERPRETATION_HSV
 )
 
+// String returns the libvips nickname of the interpretation e.g. srgb, b-w, cmyk
+func (i Interpretation) String() string {
+	return C.GoString(C.interpretation_nick(C.VipsInterpretation(i)))
+}
+
 // Intent represents VIPS_INTENT type
 type Intent int
 
@@ -158,6 +181,13 @@ const (
 	IntentLast       Intent = C.VIPS_INTENT_LAST
 )
 
+var intentMap = map[string]Intent{
+	"perceptual": IntentPerceptual,
+	"relative":   IntentRelative,
+	"saturation": IntentSaturation,
+	"absolute":   IntentAbsolute,
+}
+
 // BlendMode gives the various Porter-Duff and PDF blend modes.
 // See https://libvips.github.io/libvips/API/current/libvips-conversion.html#VipsBlendMode
 type BlendMode int
@@ -318,6 +348,30 @@ const (
 	SizeLast  Size = C.VIPS_SIZE_LAST
 )
 
+// Kernel represents VipsKernel type
+// https://libvips.github.io/libvips/API/current/libvips-resample.html#VipsKernel
+type Kernel int
+
+// Kernel enum
+const (
+	KernelNearest  Kernel = C.VIPS_KERNEL_NEAREST
+	KernelLinear   Kernel = C.VIPS_KERNEL_LINEAR
+	KernelCubic    Kernel = C.VIPS_KERNEL_CUBIC
+	KernelMitchell Kernel = C.VIPS_KERNEL_MITCHELL
+	KernelLanczos2 Kernel = C.VIPS_KERNEL_LANCZOS2
+	KernelLanczos3 Kernel = C.VIPS_KERNEL_LANCZOS3
+)
+
+// kernels resampling kernel names
+var kernels = map[string]Kernel{
+	"nearest":  KernelNearest,
+	"linear":   KernelLinear,
+	"cubic":    KernelCubic,
+	"mitchell": KernelMitchell,
+	"lanczos2": KernelLanczos2,
+	"lanczos3": KernelLanczos3,
+}
+
 // Align represents VIPS_ALIGN
 type Align int
 
@@ -277,6 +277,108 @@ int rotate_image_multi_page(VipsImage *in, VipsImage **out, VipsAngle angle) {
   return 0;
 }
 
+enum { TRANSFORM_SIMILARITY, TRANSFORM_AFFINE, TRANSFORM_MAPIM };
+
+// transform_image applies similarity, affine or mapim transform in premultiplied space
+static int transform_image(VipsImage *in, VipsImage **out, int op, const double *m,
+                           VipsImage *index, const char *interpolator,
+                           double r, double g, double b, double a) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 3);
+  VipsInterpolate *interpolate = vips_interpolate_new(interpolator);
+  if (!interpolate) {
+    g_object_unref(base);
+    return -1;
+  }
+  int has_alpha = vips_image_hasalpha(in);
+  double max = is_16bit(in->Type) ? 65535.0 : 255.0;
+  double scale = max / 255.0;
+  double alpha = has_alpha ? a / 255.0 : 1.0;
+  double background[4] = {r * scale * alpha, g * scale * alpha, b * scale * alpha, a * scale};
+  VipsArrayDouble *vipsBackground = vips_array_double_new(background, in->Bands < 4 ? in->Bands : 4);
+
+  VipsImage *src = in;
+  if (has_alpha) {
+    if (vips_premultiply(in, &t[0], "max_alpha", max, NULL)) {
+      goto error;
+    }
+    src = t[0];
+  }
+  int code;
+  switch (op) {
+  case TRANSFORM_SIMILARITY:
+    code = vips_similarity(src, &t[1], "angle", m[0],
+      "interpolate", interpolate, "background", vipsBackground, NULL);
+    break;
+  case TRANSFORM_AFFINE:
+    code = vips_affine(src, &t[1], m[0], m[1], m[2], m[3],
+      "interpolate", interpolate, "background", vipsBackground,
+      "extend", VIPS_EXTEND_BACKGROUND, NULL);
+    break;
+  default:
+    code = vips_mapim(src, &t[1], index,
+      "interpolate", interpolate, "background", vipsBackground, NULL);
+  }
+  if (code) {
+    goto error;
+  }
+  if (has_alpha) {
+    if (vips_unpremultiply(t[1], &t[2], "max_alpha", max, NULL) ||
+        vips_cast(t[2], out, in->BandFmt, NULL)) {
+      goto error;
+    }
+  } else if (vips_cast(t[1], out, in->BandFmt, NULL)) {
+    goto error;
+  }
+  vips_area_unref(VIPS_AREA(vipsBackground));
+  g_object_unref(interpolate);
+  g_object_unref(base);
+  return 0;
+
+error:
+  vips_area_unref(VIPS_AREA(vipsBackground));
+  g_object_unref(interpolate);
+  g_object_unref(base);
+  return -1;
+}
+
+int similarity_image(VipsImage *in, VipsImage **out, double angle,
+                     const char *interpolator, double r, double g, double b, double a) {
+  double m[1] = {angle};
+  return transform_image(in, out, TRANSFORM_SIMILARITY, m, NULL, interpolator, r, g, b, a);
+}
+
+int affine_image(VipsImage *in, VipsImage **out, double m0, double m1, double m2, double m3,
+                 const char *interpolator, double r, double g, double b, double a) {
+  double m[4] = {m0, m1, m2, m3};
+  return transform_image(in, out, TRANSFORM_AFFINE, m, NULL, interpolator, r, g, b, a);
+}
+
+int perspective_image(VipsImage *in, VipsImage **out, double *h, int width, int height,
+                      const char *interpolator, double r, double g, double b, double a) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 6);
+  VipsImage *matrix = vips_image_new_matrix_from_array(3, 3, h, 9);
+
+  // index image of the source coordinates by homography of the output coordinates
+  if (
+    vips_xyz(&t[0], width, height, NULL) ||
+    vips_bandjoin_const1(t[0], &t[1], 1.0, NULL) ||
+    vips_recomb(t[1], &t[2], matrix, NULL) ||
+    vips_extract_band(t[2], &t[3], 0, "n", 2, NULL) ||
+    vips_extract_band(t[2], &t[4], 2, NULL) ||
+    vips_divide(t[3], t[4], &t[5], NULL) ||
+    transform_image(in, out, TRANSFORM_MAPIM, NULL, t[5], interpolator, r, g, b, a)
+  ) {
+    g_object_unref(matrix);
+    g_object_unref(base);
+    return -1;
+  }
+  g_object_unref(matrix);
+  g_object_unref(base);
+  return 0;
+}
+
 int flatten_image(VipsImage *in, VipsImage **out, double r, double g,
                   double b) {
   if (!vips_image_hasalpha(in))
@@ -344,6 +446,138 @@ int label_image(VipsImage *in, VipsImage **out,
   return 0;
 }
 
+// label_layer colors mask with opacity into sRGB layer with alpha
+static int label_layer(VipsObject *base, VipsImage *mask, VipsImage **out,
+                       double *color, double opacity) {
+  double ones[3] = {1, 1, 1};
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 6);
+  if (vips_black(&t[0], mask->Xsize, mask->Ysize, NULL) ||
+      vips_linear(t[0], &t[1], ones, color, 3, NULL) ||
+      vips_cast(t[1], &t[2], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_linear1(mask, &t[3], opacity, 0.0, NULL) ||
+      vips_cast(t[3], &t[4], VIPS_FORMAT_UCHAR, NULL) ||
+      vips_bandjoin2(t[2], t[4], &t[5], NULL)) {
+    return 1;
+  }
+  return vips_copy(t[5], out, "interpretation", VIPS_INTERPRETATION_sRGB, NULL);
+}
+
+int rich_label_image(VipsImage *in, VipsImage **out, LabelOptions *o) {
+  int page_height = vips_image_get_page_height(in);
+  int in_width = in->Xsize;
+  int n_pages = in->Ysize / page_height;
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  VipsImage **t = (VipsImage **)vips_object_local_array(base, 14);
+  VipsImage **layers = (VipsImage **)vips_object_local_array(base, 4);
+  int modes[3] = {VIPS_BLEND_MODE_OVER, VIPS_BLEND_MODE_OVER, VIPS_BLEND_MODE_OVER};
+  int n = 0;
+
+  int code = o->fontfile && *o->fontfile
+    ? vips_text(&t[0], o->text, "font", o->font, "fontfile", o->fontfile,
+                "width", o->width, "spacing", o->spacing, "align", o->align, NULL)
+    : vips_text(&t[0], o->text, "font", o->font,
+                "width", o->width, "spacing", o->spacing, "align", o->align, NULL);
+  if (code) {
+    g_object_unref(base);
+    return 1;
+  }
+  int pad = o->background ? o->padding : 0;
+  int box_width = t[0]->Xsize + 2 * pad;
+  int box_height = t[0]->Ysize + 2 * pad;
+  // margin of the stroke and shadow around the box
+  int margin = o->stroke;
+  if (o->shadowX || o->shadowY || o->shadowBlur > 0) {
+    int shadow = o->stroke + (int)(3 * o->shadowBlur) + 1 +
+                 VIPS_MAX(abs(o->shadowX), abs(o->shadowY));
+    margin = VIPS_MAX(margin, shadow);
+  }
+  int width = box_width + 2 * margin;
+  int height = box_height + 2 * margin;
+
+  // text mask and stroke dilated from the text mask
+  if (vips_embed(t[0], &t[1], margin + pad, margin + pad, width, height, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+  VipsImage *outline = t[1];
+  if (o->stroke > 0) {
+    int window = 2 * o->stroke + 1;
+    if (vips_rank(t[1], &t[2], window, window, window * window - 1, NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    outline = t[2];
+  }
+  if (o->background) {
+    if (vips_black(&t[3], box_width, box_height, NULL) ||
+        vips_linear1(t[3], &t[4], 1.0, 255.0, NULL) ||
+        vips_cast(t[4], &t[5], VIPS_FORMAT_UCHAR, NULL) ||
+        vips_embed(t[5], &t[6], margin, margin, width, height, NULL) ||
+        label_layer(base, t[6], &layers[n++], o->backgroundColor, o->opacity)) {
+      g_object_unref(base);
+      return 1;
+    }
+  }
+  if (o->shadowX || o->shadowY || o->shadowBlur > 0) {
+    if (vips_embed(outline, &t[7], o->shadowX, o->shadowY, width, height, NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+    VipsImage *shadow = t[7];
+    if (o->shadowBlur > 0) {
+      if (vips_gaussblur(t[7], &t[8], o->shadowBlur, NULL) ||
+          vips_cast(t[8], &t[9], VIPS_FORMAT_UCHAR, NULL)) {
+        g_object_unref(base);
+        return 1;
+      }
+      shadow = t[9];
+    }
+    if (label_layer(base, shadow, &layers[n++], o->shadowColor, o->opacity * o->shadowOpacity)) {
+      g_object_unref(base);
+      return 1;
+    }
+  }
+  if (o->stroke > 0 &&
+      label_layer(base, outline, &layers[n++], o->strokeColor, o->opacity)) {
+    g_object_unref(base);
+    return 1;
+  }
+  if (label_layer(base, t[1], &layers[n++], o->color, o->opacity)) {
+    g_object_unref(base);
+    return 1;
+  }
+  if (n > 1) {
+    if (vips_composite(layers, &t[10], n, modes, NULL)) {
+      g_object_unref(base);
+      return 1;
+    }
+  } else if (vips_copy(layers[0], &t[10], NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+  // anchor the box at x, y
+  int x = o->x, y = o->y;
+  if (o->align == VIPS_ALIGN_CENTRE) {
+    x -= box_width / 2;
+  } else if (o->align == VIPS_ALIGN_HIGH) {
+    x -= box_width;
+  }
+  if (o->valign == VIPS_ALIGN_CENTRE) {
+    y += (o->size - box_height) / 2;
+  } else if (o->valign == VIPS_ALIGN_HIGH) {
+    y += o->size - box_height;
+  }
+  if (vips_embed(t[10], &t[11], x - margin, y - margin, in_width, page_height, NULL) ||
+      vips_replicate(t[11], &t[12], 1, n_pages, NULL) ||
+      vips_composite2(in, t[12], &t[13], VIPS_BLEND_MODE_OVER, NULL) ||
+      vips_cast(t[13], out, in->BandFmt, NULL)) {
+    g_object_unref(base);
+    return 1;
+  }
+  g_object_unref(base);
+  return 0;
+}
+
 int is_16bit(VipsInterpretation interpretation) {
   return interpretation == VIPS_INTERPRETATION_RGB16 ||
          interpretation == VIPS_INTERPRETATION_GREY16;
@@ -353,6 +587,107 @@ int add_alpha(VipsImage *in, VipsImage **out) {
   return vips_addalpha(in, out, NULL);
 }
 
+int luminance_mask(VipsImage *in, VipsImage **out) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+  VipsImage *tmp = in;
+
+  // transparent areas of the mask are treated as black
+  if (vips_image_hasalpha(tmp)) {
+    if (flatten_image(tmp, &t[0], 0, 0, 0)) {
+      clear_image(&base);
+      return 1;
+    }
+    tmp = t[0];
+  }
+
+  if (vips_colourspace(tmp, &t[1], VIPS_INTERPRETATION_B_W, NULL) ||
+      vips_extract_band(t[1], &t[2], 0, NULL) ||
+      vips_bandjoin2(t[2], t[2], out, NULL)) {
+    clear_image(&base);
+    return 1;
+  }
+
+  clear_image(&base);
+  return 0;
+}
+
+typedef int (*ColourOp)(VipsImage *in, VipsImage **out, void *data);
+
+// applies op to the colour bands cast back to the input format, preserving alpha
+static int colour_bands_op(VipsImage *in, VipsImage **out, ColourOp op, void *data) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+  int alpha = vips_image_hasalpha(in);
+
+  if (alpha) {
+    if (vips_extract_band(in, &t[0], 0, "n", in->Bands - 1, NULL) ||
+        vips_extract_band(in, &t[1], in->Bands - 1, NULL)) {
+      clear_image(&base);
+      return 1;
+    }
+  } else {
+    t[0] = in;
+    g_object_ref(in);
+  }
+
+  if (op(t[0], &t[2], data) ||
+      vips_cast(t[2], alpha ? &t[3] : out, in->BandFmt, NULL) ||
+      (alpha && vips_bandjoin2(t[3], t[1], out, NULL))) {
+    clear_image(&base);
+    return 1;
+  }
+
+  clear_image(&base);
+  return 0;
+}
+
+static int maplut_op(VipsImage *in, VipsImage **out, void *data) {
+  return vips_maplut(in, out, (VipsImage *) data, NULL);
+}
+
+static int recomb_op(VipsImage *in, VipsImage **out, void *data) {
+  return vips_recomb(in, out, (VipsImage *) data, NULL);
+}
+
+static int hist_equal_op(VipsImage *in, VipsImage **out, void *data) {
+  return vips_hist_equal(in, out, NULL);
+}
+
+int maplut_image(VipsImage *in, VipsImage **out, double *lut, int size) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+
+  if (!(t[0] = vips_image_new_from_memory_copy(lut, size * 3 * sizeof(double),
+                                                size, 1, 3, VIPS_FORMAT_DOUBLE)) ||
+      vips_cast(t[0], &t[1], in->BandFmt, NULL) ||
+      colour_bands_op(in, out, maplut_op, t[1])) {
+    clear_image(&base);
+    return 1;
+  }
+
+  clear_image(&base);
+  return 0;
+}
+
+int recomb_image(VipsImage *in, VipsImage **out, double *matrix) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+
+  if (!(t[0] = vips_image_new_matrix_from_array(3, 3, matrix, 9)) ||
+      colour_bands_op(in, out, recomb_op, t[0])) {
+    clear_image(&base);
+    return 1;
+  }
+
+  clear_image(&base);
+  return 0;
+}
+
+int equalize_image(VipsImage *in, VipsImage **out) {
+  return colour_bands_op(in, out, hist_equal_op, NULL);
+}
+
 double max_alpha(VipsImage *in) {
   switch (in->BandFmt) {
     case VIPS_FORMAT_USHORT:
@@ -426,10 +761,105 @@ int getpoint(VipsImage *in, double **vector, int n, int x, int y) {
   return vips_getpoint(in, vector, &n, x, y, NULL);
 }
 
+int image_to_luma(VipsImage *in, void **buf, size_t *len, int *width, int *height) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 3);
+
+  if (
+    vips_colourspace(in, &t[0], VIPS_INTERPRETATION_B_W, NULL) ||
+    vips_extract_band(t[0], &t[1], 0, NULL) ||
+    vips_cast_uchar(t[1], &t[2], NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+  *width = t[2]->Xsize;
+  *height = t[2]->Ysize;
+  *buf = vips_image_write_to_memory(t[2], len);
+  g_object_unref(base);
+  if (!*buf) return -1;
+  return 0;
+}
+
+int image_to_srgb(VipsImage *in, void **buf, size_t *len, int *width, int *height, int *bands) {
+  VipsObject *base = VIPS_OBJECT(vips_image_new());
+  VipsImage **t = (VipsImage **) vips_object_local_array(base, 2);
+
+  if (
+    vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL) ||
+    vips_cast_uchar(t[0], &t[1], NULL)
+  ) {
+    g_object_unref(base);
+    return -1;
+  }
+  *width = t[1]->Xsize;
+  *height = t[1]->Ysize;
+  *bands = t[1]->Bands;
+  *buf = vips_image_write_to_memory(t[1], len);
+  g_object_unref(base);
+  if (!*buf) return -1;
+  return 0;
+}
+
+int icc_transform(VipsImage *in, VipsImage **out, const char *output_profile,
+                  const char *input_profile, VipsIntent intent, int depth) {
+  return vips_icc_transform(in, out, output_profile,
+    "input_profile", input_profile,
+    "intent", intent,
+    "depth", depth,
+    "embedded", TRUE,
+    NULL);
+}
+
 int to_colorspace(VipsImage *in, VipsImage **out, VipsInterpretation space) {
   return vips_colourspace(in, out, space, NULL);
 }
 
+int resize_image(VipsImage *in, VipsImage **out, double hscale, double vscale, int kernel) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+  double max = max_alpha(in);
+
+  if (!vips_image_hasalpha(in)) {
+    int code = vips_resize(in, out, hscale, "vscale", vscale, "kernel", kernel, NULL);
+    clear_image(&base);
+    return code;
+  }
+
+  // premultiplied to avoid dark fringes around transparency
+  if (vips_premultiply(in, &t[0], "max_alpha", max, NULL) ||
+      vips_resize(t[0], &t[1], hscale, "vscale", vscale, "kernel", kernel, NULL) ||
+      vips_unpremultiply(t[1], &t[2], "max_alpha", max, NULL) ||
+      vips_cast(t[2], out, in->BandFmt, NULL)) {
+    clear_image(&base);
+    return 1;
+  }
+
+  clear_image(&base);
+  return 0;
+}
+
+int pixelate_image(VipsImage *in, VipsImage **out, int block) {
+  VipsImage *base = vips_image_new();
+  VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+
+  // average of each block, edges extended to the input size
+  if (vips_shrink(in, &t[0], block, block, NULL) ||
+      vips_zoom(t[0], &t[1], block, block, NULL) ||
+      vips_embed(t[1], out, 0, 0, in->Xsize, in->Ysize,
+                 "extend", VIPS_EXTEND_COPY, NULL)) {
+    clear_image(&base);
+    return 1;
+  }
+
+  clear_image(&base);
+  return 0;
+}
+
+int insert_image(VipsImage *main, VipsImage *sub, VipsImage **out, int x, int y) {
+  return vips_insert(main, sub, out, x, y, NULL);
+}
+
 int gaussian_blur_image(VipsImage *in, VipsImage **out, double sigma) {
   return vips_gaussblur(in, out, sigma, NULL);
 }
@@ -489,6 +919,41 @@ const char * get_meta_string(const VipsImage *image, const char *name) {
 	return "";
 }
 
+const char * interpretation_nick(VipsInterpretation interpretation) {
+  return vips_enum_nick(VIPS_TYPE_INTERPRETATION, interpretation);
+}
+
+int get_meta_blob(const VipsImage *image, const char *name, const void **buf, size_t *len) {
+  if (vips_image_get_typeof(image, name) == 0) return -1;
+  return vips_image_get_blob(image, name, buf, len);
+}
+
+int get_meta_int(const VipsImage *image, const char *name, int *out) {
+  if (vips_image_get_typeof(image, name) == 0) return -1;
+  return vips_image_get_int(image, name, out);
+}
+
+int get_meta_array_int(VipsImage *image, const char *name, int **out, int *n) {
+  if (vips_image_get_typeof(image, name) == 0) return -1;
+  return vips_image_get_array_int(image, name, out, n);
+}
+
+gboolean has_meta(const VipsImage *image, const char *name) {
+  return vips_image_get_typeof(image, name) != 0;
+}
+
+void remove_meta(VipsImage *image, const char *name) {
+  vips_image_remove(image, name);
+}
+
+void set_meta_string(VipsImage *image, const char *name, const char *value) {
+  vips_image_set_string(image, name, value);
+}
+
+void set_meta_blob(VipsImage *image, const char *name, const void *buf, size_t len) {
+  vips_image_set_blob_copy(image, name, buf, len);
+}
+
 int remove_exif(VipsImage *in, VipsImage **out) {
   static double default_resolution = 72.0 / 25.4;
 
@@ -513,3 +978,23 @@ int remove_exif(VipsImage *in, VipsImage **out) {
   g_strfreev(fields);
   return 0;
 }
+
+static void image_eval(VipsImage *image, VipsProgress *progress, void *ptr) {
+  if (goImageEvalKill(ptr)) {
+    vips_image_set_kill(image, TRUE);
+  }
+}
+
+// https://www.libvips.org/API/current/VipsImage.html#VipsImage-eval
+gulong set_image_eval(VipsImage *in, void *ptr) {
+  g_object_ref(in);
+  vips_image_set_progress(in, TRUE);
+  return g_signal_connect(in, "eval", G_CALLBACK(image_eval), ptr);
+}
+
+void unset_image_eval(VipsImage *in, gulong handler_id) {
+  if (G_IS_OBJECT(in)) {
+    g_signal_handler_disconnect(in, handler_id);
+    g_object_unref(in);
+  }
+}
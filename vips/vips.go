@@ -233,6 +233,50 @@ func vipsRotateMultiPage(in *C.VipsImage, angle Angle) (*C.VipsImage, error) {
 	return out, nil
 }
 
+// https://libvips.github.io/libvips/API/current/libvips-resample.html#vips-similarity
+func vipsSimilarity(in *C.VipsImage, angle float64, interpolator string, bg *ColorRGBA) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	cInterpolator := C.CString(interpolator)
+	defer freeCString(cInterpolator)
+
+	if err := C.similarity_image(in, &out, C.double(angle), cInterpolator,
+		C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(bg.A)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-resample.html#vips-affine
+func vipsAffine(in *C.VipsImage, m [4]float64, interpolator string, bg *ColorRGBA) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	cInterpolator := C.CString(interpolator)
+	defer freeCString(cInterpolator)
+
+	if err := C.affine_image(in, &out, C.double(m[0]), C.double(m[1]), C.double(m[2]), C.double(m[3]),
+		cInterpolator, C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(bg.A)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-resample.html#vips-mapim
+func vipsPerspective(
+	in *C.VipsImage, h [9]float64, width, height int, interpolator string, bg *ColorRGBA,
+) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	cInterpolator := C.CString(interpolator)
+	defer freeCString(cInterpolator)
+
+	if err := C.perspective_image(in, &out, (*C.double)(&h[0]), C.int(width), C.int(height),
+		cInterpolator, C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(bg.A)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-flatten
 func vipsFlatten(in *C.VipsImage, color *Color) (*C.VipsImage, error) {
 	var out *C.VipsImage
@@ -267,6 +311,53 @@ func vipsLabel(
 	return out, nil
 }
 
+func vipsRichLabel(in *C.VipsImage, opts *LabelOptions) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	cText := C.CString(opts.Text)
+	defer freeCString(cText)
+	cFont := C.CString(opts.Font)
+	defer freeCString(cFont)
+	cFontFile := C.CString(opts.FontFile)
+	defer freeCString(cFontFile)
+
+	o := C.LabelOptions{
+		text:          cText,
+		font:          cFont,
+		fontfile:      cFontFile,
+		x:             C.int(opts.X),
+		y:             C.int(opts.Y),
+		size:          C.int(opts.Size),
+		align:         C.VipsAlign(opts.Align),
+		valign:        C.VipsAlign(opts.VAlign),
+		width:         C.int(opts.Width),
+		spacing:       C.int(opts.Spacing),
+		opacity:       C.double(opts.Opacity),
+		stroke:        C.int(opts.Stroke),
+		shadowX:       C.int(opts.ShadowX),
+		shadowY:       C.int(opts.ShadowY),
+		shadowBlur:    C.double(opts.ShadowBlur),
+		shadowOpacity: C.double(opts.ShadowOpacity),
+		padding:       C.int(opts.Padding),
+	}
+	setColor := func(dst *[3]C.double, c *Color) {
+		if c != nil {
+			*dst = [3]C.double{C.double(c.R), C.double(c.G), C.double(c.B)}
+		}
+	}
+	setColor(&o.color, opts.Color)
+	setColor(&o.strokeColor, opts.StrokeColor)
+	setColor(&o.shadowColor, opts.ShadowColor)
+	if opts.Background != nil {
+		o.background = 1
+		setColor(&o.backgroundColor, opts.Background)
+	}
+	if err := C.rich_label_image(in, &out, &o); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 func vipsAddAlpha(in *C.VipsImage) (*C.VipsImage, error) {
 	var out *C.VipsImage
 
@@ -277,6 +368,16 @@ func vipsAddAlpha(in *C.VipsImage) (*C.VipsImage, error) {
 	return out, nil
 }
 
+func vipsLuminanceMask(in *C.VipsImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.luminance_mask(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-composite2
 func vipsComposite2(base *C.VipsImage, overlay *C.VipsImage, mode BlendMode, x, y int) (*C.VipsImage, error) {
 	var out *C.VipsImage
@@ -309,6 +410,36 @@ func vipsLinear(in *C.VipsImage, a, b []float64, n int) (*C.VipsImage, error) {
 	return out, nil
 }
 
+func vipsMapLUT(in *C.VipsImage, lut []float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.maplut_image(in, &out, (*C.double)(&lut[0]), C.int(len(lut)/3)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsRecomb(in *C.VipsImage, matrix [9]float64) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.recomb_image(in, &out, (*C.double)(&matrix[0])); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsEqualize(in *C.VipsImage) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.equalize_image(in, &out); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-arithmetic.html#vips-find-trim
 func vipsFindTrim(in *C.VipsImage, threshold float64, x, y int) (int, int, int, int, error) {
 	var left, top, width, height C.int
@@ -334,6 +465,34 @@ func vipsGetPoint(in *C.VipsImage, n int, x int, y int) ([]float64, error) {
 	return (*[4]float64)(unsafe.Pointer(out))[:n:n], nil
 }
 
+// https://www.libvips.org/API/current/VipsImage.html#vips-image-write-to-memory
+func vipsImageToLuma(in *C.VipsImage) ([]byte, int, int, error) {
+	var buf unsafe.Pointer
+	var size C.size_t
+	var width, height C.int
+
+	if err := C.image_to_luma(in, &buf, &size, &width, &height); err != 0 {
+		return nil, 0, 0, handleVipsError()
+	}
+	defer gFreePointer(buf)
+
+	return C.GoBytes(buf, C.int(size)), int(width), int(height), nil
+}
+
+// https://www.libvips.org/API/current/VipsImage.html#vips-image-write-to-memory
+func vipsImageToSRGB(in *C.VipsImage) ([]byte, int, int, int, error) {
+	var buf unsafe.Pointer
+	var size C.size_t
+	var width, height, bands C.int
+
+	if err := C.image_to_srgb(in, &buf, &size, &width, &height, &bands); err != 0 {
+		return nil, 0, 0, 0, handleVipsError()
+	}
+	defer gFreePointer(buf)
+
+	return C.GoBytes(buf, C.int(size)), int(width), int(height), int(bands), nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-colour.html#vips-colourspace
 func vipsToColorSpace(in *C.VipsImage, interpretation Interpretation) (*C.VipsImage, error) {
 	var out *C.VipsImage
@@ -347,6 +506,55 @@ func vipsToColorSpace(in *C.VipsImage, interpretation Interpretation) (*C.VipsIm
 	return out, nil
 }
 
+// https://www.libvips.org/API/current/libvips-colour.html#vips-icc-transform
+func vipsICCTransform(
+	in *C.VipsImage, outputProfile, inputProfile string, intent Intent, depth int,
+) (*C.VipsImage, error) {
+	var out *C.VipsImage
+	cOutput := C.CString(outputProfile)
+	defer freeCString(cOutput)
+	cInput := C.CString(inputProfile)
+	defer freeCString(cInput)
+
+	if err := C.icc_transform(in, &out, cOutput, cInput, C.VipsIntent(intent), C.int(depth)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-resample.html#vips-resize
+func vipsResize(in *C.VipsImage, hscale, vscale float64, kernel Kernel) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.resize_image(in, &out, C.double(hscale), C.double(vscale), C.int(kernel)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+func vipsPixelate(in *C.VipsImage, block int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.pixelate_image(in, &out, C.int(block)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
+// https://libvips.github.io/libvips/API/current/libvips-conversion.html#vips-insert
+func vipsInsert(main, sub *C.VipsImage, x, y int) (*C.VipsImage, error) {
+	var out *C.VipsImage
+
+	if err := C.insert_image(main, sub, &out, C.int(x), C.int(y)); err != 0 {
+		return nil, handleImageError(out)
+	}
+
+	return out, nil
+}
+
 // https://libvips.github.io/libvips/API/current/libvips-convolution.html#vips-gaussblur
 func vipsGaussianBlur(in *C.VipsImage, sigma float64) (*C.VipsImage, error) {
 	var out *C.VipsImage
@@ -416,3 +624,78 @@ func vipsImageSetDelay(in *C.VipsImage, data []C.int) error {
 func vipsGetMetaString(image *C.VipsImage, name string) string {
 	return C.GoString(C.get_meta_string(image, cachedCString(name)))
 }
+
+func vipsGetMetaBlob(image *C.VipsImage, name string) []byte {
+	var buf unsafe.Pointer
+	var length C.size_t
+	if C.get_meta_blob(image, cachedCString(name), &buf, &length) != 0 || buf == nil {
+		return nil
+	}
+	return C.GoBytes(buf, C.int(length))
+}
+
+func vipsGetMetaInt(image *C.VipsImage, name string) (int, bool) {
+	var out C.int
+	if C.get_meta_int(image, cachedCString(name), &out) != 0 {
+		return 0, false
+	}
+	return int(out), true
+}
+
+func vipsGetMetaArrayInt(image *C.VipsImage, name string) []int {
+	var out *C.int
+	var n C.int
+	if C.get_meta_array_int(image, cachedCString(name), &out, &n) != 0 || out == nil {
+		return nil
+	}
+	values := make([]int, int(n))
+	for i, v := range unsafe.Slice(out, int(n)) {
+		values[i] = int(v)
+	}
+	return values
+}
+
+func vipsImageGetFields(image *C.VipsImage) (fields []string) {
+	names := C.vips_image_get_fields(image)
+	defer C.g_strfreev(names)
+	for p := names; p != nil && *p != nil; p = (**C.char)(unsafe.Add(unsafe.Pointer(p), unsafe.Sizeof(*p))) {
+		fields = append(fields, C.GoString(*p))
+	}
+	return
+}
+
+func vipsImageRemoveMeta(image *C.VipsImage, name string) {
+	cName := C.CString(name)
+	defer freeCString(cName)
+	C.remove_meta(image, cName)
+}
+
+func vipsImageSetMetaString(image *C.VipsImage, name, value string) {
+	cName := C.CString(name)
+	defer freeCString(cName)
+	cValue := C.CString(value)
+	defer freeCString(cValue)
+	C.set_meta_string(image, cName, cValue)
+}
+
+func vipsImageSetMetaBlob(image *C.VipsImage, name string, buf []byte) {
+	if len(buf) == 0 {
+		return
+	}
+	cName := C.CString(name)
+	defer freeCString(cName)
+	C.set_meta_blob(image, cName, unsafe.Pointer(&buf[0]), C.size_t(len(buf)))
+}
+
+func vipsHasMeta(image *C.VipsImage, name string) bool {
+	return fromGboolean(C.has_meta(image, cachedCString(name)))
+}
+
+// https://www.libvips.org/API/current/VipsImage.html#vips-image-set-kill
+func vipsSetImageEval(in *C.VipsImage, ptr unsafe.Pointer) C.gulong {
+	return C.set_image_eval(in, ptr)
+}
+
+func vipsUnsetImageEval(in *C.VipsImage, handlerID C.gulong) {
+	C.unset_image_eval(in, handlerID)
+}
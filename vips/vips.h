@@ -2,6 +2,8 @@
 #include <vips/vips.h>
 #include <vips/vector.h>
 
+extern int goImageEvalKill(void*);
+
 int image_new_from_source(VipsSourceCustom *source, VipsImage **out);
 
 int image_new_from_source_with_option(VipsSourceCustom *source, VipsImage **out, const char *option_string);
@@ -55,12 +57,43 @@ int extract_area_multi_page(VipsImage *in, VipsImage **out, int left, int top,
 
 int rotate_image(VipsImage *in, VipsImage **out, VipsAngle angle);
 int rotate_image_multi_page(VipsImage *in, VipsImage **out, VipsAngle angle);
+int similarity_image(VipsImage *in, VipsImage **out, double angle,
+                     const char *interpolator, double r, double g, double b, double a);
+int affine_image(VipsImage *in, VipsImage **out, double m0, double m1, double m2, double m3,
+                 const char *interpolator, double r, double g, double b, double a);
+int perspective_image(VipsImage *in, VipsImage **out, double *h, int width, int height,
+                      const char *interpolator, double r, double g, double b, double a);
 int flatten_image(VipsImage *in, VipsImage **out, double r, double g, double b);
 int label_image(VipsImage *in, VipsImage **out,
           const char *text, const char *font,
           int x, int y, int size, VipsAlign align,
           double r, double g, double b, float opacity);
+
+typedef struct LabelOptions {
+  const char *text;
+  const char *font;
+  const char *fontfile;
+  int x, y, size;
+  VipsAlign align, valign;
+  int width, spacing;
+  double color[3];
+  double opacity;
+  int stroke;
+  double strokeColor[3];
+  int shadowX, shadowY;
+  double shadowBlur;
+  double shadowColor[3];
+  double shadowOpacity;
+  int background, padding;
+  double backgroundColor[3];
+} LabelOptions;
+
+int rich_label_image(VipsImage *in, VipsImage **out, LabelOptions *o);
 int add_alpha(VipsImage *in, VipsImage **out);
+int luminance_mask(VipsImage *in, VipsImage **out);
+int maplut_image(VipsImage *in, VipsImage **out, double *lut, int size);
+int recomb_image(VipsImage *in, VipsImage **out, double *matrix);
+int equalize_image(VipsImage *in, VipsImage **out);
 double max_alpha(VipsImage *in);
 
 int composite2_image(VipsImage *base, VipsImage *overlay, VipsImage **out,
@@ -75,9 +108,16 @@ int linear(VipsImage *in, VipsImage **out, double *a, double *b, int n);
 int find_trim(VipsImage *in, int *left, int *top, int *width, int *height,
   double threshold, int x, int y);
 int getpoint(VipsImage *in, double **vector, int n, int x, int y);
+int image_to_luma(VipsImage *in, void **buf, size_t *len, int *width, int *height);
+int image_to_srgb(VipsImage *in, void **buf, size_t *len, int *width, int *height, int *bands);
 
+int icc_transform(VipsImage *in, VipsImage **out, const char *output_profile,
+                  const char *input_profile, VipsIntent intent, int depth);
 int to_colorspace(VipsImage *in, VipsImage **out, VipsInterpretation space);
 
+int resize_image(VipsImage *in, VipsImage **out, double hscale, double vscale, int kernel);
+int pixelate_image(VipsImage *in, VipsImage **out, int block);
+int insert_image(VipsImage *main, VipsImage *sub, VipsImage **out, int x, int y);
 int gaussian_blur_image(VipsImage *in, VipsImage **out, double sigma);
 int sharpen_image(VipsImage *in, VipsImage **out, double sigma, double x1,
                   double m2);
@@ -91,4 +131,15 @@ void set_page_height(VipsImage *in, int height);
 int get_meta_loader(const VipsImage *in, const char **out);
 void set_image_delay(VipsImage *in, const int *array, int n);
 const char * get_meta_string(const VipsImage *image, const char *name);
+const char * interpretation_nick(VipsInterpretation interpretation);
+int get_meta_blob(const VipsImage *image, const char *name, const void **buf, size_t *len);
+int get_meta_int(const VipsImage *image, const char *name, int *out);
+int get_meta_array_int(VipsImage *image, const char *name, int **out, int *n);
+gboolean has_meta(const VipsImage *image, const char *name);
+void remove_meta(VipsImage *image, const char *name);
+void set_meta_string(VipsImage *image, const char *name, const char *value);
+void set_meta_blob(VipsImage *image, const char *name, const void *buf, size_t len);
 int remove_exif(VipsImage *in, VipsImage **out);
+
+gulong set_image_eval(VipsImage *in, void *ptr);
+void unset_image_eval(VipsImage *in, gulong handler_id);
@@ -0,0 +1,91 @@
+package vips
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// watermarkOptions key=value options of watermark filter
+type watermarkOptions struct {
+	Gravity   string
+	Margin    int
+	Size      int
+	Tile      bool
+	Angle     float64
+	Spacing   int
+	Blend     BlendMode
+	Alpha     float64
+	MinWidth  int
+	MinHeight int
+}
+
+// isWatermarkOption if watermark arg is an option flag or key=value pair
+func isWatermarkOption(arg string) bool {
+	return arg == "tile" || strings.Contains(arg, "=")
+}
+
+// parseWatermarkOptions separates options from the positional args of watermark filter
+// e.g. gravity=south_east, margin=5p, size=20p, tile, angle=45, spacing=40, blend=multiply,
+// alpha=30, min_size=300 or min_size=400x300.
+// Percentages of margin, size and spacing are relative to the shorter side of the image
+func parseWatermarkOptions(shorter int, args ...string) (o *watermarkOptions, positional []string) {
+	o = &watermarkOptions{Blend: BlendModeOver}
+	for _, arg := range args {
+		arg = strings.TrimSpace(arg)
+		if !isWatermarkOption(arg) {
+			positional = append(positional, arg)
+			continue
+		}
+		key, value, _ := strings.Cut(arg, "=")
+		if s, e := url.QueryUnescape(value); e == nil {
+			value = strings.TrimSpace(s)
+		}
+		switch key {
+		case "gravity":
+			o.Gravity = value
+		case "margin":
+			o.Margin = max(0, watermarkLength(value, shorter))
+		case "size":
+			o.Size = max(0, watermarkLength(value, shorter))
+		case "tile":
+			o.Tile = true
+		case "angle":
+			o.Angle, _ = strconv.ParseFloat(value, 64)
+			o.Angle = math.Mod(o.Angle, 360)
+		case "spacing":
+			o.Spacing = max(0, watermarkLength(value, shorter))
+		case "blend":
+			o.Blend = blendMode(value)
+		case "alpha":
+			o.Alpha, _ = strconv.ParseFloat(value, 64)
+		case "min_size":
+			w, h, ok := strings.Cut(value, "x")
+			o.MinWidth, _ = strconv.Atoi(w)
+			o.MinHeight = o.MinWidth
+			if ok {
+				o.MinHeight, _ = strconv.Atoi(h)
+			}
+		}
+	}
+	return
+}
+
+// watermarkLength pixels or percentage Np of the shorter side
+func watermarkLength(value string, shorter int) int {
+	if strings.HasSuffix(value, "p") {
+		n, _ := strconv.ParseFloat(strings.TrimSuffix(value, "p"), 64)
+		return int(n * float64(shorter) / 100)
+	}
+	n, _ := strconv.Atoi(value)
+	return n
+}
+
+// gravityPosition position of w x h box within width x height by gravity, inset by margin
+func gravityPosition(gravity string, width, height, w, h, margin int) (int, int) {
+	gx, gy := parseGravity(gravity)
+	x := margin + int(gx*float64(width-w-2*margin))
+	y := margin + int(gy*float64(height-h-2*margin))
+	return x, y
+}
@@ -0,0 +1,46 @@
+package vips
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWatermarkOptions(t *testing.T) {
+	o, positional := parseWatermarkOptions(400, "repeat", "10p", "gravity=south_east", "margin=5p",
+		"size=20p", "spacing=40", "blend=multiply", "alpha=30", "min_size=300", "50")
+	assert.Equal(t, []string{"repeat", "10p", "50"}, positional)
+	assert.Equal(t, "south_east", o.Gravity)
+	assert.Equal(t, 20, o.Margin)
+	assert.Equal(t, 80, o.Size)
+	assert.Equal(t, 40, o.Spacing)
+	assert.Equal(t, BlendModeMultiply, o.Blend)
+	assert.Equal(t, 30.0, o.Alpha)
+	assert.Equal(t, 300, o.MinWidth)
+	assert.Equal(t, 300, o.MinHeight)
+	assert.False(t, o.Tile)
+
+	o, positional = parseWatermarkOptions(400, "tile", "angle=405", "min_size=400x300", "blend=unknown")
+	assert.Empty(t, positional)
+	assert.True(t, o.Tile)
+	assert.Equal(t, 45.0, o.Angle)
+	assert.Equal(t, 400, o.MinWidth)
+	assert.Equal(t, 300, o.MinHeight)
+	assert.Equal(t, BlendModeOver, o.Blend)
+
+	o, _ = parseWatermarkOptions(400, "angle=-30")
+	assert.False(t, o.Tile)
+	assert.Equal(t, -30.0, o.Angle)
+}
+
+func TestGravityPosition(t *testing.T) {
+	x, y := gravityPosition("south_east", 400, 300, 100, 50, 10)
+	assert.Equal(t, 290, x)
+	assert.Equal(t, 240, y)
+	x, y = gravityPosition("north_west", 400, 300, 100, 50, 10)
+	assert.Equal(t, 10, x)
+	assert.Equal(t, 10, y)
+	x, y = gravityPosition("center", 400, 300, 100, 50, 10)
+	assert.Equal(t, 150, x)
+	assert.Equal(t, 125, y)
+}